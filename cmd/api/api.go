@@ -33,11 +33,14 @@ func Init() {
 	basePath = cfg.Mod.BasePath
 	log = app.GetLogger("api")
 
+	_, Port, _ = net.SplitHostPort(cfg.Mod.Listen)
+
 	initStatic(cfg.Mod.StaticDir)
 	initWS()
 
 	HandleFunc("api/streams", streamsHandler)
 	HandleFunc("api/ws", apiWS)
+	HandleFunc("api/playback", playbackHandler)
 
 	// ensure we can listen without errors
 	listener, err := net.Listen("tcp", cfg.Mod.Listen)
@@ -51,12 +54,18 @@ func Init() {
 	go func() {
 		s := http.Server{}
 
-		if log.Trace().Enabled() {
-			s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if log.Trace().Enabled() {
 				log.Trace().Stringer("url", r.URL).Msgf("[api] %s", r.Method)
-				http.DefaultServeMux.ServeHTTP(w, r)
-			})
-		}
+			}
+
+			if handler := getContentTypeHandler(r); handler != nil {
+				handler(w, r)
+				return
+			}
+
+			http.DefaultServeMux.ServeHTTP(w, r)
+		})
 
 		if err = s.Serve(listener); err != nil {
 			log.Fatal().Err(err).Msg("[api] serve")
@@ -75,13 +84,29 @@ func HandleFunc(pattern string, handler http.HandlerFunc) {
 	http.HandleFunc(pattern, handler)
 }
 
+// HandleContentType handle requests with content type in Accept or
+// Content-Type header on any path, ex. RTSP-over-HTTP tunnel
+func HandleContentType(contentType string, handler http.HandlerFunc) {
+	contentTypeHandlers[contentType] = handler
+}
+
 func HandleWS(msgType string, handler WSHandler) {
 	wsHandlers[msgType] = handler
 }
 
+var Port string
+
 var basePath string
 var log zerolog.Logger
 var wsHandlers = make(map[string]WSHandler)
+var contentTypeHandlers = make(map[string]http.HandlerFunc)
+
+func getContentTypeHandler(r *http.Request) http.HandlerFunc {
+	if handler := contentTypeHandlers[r.Header.Get("Accept")]; handler != nil {
+		return handler
+	}
+	return contentTypeHandlers[r.Header.Get("Content-Type")]
+}
 
 func streamsHandler(w http.ResponseWriter, r *http.Request) {
 	src := r.URL.Query().Get("src")
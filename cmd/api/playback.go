@@ -0,0 +1,59 @@
+package api
+
+import (
+	"github.com/AlexxIT/go2rtc/cmd/streams"
+	"github.com/AlexxIT/go2rtc/pkg/streamer"
+	"net/http"
+	"strconv"
+)
+
+// playbackHandler controls producers with recorded content (ex. NVR archive):
+// - api/playback?src=camera1&action=pause
+// - api/playback?src=camera1&action=resume
+// - api/playback?src=camera1&action=seek&range=clock=20230101T120000Z-
+// - api/playback?src=camera1&action=scale&scale=2
+func playbackHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	stream := streams.Get(query.Get("src"))
+	if stream == nil {
+		http.Error(w, "stream not found", http.StatusNotFound)
+		return
+	}
+
+	var playback streamer.Playback
+	for _, prod := range stream.Producers() {
+		if p, ok := prod.(streamer.Playback); ok {
+			playback = p
+			break
+		}
+	}
+	if playback == nil {
+		http.Error(w, "stream doesn't support playback", http.StatusBadRequest)
+		return
+	}
+
+	var err error
+
+	switch query.Get("action") {
+	case "pause":
+		err = playback.Pause()
+	case "resume":
+		err = playback.Resume()
+	case "seek":
+		err = playback.Seek(query.Get("range"))
+	case "scale":
+		var scale float64
+		if scale, err = strconv.ParseFloat(query.Get("scale"), 64); err == nil {
+			err = playback.SetScale(scale)
+		}
+	default:
+		http.Error(w, "wrong action", http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		log.Warn().Err(err).Msg("[api] playback")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,125 @@
+package events
+
+import (
+	"encoding/json"
+	"github.com/AlexxIT/go2rtc/cmd/api"
+	"github.com/AlexxIT/go2rtc/pkg/streamer"
+	"net/http"
+	"sync"
+	"time"
+)
+
+func Init() {
+	api.HandleFunc("api/events", apiEvents)
+	api.HandleWS(MsgTypeEvents, wsEvents)
+}
+
+// Event - notification from any source (ex. ONVIF motion) for other modules and API
+type Event struct {
+	Type   string      `json:"type"` // event source type, ex. "onvif"
+	Stream string      `json:"stream,omitempty"`
+	Source string      `json:"source,omitempty"` // source link without user/pass
+	Time   time.Time   `json:"time"`
+	Value  interface{} `json:"value,omitempty"`
+}
+
+type Handler func(event *Event)
+
+const (
+	MsgTypeEvents = "events" // subscribe from WebSocket API
+	MsgTypeEvent  = "event"  // new event for WebSocket API
+)
+
+// HistorySize - how many last events are stored for API
+const HistorySize = 100
+
+// Subscribe handler on all new events, handler should be fast,
+// returns function for unsubscribe
+func Subscribe(handler Handler) func() {
+	mu.Lock()
+	id := nextID
+	nextID++
+	handlers[id] = handler
+	mu.Unlock()
+
+	return func() {
+		mu.Lock()
+		delete(handlers, id)
+		mu.Unlock()
+	}
+}
+
+func Publish(event *Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	mu.Lock()
+	if len(history) >= HistorySize {
+		history = history[1:]
+	}
+	history = append(history, event)
+
+	subs := make([]Handler, 0, len(handlers))
+	for _, handler := range handlers {
+		subs = append(subs, handler)
+	}
+	mu.Unlock()
+
+	for _, handler := range subs {
+		handler(event)
+	}
+}
+
+var handlers = map[int]Handler{}
+var history []*Event
+var nextID int
+var mu sync.Mutex
+
+// apiEvents returns last events: api/events or api/events?src=camera1
+func apiEvents(w http.ResponseWriter, r *http.Request) {
+	src := r.URL.Query().Get("src")
+
+	items := make([]*Event, 0)
+
+	mu.Lock()
+	for _, event := range history {
+		if src == "" || event.Stream == src {
+			items = append(items, event)
+		}
+	}
+	mu.Unlock()
+
+	_ = json.NewEncoder(w).Encode(items)
+}
+
+// wsEvents sends new events to WebSocket: /api/ws or /api/ws?src=camera1
+// repeated "events" messages of the same connection are ignored
+func wsEvents(ctx *api.Context, msg *streamer.Message) {
+	wsMu.Lock()
+	if wsSubscribed[ctx] {
+		wsMu.Unlock()
+		return
+	}
+	wsSubscribed[ctx] = true
+	wsMu.Unlock()
+
+	src := ctx.Request.URL.Query().Get("src")
+
+	unsubscribe := Subscribe(func(event *Event) {
+		if src == "" || event.Stream == src {
+			ctx.Write(&streamer.Message{Type: MsgTypeEvent, Value: event})
+		}
+	})
+
+	ctx.OnClose(func() {
+		unsubscribe()
+
+		wsMu.Lock()
+		delete(wsSubscribed, ctx)
+		wsMu.Unlock()
+	})
+}
+
+var wsSubscribed = map[*api.Context]bool{}
+var wsMu sync.Mutex
@@ -0,0 +1,20 @@
+package events
+
+import (
+	"github.com/AlexxIT/go2rtc/cmd/api"
+	"github.com/AlexxIT/go2rtc/pkg/streamer"
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWSEventsOnce(t *testing.T) {
+	ctx := &api.Context{Request: httptest.NewRequest("GET", "/api/ws", nil)}
+
+	msg := &streamer.Message{Type: MsgTypeEvents}
+	wsEvents(ctx, msg)
+	wsEvents(ctx, msg)
+
+	assert.Len(t, handlers, 1)
+	assert.Len(t, wsSubscribed, 1)
+}
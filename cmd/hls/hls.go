@@ -0,0 +1,261 @@
+package hls
+
+import (
+	"crypto/rand"
+	"fmt"
+	"github.com/AlexxIT/go2rtc/cmd/api"
+	"github.com/AlexxIT/go2rtc/cmd/app"
+	"github.com/AlexxIT/go2rtc/cmd/streams"
+	"github.com/AlexxIT/go2rtc/pkg/hls"
+	"github.com/AlexxIT/go2rtc/pkg/mp4"
+	"github.com/AlexxIT/go2rtc/pkg/streamer"
+	"github.com/rs/zerolog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+func Init() {
+	log = app.GetLogger("hls")
+
+	streams.HandleFunc("hls", func(url string) (streamer.Producer, error) {
+		return hls.Dial(url[4:]) // remove `hls:`
+	})
+
+	api.HandleFunc("api/stream.m3u8", handlerStream)
+	api.HandleFunc("api/hls/playlist.m3u8", handlerPlaylist)
+	api.HandleFunc("api/hls/init.mp4", handlerInit)
+	api.HandleFunc("api/hls/segment.m4s", handlerSegment)
+	api.HandleFunc("api/hls/part.m4s", handlerPart)
+}
+
+var log zerolog.Logger
+
+// Session - one viewer with own consumer, removed when the viewer stops
+// polling the playlist
+type Session struct {
+	playlist *hls.Playlist
+	alive    *time.Timer
+}
+
+// keepalive - session lifetime without requests
+const keepalive = 15 * time.Second
+
+var sessions = map[string]*Session{}
+var sessionsMu sync.Mutex
+
+func handlerStream(w http.ResponseWriter, r *http.Request) {
+	// CORS important for Chromecast
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	if r.Method == "OPTIONS" {
+		w.Header().Set("Access-Control-Allow-Methods", "GET")
+		return
+	}
+
+	src := r.URL.Query().Get("src")
+	stream := streams.GetOrNew(src)
+	if stream == nil {
+		http.Error(w, "stream not found", http.StatusNotFound)
+		return
+	}
+
+	var playlist *hls.Playlist
+	if _, ok := r.URL.Query()["ll"]; ok {
+		playlist = hls.NewLowLatencyPlaylist(newID())
+	} else {
+		playlist = hls.NewPlaylist(newID())
+	}
+
+	cons := &mp4.Consumer{}
+	cons.UserAgent = r.UserAgent()
+	cons.RemoteAddr = r.RemoteAddr
+	cons.Listen(playlist.Handle)
+
+	if err := stream.AddConsumer(cons); err != nil {
+		log.Error().Err(err).Msg("[api.hls] add consumer")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var err error
+	if playlist.Init, err = cons.Init(); err != nil {
+		stream.RemoveConsumer(cons)
+		log.Error().Err(err).Msg("[api.hls] init")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	session := &Session{playlist: playlist}
+	session.alive = time.AfterFunc(keepalive, func() {
+		sessionsMu.Lock()
+		delete(sessions, playlist.ID)
+		sessionsMu.Unlock()
+
+		stream.RemoveConsumer(cons)
+
+		log.Trace().Str("id", playlist.ID).Msg("[api.hls] close session")
+	})
+
+	sessionsMu.Lock()
+	sessions[playlist.ID] = session
+	sessionsMu.Unlock()
+
+	log.Trace().Str("id", playlist.ID).Msg("[api.hls] new session")
+
+	// codecs from: video/mp4; codecs="avc1.640029,mp4a.40.2"
+	codecs := cons.MimeType()
+	if i := strings.IndexByte(codecs, '"'); i > 0 {
+		codecs = strings.Trim(codecs[i:], `"`)
+	}
+
+	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+	_, _ = w.Write(hls.MasterPlaylist("hls/playlist.m3u8?id="+playlist.ID, codecs))
+}
+
+func handlerPlaylist(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	session := getSession(r)
+	if session == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	playlist := session.playlist
+
+	// first segment can be ready only after several seconds
+	if !playlist.Wait(keepalive) {
+		http.Error(w, "no segments", http.StatusNotFound)
+		return
+	}
+
+	query := r.URL.Query()
+
+	// Low-Latency HLS blocking playlist reload
+	if s := query.Get("_HLS_msn"); s != "" {
+		msn, err := strconv.Atoi(s)
+		if err != nil || msn < 0 {
+			http.Error(w, "wrong _HLS_msn", http.StatusBadRequest)
+			return
+		}
+
+		part := -1
+		if s = query.Get("_HLS_part"); s != "" {
+			if part, err = strconv.Atoi(s); err != nil || part < 0 {
+				http.Error(w, "wrong _HLS_part", http.StatusBadRequest)
+				return
+			}
+		}
+
+		// request too far in the future
+		if msn > playlist.NextSequence()+2 {
+			http.Error(w, "wrong _HLS_msn", http.StatusBadRequest)
+			return
+		}
+
+		if !playlist.WaitPart(msn, part, 3*playlist.TargetDuration()) {
+			http.Error(w, "timeout", http.StatusServiceUnavailable)
+			return
+		}
+	}
+
+	// Low-Latency HLS delta update
+	skip := query.Get("_HLS_skip")
+
+	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+	_, _ = w.Write(playlist.Marshal(skip == "YES" || skip == "v2"))
+}
+
+func handlerInit(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	session := getSession(r)
+	if session == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "video/mp4")
+	_, _ = w.Write(session.playlist.Init)
+}
+
+func handlerSegment(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	session := getSession(r)
+	if session == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	n, _ := strconv.Atoi(r.URL.Query().Get("n"))
+	data := session.playlist.Segment(n)
+	if data == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "video/iso.segment")
+	_, _ = w.Write(data)
+}
+
+// handlerPart - Low-Latency HLS partial segment, waits for the part from the preload hint
+func handlerPart(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	session := getSession(r)
+	if session == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	query := r.URL.Query()
+	n, err := strconv.Atoi(query.Get("n"))
+	if err != nil || n < 0 {
+		http.Error(w, "wrong n", http.StatusBadRequest)
+		return
+	}
+	p, err := strconv.Atoi(query.Get("p"))
+	if err != nil || p < 0 {
+		http.Error(w, "wrong p", http.StatusBadRequest)
+		return
+	}
+
+	playlist := session.playlist
+	if n > playlist.NextSequence()+1 || !playlist.WaitPart(n, p, 3*playlist.TargetDuration()) {
+		http.NotFound(w, r)
+		return
+	}
+
+	data := playlist.Part(n, p)
+	if data == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "video/iso.segment")
+	_, _ = w.Write(data)
+}
+
+// getSession by id from query and prolong its life
+func getSession(r *http.Request) *Session {
+	id := r.URL.Query().Get("id")
+
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+
+	session := sessions[id]
+	if session != nil {
+		session.alive.Reset(keepalive)
+	}
+	return session
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("%x", b)
+}
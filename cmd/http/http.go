@@ -0,0 +1,125 @@
+package http
+
+import (
+	"bufio"
+	"errors"
+	"github.com/AlexxIT/go2rtc/cmd/streams"
+	"github.com/AlexxIT/go2rtc/pkg/hls"
+	"github.com/AlexxIT/go2rtc/pkg/mjpeg"
+	"github.com/AlexxIT/go2rtc/pkg/mpegts"
+	"github.com/AlexxIT/go2rtc/pkg/rtmp"
+	"github.com/AlexxIT/go2rtc/pkg/streamer"
+	"github.com/gorilla/websocket"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+func Init() {
+	streams.HandleFunc("http", handleHTTP)
+	streams.HandleFunc("https", handleHTTP)
+
+	// WebSocket sources support only FLV stream
+	streams.HandleFunc("ws", handleWS)
+	streams.HandleFunc("wss", handleWS)
+}
+
+// client - timeouts for connect and response headers, without total timeout,
+// because body of the stream never ends
+var client = &http.Client{
+	Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: 5 * time.Second}).DialContext,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ResponseHeaderTimeout: 10 * time.Second,
+	},
+}
+
+// handleHTTP selects producer by Content-Type of the response
+func handleHTTP(url string) (streamer.Producer, error) {
+	if hls.IsPlaylist(url, "") {
+		return hls.Dial(url)
+	}
+
+	res, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		_ = res.Body.Close()
+		return nil, errors.New("wrong response status: " + res.Status)
+	}
+
+	// some cameras send wrong content type, so check also the first bytes
+	rd := bufio.NewReader(res.Body)
+	b, _ := rd.Peek(3)
+
+	ct := res.Header.Get("Content-Type")
+	if i := strings.IndexByte(ct, ';'); i > 0 {
+		ct = ct[:i]
+	}
+
+	body := &readCloser{Reader: rd, Closer: res.Body}
+
+	switch {
+	case ct == "video/x-flv" || string(b) == "FLV":
+		return rtmp.OpenFLV(url, "HTTP-FLV", body)
+	case hls.IsPlaylist(url, ct):
+		_ = res.Body.Close()
+		return hls.Dial(url)
+	case ct == "video/mp2t" || (len(b) > 0 && b[0] == mpegts.SyncByte):
+		return mpegts.Open(url, "HTTP-TS", body)
+	case ct == "multipart/x-mixed-replace":
+		return mjpeg.Open(url, body)
+	}
+
+	_ = res.Body.Close()
+	return nil, errors.New("unsupported content type: " + ct)
+}
+
+func handleWS(url string) (streamer.Producer, error) {
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return rtmp.OpenFLV(url, "WS-FLV", &wsReader{conn: conn})
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// wsReader - binary messages of WebSocket connection as one stream
+type wsReader struct {
+	conn *websocket.Conn
+	r    io.Reader
+}
+
+func (w *wsReader) Read(p []byte) (n int, err error) {
+	for {
+		if w.r == nil {
+			if _, w.r, err = w.conn.NextReader(); err != nil {
+				return
+			}
+		}
+
+		n, err = w.r.Read(p)
+		if err == io.EOF {
+			w.r = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return
+	}
+}
+
+func (w *wsReader) Close() error {
+	return w.conn.Close()
+}
@@ -33,13 +33,17 @@ func handlerKeyframe(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	exit := make(chan []byte)
+	exit := make(chan []byte, 1)
 
 	cons := &mp4.Consumer{}
 	cons.Listen(func(msg interface{}) {
 		switch msg := msg.(type) {
 		case []byte:
-			exit <- msg
+			// only first fragment is needed, skip others
+			select {
+			case exit <- msg:
+			default:
+			}
 		}
 	})
 
@@ -82,7 +86,7 @@ func handlerMP4(w http.ResponseWriter, r *http.Request) {
 
 	exit := make(chan struct{})
 
-	cons := &mp4.Consumer{}
+	cons := &mp4.Consumer{WallClock: true}
 	cons.Listen(func(msg interface{}) {
 		switch msg := msg.(type) {
 		case []byte:
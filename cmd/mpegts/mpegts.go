@@ -0,0 +1,73 @@
+package mpegts
+
+import (
+	"github.com/AlexxIT/go2rtc/cmd/api"
+	"github.com/AlexxIT/go2rtc/cmd/app"
+	"github.com/AlexxIT/go2rtc/cmd/streams"
+	"github.com/AlexxIT/go2rtc/pkg/mpegts"
+	"github.com/AlexxIT/go2rtc/pkg/streamer"
+	"github.com/rs/zerolog"
+	"net/http"
+)
+
+func Init() {
+	log = app.GetLogger("mpegts")
+
+	streams.HandleFunc("udp", handleTS)
+	streams.HandleFunc("tcp", handleTS)
+
+	api.HandleFunc("api/stream.ts", apiHandle)
+}
+
+var log zerolog.Logger
+
+func handleTS(url string) (streamer.Producer, error) {
+	return mpegts.Dial(url)
+}
+
+func apiHandle(w http.ResponseWriter, r *http.Request) {
+	src := r.URL.Query().Get("src")
+	stream := streams.GetOrNew(src)
+	if stream == nil {
+		http.Error(w, "stream not found", http.StatusNotFound)
+		return
+	}
+
+	exit := make(chan struct{}, 1)
+
+	cons := &mpegts.Consumer{
+		RemoteAddr: r.RemoteAddr,
+		UserAgent:  r.UserAgent(),
+	}
+	cons.Listen(func(msg interface{}) {
+		if data, ok := msg.([]byte); ok {
+			if _, err := w.Write(data); err != nil {
+				select {
+				case exit <- struct{}{}:
+				default:
+				}
+				return
+			}
+			if f, ok := w.(http.Flusher); ok {
+				f.Flush()
+			}
+		}
+	})
+
+	w.Header().Set("Content-Type", "video/mp2t")
+
+	if err := stream.AddConsumer(cons); err != nil {
+		log.Error().Err(err).Msg("[api.ts] add consumer")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	select {
+	case <-exit:
+	case <-r.Context().Done():
+	}
+
+	stream.RemoveConsumer(cons)
+
+	log.Trace().Msg("[api.ts] close")
+}
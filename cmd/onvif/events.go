@@ -0,0 +1,79 @@
+package onvif
+
+import (
+	"errors"
+	"github.com/AlexxIT/go2rtc/cmd/events"
+	"github.com/AlexxIT/go2rtc/pkg/onvif"
+	"net/url"
+	"time"
+)
+
+const (
+	SubscriptionTTL = time.Minute      // camera terminates subscription without renew
+	PullTimeout     = 10 * time.Second // long polling timeout for PullMessages
+	RetryTimeout    = 10 * time.Second // reconnect delay after errors
+)
+
+// eventsWorker keeps PullPoint subscription for the stream camera
+func eventsWorker(name string) {
+	for {
+		err := pullEvents(name)
+		log.Warn().Err(err).Str("stream", name).Msg("[onvif] events")
+		time.Sleep(RetryTimeout)
+	}
+}
+
+func pullEvents(name string) error {
+	rawURL := onvifURL(name)
+	if rawURL == "" {
+		return errors.New("can't find onvif device")
+	}
+
+	client, err := onvif.NewClient(rawURL)
+	if err != nil {
+		return err
+	}
+
+	sub, err := client.CreatePullPointSubscription(SubscriptionTTL)
+	if err != nil {
+		return err
+	}
+	defer sub.Unsubscribe()
+
+	log.Debug().Str("stream", name).Msg("[onvif] subscribed to events")
+
+	source := sourceURL(rawURL)
+	renew := time.Now().Add(SubscriptionTTL / 2)
+
+	for {
+		items, err := sub.PullMessages(PullTimeout, 100)
+		if err != nil {
+			return err
+		}
+
+		for _, item := range items {
+			log.Trace().Str("stream", name).Stringer("event", item).Msg("[onvif] event")
+
+			events.Publish(&events.Event{
+				Type: "onvif", Stream: name, Source: source, Time: item.Time, Value: item,
+			})
+		}
+
+		if time.Now().After(renew) {
+			if err = sub.Renew(SubscriptionTTL); err != nil {
+				return err
+			}
+			renew = time.Now().Add(SubscriptionTTL / 2)
+		}
+	}
+}
+
+// sourceURL removes user/pass from the link
+func sourceURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	u.User = nil
+	return u.String()
+}
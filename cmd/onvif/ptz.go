@@ -0,0 +1,252 @@
+package onvif
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/AlexxIT/go2rtc/cmd/api"
+	"github.com/AlexxIT/go2rtc/cmd/streams"
+	"github.com/AlexxIT/go2rtc/pkg/onvif"
+	"github.com/AlexxIT/go2rtc/pkg/streamer"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const MsgTypePTZ = "ptz"
+
+// apiPTZ controls camera of the stream:
+// - api/ptz?src=camera1&action=move&x=0.5&y=0&zoom=0
+// - api/ptz?src=camera1&action=stop
+// - api/ptz?src=camera1&action=absolute&x=0&y=0&zoom=0.5
+// - api/ptz?src=camera1&action=presets
+// - api/ptz?src=camera1&action=goto&preset=1
+// - api/ptz?src=camera1&action=set&name=door
+// - api/ptz?src=camera1&action=home
+func apiPTZ(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	v, err := ptzAction(query.Get("src"), query.Get("action"), query.Get)
+	if err != nil {
+		log.Warn().Err(err).Msg("[api.ptz]")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if v != nil {
+		_ = json.NewEncoder(w).Encode(v)
+	}
+}
+
+// wsPTZ - low latency control from /api/ws?src=camera1
+// {"type":"ptz","value":{"action":"move","x":0.5,"y":0,"zoom":0}}
+func wsPTZ(ctx *api.Context, msg *streamer.Message) {
+	params, _ := msg.Value.(map[string]interface{})
+
+	get := func(key string) string {
+		if v, ok := params[key]; ok {
+			return fmt.Sprint(v)
+		}
+		return ""
+	}
+
+	src := ctx.Request.URL.Query().Get("src")
+
+	v, err := ptzAction(src, get("action"), get)
+	if err != nil {
+		log.Warn().Err(err).Msg("[api.ptz]")
+		ctx.Error(err)
+		return
+	}
+
+	if v != nil {
+		ctx.Write(&streamer.Message{Type: MsgTypePTZ, Value: v})
+	}
+}
+
+func ptzAction(src, action string, get func(key string) string) (interface{}, error) {
+	device, err := getPTZ(src)
+	if err != nil {
+		return nil, err
+	}
+
+	// client isn't safe for concurrent requests
+	device.mu.Lock()
+	defer device.mu.Unlock()
+
+	client := device.client
+	profile := device.profile
+
+	switch action {
+	case "move":
+		err = client.ContinuousMove(profile, atof(get("x")), atof(get("y")), atof(get("zoom")))
+	case "stop":
+		err = client.Stop(profile)
+	case "absolute":
+		var zoom *float64
+		if s := get("zoom"); s != "" {
+			f := atof(s)
+			zoom = &f
+		}
+		err = client.AbsoluteMove(profile, atof(get("x")), atof(get("y")), zoom)
+	case "presets":
+		return client.GetPresets(profile)
+	case "goto":
+		err = client.GotoPreset(profile, get("preset"))
+	case "set":
+		var token string
+		if token, err = client.SetPreset(profile, get("name")); err == nil {
+			return &onvif.Preset{Token: token, Name: get("name")}, nil
+		}
+	case "home":
+		err = client.GotoHomePosition(profile)
+	case "sethome":
+		err = client.SetHomePosition(profile)
+	default:
+		return nil, errors.New("ptz: wrong action: " + action)
+	}
+
+	if err != nil {
+		// maybe camera was changed, so resolve it again next time
+		ptzMu.Lock()
+		if ptzDevices[src] == device {
+			delete(ptzDevices, src)
+		}
+		ptzMu.Unlock()
+	}
+
+	return nil, err
+}
+
+// PTZRetry - device with error isn't resolved again during this time
+const PTZRetry = 30 * time.Second
+
+type ptzDevice struct {
+	client  *onvif.Client
+	profile string
+
+	err     error
+	errTime time.Time
+	mu      sync.Mutex // one resolve or request at a time for the device
+}
+
+var ptzDevices = map[string]*ptzDevice{}
+var ptzMu sync.Mutex
+
+// getPTZ resolves ONVIF device of the stream and profile with PTZ support,
+// offline device doesn't block other devices
+func getPTZ(src string) (*ptzDevice, error) {
+	// cache only streams from config, other links are resolved for each request
+	if strings.Contains(src, "://") || streams.Get(src) == nil {
+		device := &ptzDevice{}
+		if err := device.resolve(src); err != nil {
+			return nil, err
+		}
+		return device, nil
+	}
+
+	ptzMu.Lock()
+	device := ptzDevices[src]
+	if device == nil {
+		device = &ptzDevice{}
+		ptzDevices[src] = device
+	}
+	ptzMu.Unlock()
+
+	device.mu.Lock()
+	defer device.mu.Unlock()
+
+	if device.client == nil && (device.err == nil || time.Since(device.errTime) > PTZRetry) {
+		if device.err = device.resolve(src); device.err != nil {
+			device.errTime = time.Now()
+		}
+	}
+
+	if device.client == nil {
+		return nil, device.err
+	}
+	return device, nil
+}
+
+func (d *ptzDevice) resolve(src string) error {
+	rawURL := onvifURL(src)
+	if rawURL == "" {
+		return errors.New("ptz: can't find onvif device for: " + src)
+	}
+
+	client, err := onvif.NewClient(rawURL)
+	if err != nil {
+		return err
+	}
+
+	profiles, err := client.GetProfiles()
+	if err != nil {
+		return err
+	}
+
+	u, _ := url.Parse(rawURL)
+	query := u.Query()
+
+	var profile *onvif.Profile
+	if query.Get("profile") != "" || query.Get("resolution") != "" {
+		profile = onvif.FindProfile(profiles, query.Get("profile"), query.Get("resolution"))
+	} else {
+		for _, p := range profiles {
+			if p.PTZ {
+				profile = p
+				break
+			}
+		}
+	}
+	if profile == nil {
+		return errors.New("ptz: can't find profile")
+	}
+
+	d.client = client
+	d.profile = profile.Token
+	return nil
+}
+
+// onvifURL from onvif source of the stream or from RTSP source with user/pass
+func onvifURL(src string) string {
+	if strings.HasPrefix(src, "onvif://") {
+		return src
+	}
+
+	stream := streams.Get(src)
+	if stream == nil {
+		return ""
+	}
+
+	sources := stream.Sources()
+
+	for _, source := range sources {
+		if strings.HasPrefix(source, "onvif://") {
+			if i := strings.IndexByte(source, '#'); i > 0 {
+				source = source[:i]
+			}
+			return source
+		}
+	}
+
+	// many cameras have ONVIF on default HTTP port with the same user/pass
+	for _, source := range sources {
+		if strings.HasPrefix(source, "rtsp://") {
+			u, err := url.Parse(source)
+			if err != nil || u.User == nil {
+				continue
+			}
+			return "onvif://" + u.User.String() + "@" + u.Hostname()
+		}
+	}
+
+	return ""
+}
+
+func atof(s string) float64 {
+	f, _ := strconv.ParseFloat(s, 64)
+	return f
+}
@@ -0,0 +1,24 @@
+package onvif
+
+import (
+	"github.com/AlexxIT/go2rtc/cmd/streams"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPTZCache(t *testing.T) {
+	streams.New("camera_ptz", "rtsp://192.168.1.123/stream1")
+	defer streams.Delete("camera_ptz")
+
+	// links and unknown names are not cached
+	_, err := getPTZ("camera404")
+	assert.NotNil(t, err)
+	_, err = getPTZ("rtsp://192.168.1.123/stream1")
+	assert.NotNil(t, err)
+	assert.Len(t, ptzDevices, 0)
+
+	// stream without user/pass has no onvif device, error is cached for retry
+	_, err = getPTZ("camera_ptz")
+	assert.EqualError(t, err, "ptz: can't find onvif device for: camera_ptz")
+	assert.Len(t, ptzDevices, 1)
+}
@@ -0,0 +1,240 @@
+package onvif
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"github.com/AlexxIT/go2rtc/cmd/api"
+	"github.com/AlexxIT/go2rtc/cmd/rtsp"
+	"github.com/AlexxIT/go2rtc/cmd/streams"
+	"github.com/AlexxIT/go2rtc/pkg/h264"
+	"github.com/AlexxIT/go2rtc/pkg/h265"
+	"github.com/AlexxIT/go2rtc/pkg/onvif"
+	"github.com/AlexxIT/go2rtc/pkg/streamer"
+	"github.com/deepch/vdk/codec/h264parser"
+	"github.com/deepch/vdk/codec/h265parser"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// initServer - go2rtc as ONVIF Profile S device for NVRs, one profile for
+// each stream with link to the RTSP server
+func initServer() {
+	api.HandleFunc(onvif.DevicePath, serverHandler)
+	api.HandleFunc(onvif.MediaPath, serverHandler)
+
+	go discoveryWorker()
+}
+
+var deviceInfo = &onvif.DeviceInformation{
+	Manufacturer:    "AlexxIT",
+	Model:           "go2rtc",
+	FirmwareVersion: "1.0",
+	SerialNumber:    deviceID(),
+	HardwareId:      "go2rtc",
+}
+
+var deviceScopes = []string{
+	"onvif://www.onvif.org/type/video_encoder",
+	"onvif://www.onvif.org/Profile/Streaming",
+	"onvif://www.onvif.org/name/go2rtc",
+	"onvif://www.onvif.org/hardware/go2rtc",
+}
+
+func serverHandler(w http.ResponseWriter, r *http.Request) {
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return
+	}
+
+	action := onvif.GetRequestAction(b)
+
+	log.Trace().Msgf("[onvif] server request %s", action)
+
+	var body string
+
+	switch action {
+	case "GetSystemDateAndTime":
+		body = onvif.SystemDateAndTimeResponse(time.Now())
+	case "GetCapabilities":
+		body = onvif.CapabilitiesResponse(r.Host)
+	case "GetServices":
+		body = onvif.ServicesResponse(r.Host)
+	case "GetDeviceInformation":
+		body = onvif.DeviceInformationResponse(deviceInfo)
+	case "GetScopes":
+		body = onvif.ScopesResponse(deviceScopes)
+	case "GetProfiles":
+		body = onvif.ProfilesResponse(serverProfiles())
+	case "GetVideoSources":
+		body = onvif.VideoSourcesResponse(serverProfiles())
+	case "GetProfile":
+		name := onvif.GetRequestValue(b, "ProfileToken")
+		stream := streams.Get(name)
+		if stream == nil {
+			serverFault(w, "NoProfile", "profile not exists")
+			return
+		}
+		body = onvif.ProfileResponse(streamProfile(name, stream))
+	case "GetStreamUri":
+		name := onvif.GetRequestValue(b, "ProfileToken")
+		if streams.Get(name) == nil {
+			serverFault(w, "NoProfile", "profile not exists")
+			return
+		}
+		if rtsp.Port == "" {
+			serverFault(w, "StreamSetupFailed", "RTSP server disabled")
+			return
+		}
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		uri := "rtsp://" + net.JoinHostPort(host, rtsp.Port) + "/" + url.PathEscape(name)
+		body = onvif.StreamUriResponse(uri)
+	default:
+		log.Debug().Msgf("[onvif] server unsupported action: %s", action)
+		serverFault(w, "ActionNotSupported", "action not supported: "+action)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/soap+xml; charset=utf-8")
+	_, _ = w.Write(onvif.ServerEnvelope(body))
+}
+
+func serverFault(w http.ResponseWriter, code, reason string) {
+	w.Header().Set("Content-Type", "application/soap+xml; charset=utf-8")
+	w.WriteHeader(http.StatusBadRequest)
+	_, _ = w.Write(onvif.FaultEnvelope(code, reason))
+}
+
+// serverStreams - sorted names of streams, skip streams created from links by API
+func serverStreams() []string {
+	var names []string
+	for name := range streams.All() {
+		if strings.Contains(name, "://") {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// serverProfiles - one profile for each stream from serverStreams
+func serverProfiles() []*onvif.Profile {
+	var profiles []*onvif.Profile
+	for _, name := range serverStreams() {
+		if stream := streams.Get(name); stream != nil {
+			profiles = append(profiles, streamProfile(name, stream))
+		}
+	}
+	return profiles
+}
+
+// streamProfile - codec and resolution from the first video track of started
+// producers, idle streams are not probed and get default H264 1920x1080
+func streamProfile(name string, stream *streams.Stream) *onvif.Profile {
+	profile := &onvif.Profile{Token: name, Name: name, Encoding: "H264", Width: 1920, Height: 1080}
+
+	for _, prod := range stream.Producers() {
+		for _, media := range prod.GetMedias() {
+			if media.Kind != streamer.KindVideo || media.Direction != streamer.DirectionSendonly {
+				continue
+			}
+
+			for _, codec := range media.Codecs {
+				switch codec.Name {
+				case streamer.CodecH264:
+					profile.Encoding = "H264"
+					if sps, _ := h264.GetParameterSet(codec.FmtpLine); len(sps) > 0 {
+						if info, err := h264parser.ParseSPS(sps); err == nil && info.Width > 0 {
+							profile.Width, profile.Height = int(info.Width), int(info.Height)
+						}
+					}
+				case streamer.CodecH265:
+					profile.Encoding = "H265"
+					if _, sps, _ := h265.GetParameterSet(codec.FmtpLine); len(sps) > 0 {
+						if info, err := h265parser.ParseSPS(sps); err == nil && info.Width > 0 {
+							profile.Width, profile.Height = int(info.Width), int(info.Height)
+						}
+					}
+				case streamer.CodecJPEG:
+					profile.Encoding = "JPEG"
+				default:
+					continue
+				}
+				return profile
+			}
+		}
+	}
+
+	return profile
+}
+
+// discoveryWorker answers WS-Discovery Probe messages from NVRs
+func discoveryWorker() {
+	addr, err := net.ResolveUDPAddr("udp4", onvif.DiscoveryAddress)
+	if err != nil {
+		return
+	}
+
+	conn, err := net.ListenMulticastUDP("udp4", nil, addr)
+	if err != nil {
+		log.Warn().Err(err).Msg("[onvif] discovery listen")
+		return
+	}
+
+	log.Info().Str("addr", onvif.DiscoveryAddress).Msg("[onvif] discovery listen")
+
+	address := "urn:uuid:" + deviceID()
+
+	b := make([]byte, 64*1024)
+	for {
+		n, remote, err := conn.ReadFromUDP(b)
+		if err != nil {
+			log.Warn().Err(err).Msg("[onvif] discovery read")
+			return
+		}
+
+		id := onvif.ParseProbe(b[:n])
+		if id == "" {
+			continue
+		}
+
+		ip := localIP(remote)
+		if ip == "" || api.Port == "" {
+			continue
+		}
+
+		log.Trace().Stringer("addr", remote).Msg("[onvif] discovery probe")
+
+		xaddr := "http://" + net.JoinHostPort(ip, api.Port) + onvif.DevicePath
+		msg := onvif.ProbeMatchesMessage(id, address, []string{xaddr}, deviceScopes)
+		if _, err = conn.WriteToUDP(msg, remote); err != nil {
+			log.Warn().Err(err).Msg("[onvif] discovery write")
+		}
+	}
+}
+
+// localIP - address of the interface that is used for the remote host
+func localIP(remote *net.UDPAddr) string {
+	conn, err := net.DialUDP("udp4", nil, remote)
+	if err != nil {
+		return ""
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP.String()
+}
+
+// deviceID - same uuid for the same host between restarts
+func deviceID() string {
+	hostname, _ := os.Hostname()
+	b := sha1.Sum([]byte("go2rtc:" + hostname))
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
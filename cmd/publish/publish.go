@@ -0,0 +1,137 @@
+package publish
+
+import (
+	"errors"
+	"fmt"
+	"github.com/AlexxIT/go2rtc/cmd/app"
+	"github.com/AlexxIT/go2rtc/cmd/streams"
+	"github.com/AlexxIT/go2rtc/pkg/streamer"
+	"github.com/rs/zerolog"
+	"strings"
+	"time"
+)
+
+func Init() {
+	var cfg struct {
+		Mod map[string]interface{} `yaml:"publish"`
+	}
+
+	app.LoadConfig(&cfg)
+
+	log = app.GetLogger("publish")
+
+	for name, item := range cfg.Mod {
+		switch item := item.(type) {
+		case string:
+			go worker(name, item)
+		case []interface{}:
+			for _, v := range item {
+				if url, ok := v.(string); ok {
+					go worker(name, url)
+				} else {
+					log.Error().Str("stream", name).Msgf("[publish] wrong url: %v", v)
+				}
+			}
+		default:
+			log.Error().Str("stream", name).Msgf("[publish] wrong url: %v", item)
+		}
+	}
+}
+
+// Consumer - stream consumer that sends media to the remote server
+type Consumer interface {
+	streamer.Consumer
+	// Start connects to the server and blocks until connection is closed
+	Start() error
+	Stop() error
+}
+
+type Handler func(url string) (Consumer, error)
+
+func HandleFunc(scheme string, handler Handler) {
+	if handlers == nil {
+		handlers = make(map[string]Handler)
+	}
+	handlers[scheme] = handler
+}
+
+// RetryMin and RetryMax - reconnect delay limits, delay doubles on each fail
+const (
+	RetryMin = time.Second
+	RetryMax = time.Minute
+)
+
+// internal
+
+var log zerolog.Logger
+var handlers map[string]Handler
+
+func worker(name, url string) {
+	handler, err := getHandler(url)
+	if err != nil {
+		log.Error().Err(err).Str("stream", name).Msg("[publish]")
+		return
+	}
+
+	var delay time.Duration
+
+	for {
+		ts := time.Now()
+
+		err = publish(name, url, handler)
+
+		delay = retryDelay(delay, time.Since(ts))
+
+		log.Warn().Err(err).Str("stream", name).Str("url", url).
+			Msgf("[publish] reconnect in %s", delay)
+
+		time.Sleep(delay)
+	}
+}
+
+// retryDelay returns delay before next reconnect from the previous delay and
+// the time publishing was working, delay resets if it was working long enough
+func retryDelay(delay, worked time.Duration) time.Duration {
+	if delay == 0 || worked > RetryMax {
+		return RetryMin
+	}
+	if delay *= 2; delay > RetryMax {
+		return RetryMax
+	}
+	return delay
+}
+
+func publish(name, url string, handler Handler) error {
+	stream := streams.Get(name)
+	if stream == nil {
+		return errors.New("stream not found")
+	}
+
+	cons, err := handler(url)
+	if err != nil {
+		return err
+	}
+
+	if err = stream.AddConsumer(cons); err != nil {
+		_ = cons.Stop()
+		return err
+	}
+
+	log.Debug().Str("stream", name).Str("url", url).Msg("[publish] start")
+
+	err = cons.Start()
+
+	stream.RemoveConsumer(cons)
+	_ = cons.Stop()
+
+	return err
+}
+
+func getHandler(url string) (Handler, error) {
+	if i := strings.IndexByte(url, ':'); i > 0 {
+		if handler := handlers[url[:i]]; handler != nil {
+			return handler, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported scheme: %s", url)
+}
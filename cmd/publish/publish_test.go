@@ -0,0 +1,26 @@
+package publish
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRetryDelay(t *testing.T) {
+	var delays []time.Duration
+
+	var delay time.Duration
+	for i := 0; i < 8; i++ {
+		delay = retryDelay(delay, 0)
+		delays = append(delays, delay)
+	}
+
+	assert.Equal(t, []time.Duration{
+		time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second,
+		16 * time.Second, 32 * time.Second, time.Minute, time.Minute,
+	}, delays)
+
+	// short publish doesn't reset delay, long one does
+	assert.Equal(t, time.Minute, retryDelay(time.Minute, RetryMax))
+	assert.Equal(t, RetryMin, retryDelay(time.Minute, RetryMax+time.Second))
+}
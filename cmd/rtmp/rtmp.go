@@ -1,19 +1,249 @@
 package rtmp
 
 import (
+	"crypto/subtle"
+	"github.com/AlexxIT/go2rtc/cmd/api"
+	"github.com/AlexxIT/go2rtc/cmd/app"
+	"github.com/AlexxIT/go2rtc/cmd/publish"
 	"github.com/AlexxIT/go2rtc/cmd/streams"
 	"github.com/AlexxIT/go2rtc/pkg/rtmp"
 	"github.com/AlexxIT/go2rtc/pkg/streamer"
+	"github.com/deepch/vdk/format/flv/flvio"
+	vdk "github.com/deepch/vdk/format/rtmp"
+	"github.com/rs/zerolog"
+	"net/http"
 )
 
 func Init() {
-	streams.HandleFunc("rtmp", handle)
+	var conf struct {
+		Mod struct {
+			Listen       string            `yaml:"listen"`
+			Keys         map[string]string `yaml:"keys"`
+			AllowPublish bool              `yaml:"allow_publish"` // without keys
+		} `yaml:"rtmp"`
+	}
+
+	app.LoadConfig(&conf)
+
+	log = app.GetLogger("rtmp")
+
+	streams.HandleFunc("rtmp", streamsHandle)
+
+	// HTTP-FLV and WS-FLV clients support (ex. flv.js)
+	api.HandleFunc("api/stream.flv", apiHandle)
+
+	// RTMP publish support
+	publish.HandleFunc("rtmp", publishHandle)
+	publish.HandleFunc("rtmps", publishHandle)
+
+	// RTMP server support
+	address := conf.Mod.Listen
+	if address != "" {
+		keys = conf.Mod.Keys
+		allowPublish = conf.Mod.AllowPublish
+
+		go worker(address)
+	}
 }
 
-func handle(url string) (streamer.Producer, error) {
+var log zerolog.Logger
+
+// keys - stream name => stream key for publish, streams without key
+// can be published only with allowPublish
+var keys map[string]string
+var allowPublish bool
+
+func streamsHandle(url string) (streamer.Producer, error) {
 	conn := rtmp.NewClient(url)
 	if err := conn.Dial(); err != nil {
 		return nil, err
 	}
 	return conn, nil
 }
+
+func publishHandle(url string) (publish.Consumer, error) {
+	return rtmp.NewPublisher(url)
+}
+
+func worker(address string) {
+	srv := &vdk.Server{Addr: address, HandleConn: handle}
+
+	log.Info().Str("addr", address).Msg("[rtmp] listen")
+
+	if err := srv.ListenAndServe(); err != nil {
+		log.Error().Err(err).Msg("[rtmp] listen")
+	}
+}
+
+func handle(conn *vdk.Conn) {
+	defer conn.Close()
+
+	// callback is called only for publish command
+	var publish bool
+	conn.OnPlayOrPublish = func(string, flvio.AMFMap) error {
+		publish = true
+		return nil
+	}
+
+	// handshake, connect and play or publish commands
+	if err := conn.Prepare(); err != nil {
+		log.Debug().Err(err).Msg("[rtmp] prepare")
+		return
+	}
+
+	if publish {
+		handlePublish(conn)
+	} else {
+		handlePlay(conn)
+	}
+}
+
+// handlePublish - stream from OBS or camera: rtmp://host/app/{stream_name}?key=...
+func handlePublish(conn *vdk.Conn) {
+	name := rtmp.StreamName(conn)
+
+	stream := streams.Get(name)
+	if stream == nil {
+		log.Warn().Str("stream", name).Msg("[rtmp] stream not found")
+		return
+	}
+
+	if key, ok := keys[name]; ok {
+		if subtle.ConstantTimeCompare([]byte(conn.URL.Query().Get("key")), []byte(key)) != 1 {
+			log.Warn().Str("stream", name).Msg("[rtmp] wrong stream key")
+			return
+		}
+	} else if !allowPublish {
+		log.Warn().Str("stream", name).Msg("[rtmp] publish not allowed")
+		return
+	}
+
+	log.Debug().Str("stream", name).Msg("[rtmp] new producer")
+
+	prod, err := rtmp.Accept(conn)
+	if err != nil {
+		log.Warn().Err(err).Str("stream", name).Msg("[rtmp] accept")
+		return
+	}
+
+	stream.AddProducer(prod)
+
+	if err = prod.Handle(); err != nil {
+		log.Debug().Err(err).Str("stream", name).Msg("[rtmp] handle")
+	}
+
+	stream.RemoveProducer(prod)
+
+	log.Debug().Str("stream", name).Msg("[rtmp] disconnect")
+}
+
+func handlePlay(conn *vdk.Conn) {
+	name := rtmp.StreamName(conn)
+
+	stream := streams.Get(name)
+	if stream == nil {
+		log.Warn().Str("stream", name).Msg("[rtmp] stream not found")
+		return
+	}
+
+	log.Debug().Str("stream", name).Msg("[rtmp] new consumer")
+
+	cons := rtmp.NewConsumer(conn)
+
+	if err := stream.AddConsumer(cons); err != nil {
+		log.Warn().Err(err).Str("stream", name).Msg("[rtmp]")
+		return
+	}
+
+	// wait until disconnect
+	_ = cons.Start()
+
+	stream.RemoveConsumer(cons)
+
+	log.Debug().Str("stream", name).Msg("[rtmp] disconnect")
+}
+
+func apiHandle(w http.ResponseWriter, r *http.Request) {
+	src := r.URL.Query().Get("src")
+	stream := streams.GetOrNew(src)
+	if stream == nil {
+		http.Error(w, "stream not found", http.StatusNotFound)
+		return
+	}
+
+	if r.Header.Get("Upgrade") == "websocket" {
+		apiHandleWS(w, r, stream)
+		return
+	}
+
+	exit := make(chan struct{}, 1)
+
+	cons := rtmp.NewFLVConsumer("HTTP-FLV")
+	cons.RemoteAddr = r.RemoteAddr
+	cons.UserAgent = r.UserAgent()
+	cons.Listen(func(msg interface{}) {
+		if data, ok := msg.([]byte); ok {
+			if _, err := w.Write(data); err != nil {
+				select {
+				case exit <- struct{}{}:
+				default:
+				}
+				return
+			}
+			if f, ok := w.(http.Flusher); ok {
+				f.Flush()
+			}
+		}
+	})
+
+	w.Header().Set("Content-Type", "video/x-flv")
+
+	if err := stream.AddConsumer(cons); err != nil {
+		log.Error().Err(err).Msg("[api.flv] add consumer")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	select {
+	case <-exit:
+	case <-r.Context().Done():
+	}
+
+	stream.RemoveConsumer(cons)
+
+	log.Trace().Msg("[api.flv] close")
+}
+
+func apiHandleWS(w http.ResponseWriter, r *http.Request, stream *streams.Stream) {
+	ctx := new(api.Context)
+	if err := ctx.Upgrade(w, r); err != nil {
+		log.Error().Err(err).Msg("[api.flv] upgrade")
+		return
+	}
+	defer ctx.Close()
+
+	cons := rtmp.NewFLVConsumer("WS-FLV")
+	cons.RemoteAddr = r.RemoteAddr
+	cons.UserAgent = r.UserAgent()
+	cons.Listen(func(msg interface{}) {
+		if data, ok := msg.([]byte); ok {
+			ctx.Write(data)
+		}
+	})
+
+	if err := stream.AddConsumer(cons); err != nil {
+		log.Error().Err(err).Msg("[api.flv] add consumer")
+		return
+	}
+
+	// wait until client disconnect
+	for {
+		if _, _, err := ctx.Conn.ReadMessage(); err != nil {
+			break
+		}
+	}
+
+	stream.RemoveConsumer(cons)
+
+	log.Trace().Msg("[api.flv] close")
+}
@@ -1,13 +1,18 @@
 package rtsp
 
 import (
+	"github.com/AlexxIT/go2rtc/cmd/api"
 	"github.com/AlexxIT/go2rtc/cmd/app"
+	"github.com/AlexxIT/go2rtc/cmd/events"
+	"github.com/AlexxIT/go2rtc/cmd/publish"
 	"github.com/AlexxIT/go2rtc/cmd/streams"
+	"github.com/AlexxIT/go2rtc/pkg/onvif"
 	"github.com/AlexxIT/go2rtc/pkg/rtsp"
 	"github.com/AlexxIT/go2rtc/pkg/streamer"
 	"github.com/AlexxIT/go2rtc/pkg/tcp"
 	"github.com/rs/zerolog"
 	"net"
+	"strconv"
 	"strings"
 )
 
@@ -29,6 +34,13 @@ func Init() {
 	streams.HandleFunc("rtsp", rtspHandler)
 	streams.HandleFunc("rtsps", rtspHandler)
 	streams.HandleFunc("rtspx", rtspHandler)
+	streams.HandleFunc("rtsp+http", rtspHandler)
+
+	// RTSP publish support
+	publish.HandleFunc("rtsp", publishHandler)
+	publish.HandleFunc("rtsps", publishHandler)
+	publish.HandleFunc("rtspx", publishHandler)
+	publish.HandleFunc("rtsp+http", publishHandler)
 
 	// RTSP server support
 	address := conf.Mod.Listen
@@ -37,6 +49,9 @@ func Init() {
 
 		go worker(address)
 	}
+
+	// RTSP-over-HTTP tunnel on API port
+	api.HandleContentType(rtsp.TunnelContentType, tunnelHandler)
 }
 
 var Port string
@@ -50,10 +65,9 @@ var log zerolog.Logger
 func rtspHandler(url string) (streamer.Producer, error) {
 	backchannel := true
 
+	var params []string
 	if i := strings.IndexByte(url, '#'); i > 0 {
-		if url[i+1:] == "backchannel=0" {
-			backchannel = false
-		}
+		params = strings.Split(url[i+1:], "#")
 		url = url[:i]
 	}
 
@@ -62,6 +76,25 @@ func rtspHandler(url string) (streamer.Producer, error) {
 		return nil, err
 	}
 
+	// rtsp://...#backchannel=0#range=clock=20230101T120000Z-#scale=2
+	for _, param := range params {
+		kv := strings.SplitN(param, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		switch kv[0] {
+		case "backchannel":
+			backchannel = kv[1] != "0"
+		case "range":
+			conn.Range = kv[1]
+		case "scale":
+			conn.Scale, _ = strconv.ParseFloat(kv[1], 64)
+		case "speed":
+			conn.Speed, _ = strconv.ParseFloat(kv[1], 64)
+		}
+	}
+
 	if log.Trace().Enabled() {
 		conn.Listen(func(msg interface{}) {
 			switch msg := msg.(type) {
@@ -73,10 +106,26 @@ func rtspHandler(url string) (streamer.Producer, error) {
 		})
 	}
 
+	conn.Listen(func(msg interface{}) {
+		if event, ok := msg.(*onvif.Event); ok {
+			log.Trace().Str("url", url).Stringer("event", event).Msg("[rtsp] onvif event")
+
+			events.Publish(&events.Event{
+				Type: "onvif", Source: conn.URL.String(), Time: event.Time, Value: event,
+			})
+		}
+	})
+
 	if err = conn.Dial(); err != nil {
 		return nil, err
 	}
 
+	// get supported methods for keep-alive, some cameras answer OPTIONS
+	// with error, but stream fine, so keep-alive will use OPTIONS
+	if err = conn.Options(); err != nil {
+		log.Debug().Err(err).Str("url", url).Msg("[rtsp] options")
+	}
+
 	conn.Backchannel = backchannel
 	if err = conn.Describe(); err != nil {
 		if !backchannel {
@@ -96,6 +145,37 @@ func rtspHandler(url string) (streamer.Producer, error) {
 	return conn, nil
 }
 
+func publishHandler(url string) (publish.Consumer, error) {
+	conn, err := rtsp.NewPublisher(url)
+	if err != nil {
+		return nil, err
+	}
+
+	if log.Trace().Enabled() {
+		conn.Listen(func(msg interface{}) {
+			switch msg := msg.(type) {
+			case *tcp.Request:
+				log.Trace().Msgf("[rtsp] publish request:\n%s", msg)
+			case *tcp.Response:
+				log.Trace().Msgf("[rtsp] publish response:\n%s", msg)
+			}
+		})
+	}
+
+	if err = conn.Dial(); err != nil {
+		return nil, err
+	}
+
+	// get supported methods for keep-alive, error isn't critical here too
+	if err = conn.Options(); err != nil {
+		log.Debug().Err(err).Str("url", url).Msg("[rtsp] options")
+	}
+
+	initMedias(conn)
+
+	return conn, nil
+}
+
 func worker(address string) {
 	srv, err := tcp.NewServer(address)
 	if err != nil {
@@ -106,98 +186,112 @@ func worker(address string) {
 	log.Info().Str("addr", address).Msg("[rtsp] listen")
 
 	srv.Listen(func(msg interface{}) {
-		switch msg.(type) {
+		switch msg := msg.(type) {
 		case net.Conn:
-			var name string
-			var onDisconnect func()
-
-			trace := log.Trace().Enabled()
-
-			conn := rtsp.NewServer(msg.(net.Conn))
-			conn.Listen(func(msg interface{}) {
-				if trace {
-					switch msg := msg.(type) {
-					case *tcp.Request:
-						log.Trace().Msgf("[rtsp] server request:\n%s", msg)
-					case *tcp.Response:
-						log.Trace().Msgf("[rtsp] server response:\n%s", msg)
-					}
-				}
+			handle(msg)
+		}
+	})
 
-				switch msg {
-				case rtsp.MethodDescribe:
-					name = conn.URL.Path[1:]
+	srv.Serve()
+}
 
-					log.Debug().Str("stream", name).Msg("[rtsp] new consumer")
+// handle RTSP server connection, can be TCP or RTSP-over-HTTP tunnel
+func handle(netConn net.Conn) {
+	var name string
+	var onDisconnect func()
 
-					stream := streams.Get(name) // TODO: rewrite
-					if stream == nil {
-						return
-					}
+	trace := log.Trace().Enabled()
 
-					initMedias(conn)
+	conn := rtsp.NewServer(netConn)
+	conn.Listen(func(msg interface{}) {
+		if trace {
+			switch msg := msg.(type) {
+			case *tcp.Request:
+				log.Trace().Msgf("[rtsp] server request:\n%s", msg)
+			case *tcp.Response:
+				log.Trace().Msgf("[rtsp] server response:\n%s", msg)
+			}
+		}
 
-					if err = stream.AddConsumer(conn); err != nil {
-						log.Warn().Err(err).Str("stream", name).Msg("[rtsp]")
-						return
-					}
+		if event, ok := msg.(*onvif.Event); ok {
+			log.Trace().Str("stream", name).Stringer("event", event).Msg("[rtsp] onvif event")
 
-					onDisconnect = func() {
-						stream.RemoveConsumer(conn)
-					}
+			events.Publish(&events.Event{
+				Type: "onvif", Stream: name, Time: event.Time, Value: event,
+			})
+			return
+		}
 
-				case rtsp.MethodAnnounce:
-					if OnProducer != nil {
-						if OnProducer(conn) {
-							return
-						}
-					}
+		switch msg {
+		case rtsp.MethodDescribe:
+			name = conn.URL.Path[1:]
 
-					name = conn.URL.Path[1:]
+			log.Debug().Str("stream", name).Msg("[rtsp] new consumer")
 
-					log.Debug().Str("stream", name).Msg("[rtsp] new producer")
+			stream := streams.Get(name) // TODO: rewrite
+			if stream == nil {
+				return
+			}
 
-					stream := streams.Get(name)
-					if stream == nil {
-						return
-					}
+			initMedias(conn)
 
-					stream.AddProducer(conn)
+			if err := stream.AddConsumer(conn); err != nil {
+				log.Warn().Err(err).Str("stream", name).Msg("[rtsp]")
+				return
+			}
 
-					onDisconnect = func() {
-						stream.RemoveProducer(conn)
-					}
+			onDisconnect = func() {
+				stream.RemoveConsumer(conn)
+			}
 
-				case streamer.StatePlaying:
-					log.Debug().Str("stream", name).Msg("[rtsp] start")
+		case rtsp.MethodAnnounce:
+			if OnProducer != nil {
+				if OnProducer(conn) {
+					return
 				}
-			})
+			}
+
+			name = conn.URL.Path[1:]
 
-			if err = conn.Accept(); err != nil {
-				log.Warn().Err(err).Msg("[rtsp] accept")
+			log.Debug().Str("stream", name).Msg("[rtsp] new producer")
+
+			stream := streams.Get(name)
+			if stream == nil {
 				return
 			}
 
-			if err = conn.Handle(); err != nil {
-				//log.Warn().Err(err).Msg("[rtsp] handle server")
-			}
+			stream.AddProducer(conn)
 
-			if onDisconnect != nil {
-				onDisconnect()
+			onDisconnect = func() {
+				stream.RemoveProducer(conn)
 			}
 
-			log.Debug().Str("stream", name).Msg("[rtsp] disconnect")
+		case streamer.StatePlaying:
+			log.Debug().Str("stream", name).Msg("[rtsp] start")
 		}
 	})
 
-	srv.Serve()
+	if err := conn.Accept(); err != nil {
+		log.Warn().Err(err).Msg("[rtsp] accept")
+		return
+	}
+
+	if err := conn.Handle(); err != nil {
+		//log.Warn().Err(err).Msg("[rtsp] handle server")
+	}
+
+	if onDisconnect != nil {
+		onDisconnect()
+	}
+
+	log.Debug().Str("stream", name).Msg("[rtsp] disconnect")
 }
 
 func initMedias(conn *rtsp.Conn) {
 	// set media candidates from query list
 	for key, value := range conn.URL.Query() {
 		switch key {
-		case streamer.KindVideo, streamer.KindAudio:
+		case streamer.KindVideo, streamer.KindAudio, streamer.KindApplication:
 			for _, value := range value {
 				media := &streamer.Media{
 					Kind: key, Direction: streamer.DirectionRecvonly,
@@ -220,6 +314,7 @@ func initMedias(conn *rtsp.Conn) {
 		conn.Medias = []*streamer.Media{
 			{Kind: streamer.KindVideo, Direction: streamer.DirectionRecvonly},
 			{Kind: streamer.KindAudio, Direction: streamer.DirectionRecvonly},
+			{Kind: streamer.KindApplication, Direction: streamer.DirectionRecvonly},
 		}
 	}
 }
@@ -0,0 +1,116 @@
+package rtsp
+
+import (
+	"github.com/AlexxIT/go2rtc/pkg/rtsp"
+	"net/http"
+	"sync"
+	"time"
+)
+
+func tunnelHandler(w http.ResponseWriter, r *http.Request) {
+	cookie := r.Header.Get("x-sessioncookie")
+	if cookie == "" {
+		http.Error(w, "empty session cookie", http.StatusBadRequest)
+		return
+	}
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "hijacking not supported", http.StatusInternalServerError)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		conn, rw, err := hj.Hijack()
+		if err != nil {
+			log.Warn().Err(err).Msg("[rtsp] tunnel hijack")
+			return
+		}
+
+		// response without Content-Length, data goes until connection close
+		_, _ = rw.WriteString("HTTP/1.0 200 OK\r\n" +
+			"Content-Type: " + rtsp.TunnelContentType + "\r\n" +
+			"Cache-Control: no-cache\r\n" +
+			"Pragma: no-cache\r\n\r\n")
+		if err = rw.Flush(); err != nil {
+			_ = conn.Close()
+			return
+		}
+
+		tunnel := rtsp.NewTunnel(conn)
+
+		tunnelsMu.Lock()
+		wait := tunnels[cookie]
+		if wait == nil || wait.tunnel != nil {
+			wait = &tunnelWait{ready: make(chan struct{})}
+			tunnels[cookie] = wait
+		}
+		wait.tunnel = tunnel
+		close(wait.ready) // wake up POST requests of this session
+		tunnelsMu.Unlock()
+
+		log.Debug().Str("remote_addr", r.RemoteAddr).Msg("[rtsp] new tunnel")
+
+		handle(tunnel)
+
+		tunnelsMu.Lock()
+		if tunnels[cookie] == wait {
+			delete(tunnels, cookie)
+		}
+		tunnelsMu.Unlock()
+
+		_ = tunnel.Close()
+
+	case "POST":
+		// GET connection can come a little later
+		tunnelsMu.Lock()
+		wait := tunnels[cookie]
+		if wait == nil {
+			wait = &tunnelWait{ready: make(chan struct{})}
+			tunnels[cookie] = wait
+		}
+		tunnelsMu.Unlock()
+
+		select {
+		case <-wait.ready:
+		case <-time.After(TunnelTimeout):
+			tunnelsMu.Lock()
+			if tunnels[cookie] == wait && wait.tunnel == nil {
+				delete(tunnels, cookie)
+			}
+			tunnelsMu.Unlock()
+
+			http.Error(w, "unknown session cookie", http.StatusNotFound)
+			return
+		}
+
+		tunnel := wait.tunnel
+
+		conn, rw, err := hj.Hijack()
+		if err != nil {
+			log.Warn().Err(err).Msg("[rtsp] tunnel hijack")
+			return
+		}
+
+		// server doesn't answer on POST request
+		if err = tunnel.AddPost(conn, rw.Reader); err != nil {
+			_ = conn.Close()
+		}
+
+	default:
+		http.Error(w, "", http.StatusMethodNotAllowed)
+	}
+}
+
+// TunnelTimeout - how long POST request waits for GET request of the same session
+const TunnelTimeout = 5 * time.Second
+
+// tunnelWait - tunnel of the session, ready is closed when GET request registers it
+type tunnelWait struct {
+	tunnel *rtsp.Tunnel
+	ready  chan struct{}
+}
+
+var tunnels = map[string]*tunnelWait{}
+var tunnelsMu sync.Mutex
@@ -0,0 +1,23 @@
+package rtsp
+
+import (
+	"github.com/AlexxIT/go2rtc/pkg/rtsp"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTunnelHandler(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(tunnelHandler))
+	defer srv.Close()
+
+	conn, err := rtsp.NewClient("rtsp+http://" + srv.Listener.Addr().String() + "/stream")
+	assert.Nil(t, err)
+	assert.Nil(t, conn.Dial())
+
+	assert.Nil(t, conn.Options())
+	assert.True(t, conn.Supports(rtsp.MethodAnnounce))
+
+	_ = conn.Close()
+}
@@ -0,0 +1,19 @@
+package sdp
+
+import (
+	"github.com/AlexxIT/go2rtc/cmd/streams"
+	"github.com/AlexxIT/go2rtc/pkg/sdp"
+	"github.com/AlexxIT/go2rtc/pkg/streamer"
+)
+
+func Init() {
+	// RTP on UDP ports from SDP file or link: sdp:/config/camera.sdp
+	streams.HandleFunc("sdp", func(url string) (streamer.Producer, error) {
+		return sdp.Load(url[4:]) // remove `sdp:`
+	})
+
+	// RTP on UDP port with codec param: rtp://@239.0.0.1:5004#video=h264
+	streams.HandleFunc("rtp", func(url string) (streamer.Producer, error) {
+		return sdp.Dial(url)
+	})
+}
@@ -0,0 +1,145 @@
+package srt
+
+import (
+	"github.com/AlexxIT/go2rtc/cmd/app"
+	"github.com/AlexxIT/go2rtc/cmd/streams"
+	"github.com/AlexxIT/go2rtc/pkg/mpegts"
+	"github.com/AlexxIT/go2rtc/pkg/srt"
+	"github.com/AlexxIT/go2rtc/pkg/streamer"
+	"github.com/rs/zerolog"
+	"io"
+	"io/ioutil"
+	"time"
+)
+
+func Init() {
+	var conf struct {
+		Mod struct {
+			Listen       string `yaml:"listen"`
+			Passphrase   string `yaml:"passphrase"`
+			Latency      int    `yaml:"latency"`       // milliseconds
+			AllowPublish bool   `yaml:"allow_publish"` // without passphrase
+		} `yaml:"srt"`
+	}
+
+	app.LoadConfig(&conf)
+
+	log = app.GetLogger("srt")
+
+	// SRT client support (caller and listener modes)
+	streams.HandleFunc("srt", streamsHandle)
+
+	// SRT server support
+	address := conf.Mod.Listen
+	if address != "" {
+		opts := &srt.Options{
+			Passphrase: conf.Mod.Passphrase,
+			Latency:    time.Duration(conf.Mod.Latency) * time.Millisecond,
+		}
+
+		// publish only from callers with passphrase or from trusted network
+		allowPublish = conf.Mod.Passphrase != "" || conf.Mod.AllowPublish
+
+		go worker(address, opts)
+	}
+}
+
+var log zerolog.Logger
+
+var allowPublish bool
+
+func streamsHandle(url string) (streamer.Producer, error) {
+	conn, err := srt.Open(url)
+	if err != nil {
+		return nil, err
+	}
+	return mpegts.Open(url, "SRT", conn)
+}
+
+func worker(address string, opts *srt.Options) {
+	ln, err := srt.Listen(address, opts)
+	if err != nil {
+		log.Error().Err(err).Msg("[srt] listen")
+		return
+	}
+
+	log.Info().Str("addr", address).Msg("[srt] listen")
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			log.Error().Err(err).Msg("[srt] accept")
+			return
+		}
+
+		go handle(conn)
+	}
+}
+
+// handle - play or publish by stream ID: srt://host:8890?streamid=camera1
+// or srt://host:8890?streamid=#!::r=camera1,m=publish
+func handle(conn *srt.Conn) {
+	defer conn.Close()
+
+	name, publish := srt.ParseStreamID(conn.StreamID)
+
+	stream := streams.Get(name)
+	if stream == nil {
+		log.Warn().Str("stream", name).Msg("[srt] stream not found")
+		return
+	}
+
+	if publish {
+		if !allowPublish {
+			log.Warn().Str("stream", name).Msg("[srt] publish not allowed")
+			return
+		}
+		handlePublish(conn, name, stream)
+	} else {
+		handlePlay(conn, name, stream)
+	}
+}
+
+func handlePublish(conn *srt.Conn, name string, stream *streams.Stream) {
+	log.Debug().Str("stream", name).Msg("[srt] new producer")
+
+	prod, err := mpegts.Open("srt://"+conn.RemoteAddr().String(), "SRT", conn)
+	if err != nil {
+		log.Warn().Err(err).Str("stream", name).Msg("[srt] open")
+		return
+	}
+
+	stream.AddProducer(prod)
+
+	if err = prod.Handle(); err != nil && err != io.EOF {
+		log.Debug().Err(err).Str("stream", name).Msg("[srt] handle")
+	}
+
+	stream.RemoveProducer(prod)
+
+	log.Debug().Str("stream", name).Msg("[srt] disconnect")
+}
+
+func handlePlay(conn *srt.Conn, name string, stream *streams.Stream) {
+	log.Debug().Str("stream", name).Msg("[srt] new consumer")
+
+	cons := &mpegts.Consumer{RemoteAddr: conn.RemoteAddr().String()}
+	cons.Listen(func(msg interface{}) {
+		if data, ok := msg.([]byte); ok {
+			// connection error is returned by the read below
+			_, _ = conn.Write(data)
+		}
+	})
+
+	if err := stream.AddConsumer(cons); err != nil {
+		log.Warn().Err(err).Str("stream", name).Msg("[srt] add consumer")
+		return
+	}
+
+	// caller doesn't send data in play mode, wait for disconnect
+	_, _ = io.Copy(ioutil.Discard, conn)
+
+	stream.RemoveConsumer(cons)
+
+	log.Debug().Str("stream", name).Msg("[srt] disconnect")
+}
@@ -151,6 +151,33 @@ func (s *Stream) RemoveProducer(prod streamer.Producer) {
 	}
 }
 
+// Sources returns source links of the stream
+func (s *Stream) Sources() []string {
+	var sources []string
+	for _, prod := range s.producers {
+		prod.mx.Lock()
+		if prod.url != "" {
+			sources = append(sources, prod.url)
+		}
+		prod.mx.Unlock()
+	}
+	return sources
+}
+
+// Producers returns started producer elements of the stream
+func (s *Stream) Producers() []streamer.Producer {
+	var producers []streamer.Producer
+	for _, prod := range s.producers {
+		// producer can be started or stopped at the same time
+		prod.mx.Lock()
+		if prod.element != nil {
+			producers = append(producers, prod.element)
+		}
+		prod.mx.Unlock()
+	}
+	return producers
+}
+
 func (s *Stream) Active() bool {
 	if len(s.consumers) > 0 {
 		return true
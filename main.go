@@ -5,16 +5,24 @@ import (
 	"github.com/AlexxIT/go2rtc/cmd/app"
 	"github.com/AlexxIT/go2rtc/cmd/debug"
 	"github.com/AlexxIT/go2rtc/cmd/echo"
+	"github.com/AlexxIT/go2rtc/cmd/events"
 	"github.com/AlexxIT/go2rtc/cmd/exec"
 	"github.com/AlexxIT/go2rtc/cmd/ffmpeg"
 	"github.com/AlexxIT/go2rtc/cmd/hass"
+	"github.com/AlexxIT/go2rtc/cmd/hls"
 	"github.com/AlexxIT/go2rtc/cmd/homekit"
+	"github.com/AlexxIT/go2rtc/cmd/http"
 	"github.com/AlexxIT/go2rtc/cmd/ivideon"
 	"github.com/AlexxIT/go2rtc/cmd/mjpeg"
 	"github.com/AlexxIT/go2rtc/cmd/mp4"
+	"github.com/AlexxIT/go2rtc/cmd/mpegts"
 	"github.com/AlexxIT/go2rtc/cmd/ngrok"
+	"github.com/AlexxIT/go2rtc/cmd/onvif"
+	"github.com/AlexxIT/go2rtc/cmd/publish"
 	"github.com/AlexxIT/go2rtc/cmd/rtmp"
 	"github.com/AlexxIT/go2rtc/cmd/rtsp"
+	"github.com/AlexxIT/go2rtc/cmd/sdp"
+	"github.com/AlexxIT/go2rtc/cmd/srt"
 	"github.com/AlexxIT/go2rtc/cmd/srtp"
 	"github.com/AlexxIT/go2rtc/cmd/streams"
 	"github.com/AlexxIT/go2rtc/cmd/webrtc"
@@ -27,18 +35,25 @@ func main() {
 	app.Init()     // init config and logs
 	streams.Init() // load streams list
 
-	api.Init() // init HTTP API server
+	api.Init()    // init HTTP API server
+	events.Init() // events API for other modules (ex. ONVIF motion)
 
 	echo.Init()
 
 	rtsp.Init()   // add support RTSP client and RTSP server
 	rtmp.Init()   // add support RTMP client
+	http.Init()   // add support HTTP-FLV and WS-FLV clients
 	exec.Init()   // add support exec scheme (depends on RTSP server)
 	ffmpeg.Init() // add support ffmpeg scheme (depends on exec scheme)
 	hass.Init()   // add support hass scheme
+	onvif.Init()  // add support onvif scheme (depends on RTSP client)
 
 	webrtc.Init()
 	mp4.Init()
+	hls.Init()
+	mpegts.Init()
+	srt.Init()
+	sdp.Init()
 	mjpeg.Init()
 
 	srtp.Init()
@@ -46,6 +61,8 @@ func main() {
 
 	ivideon.Init()
 
+	publish.Init() // send streams to remote servers (depends on RTSP and RTMP)
+
 	ngrok.Init()
 	debug.Init()
 
@@ -0,0 +1,46 @@
+package aac
+
+import (
+	"encoding/hex"
+	"github.com/AlexxIT/go2rtc/pkg/streamer"
+)
+
+// SamplesPerFrame - AAC access unit duration in samples (RTP timestamp units)
+const SamplesPerFrame = 1024
+
+// FmtpLine for RTP (RFC 3640) with AudioSpecificConfig
+func FmtpLine(config []byte) string {
+	return "streamtype=5;profile-level-id=1;mode=AAC-hbr;sizelength=13;indexlength=3;indexdeltalength=3;config=" +
+		hex.EncodeToString(config)
+}
+
+// GetConfig returns AudioSpecificConfig from fmtp line: config=1588
+func GetConfig(fmtp string) []byte {
+	if fmtp == "" {
+		return nil
+	}
+
+	s := streamer.Between(fmtp, "config=", ";")
+	if s == "" {
+		return nil
+	}
+
+	b, _ := hex.DecodeString(s)
+	return b
+}
+
+// ObjectType returns audio object type from AudioSpecificConfig:
+// 2 - AAC-LC, 5 - HE-AAC (SBR), 29 - HE-AACv2 (PS), 0 - unknown
+func ObjectType(config []byte) byte {
+	if len(config) < 1 {
+		return 0
+	}
+	objectType := config[0] >> 3
+	if objectType == 31 {
+		if len(config) < 2 {
+			return 0
+		}
+		objectType = 32 + (config[0]&0x07<<3 | config[1]>>5)
+	}
+	return objectType
+}
@@ -0,0 +1,68 @@
+package aac
+
+// ADTS - AAC frames with headers, ex. in MPEG-TS
+
+const ADTSHeaderSize = 7
+
+func IsADTS(b []byte) bool {
+	return len(b) >= ADTSHeaderSize && b[0] == 0xFF && b[1]&0xF6 == 0xF0
+}
+
+// ADTSHeaderLen - 7 bytes or 9 bytes with CRC
+func ADTSHeaderLen(b []byte) int {
+	if b[1]&1 == 0 {
+		return ADTSHeaderSize + 2
+	}
+	return ADTSHeaderSize
+}
+
+// ADTSFrameLen - size of the frame with header
+func ADTSFrameLen(b []byte) int {
+	return int(b[3]&0x03)<<11 | int(b[4])<<3 | int(b[5]>>5)
+}
+
+// ADTSConfig returns AudioSpecificConfig from ADTS header
+func ADTSConfig(b []byte) []byte {
+	objectType := b[2]>>6 + 1
+	sampleRateIndex := b[2] >> 2 & 0x0F
+	channels := b[2]&1<<2 | b[3]>>6
+
+	return []byte{
+		objectType<<3 | sampleRateIndex>>1,
+		sampleRateIndex<<7 | channels<<3,
+	}
+}
+
+// ConfigInfo returns sample rate and channels from AudioSpecificConfig
+func ConfigInfo(config []byte) (sampleRate uint32, channels uint16) {
+	if len(config) < 2 {
+		return
+	}
+	if i := config[0]&0x07<<1 | config[1]>>7; int(i) < len(sampleRates) {
+		sampleRate = sampleRates[i]
+	}
+	channels = uint16(config[1] >> 3 & 0x0F)
+	return
+}
+
+var sampleRates = []uint32{
+	96000, 88200, 64000, 48000, 44100, 32000, 24000, 22050, 16000, 12000, 11025, 8000, 7350,
+}
+
+// ADTSHeader for the frame with AudioSpecificConfig, without CRC
+func ADTSHeader(config []byte, size int) []byte {
+	objectType := config[0] >> 3
+	sampleRateIndex := config[0]&0x07<<1 | config[1]>>7
+	channels := config[1] >> 3 & 0x0F
+
+	size += ADTSHeaderSize
+
+	return []byte{
+		0xFF, 0xF1,
+		(objectType-1)<<6 | sampleRateIndex<<2 | channels>>2,
+		channels&3<<6 | byte(size>>11),
+		byte(size >> 3),
+		byte(size)<<5 | 0x1F,
+		0xFC,
+	}
+}
@@ -0,0 +1,27 @@
+package aac
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestADTS(t *testing.T) {
+	config := []byte{0x12, 0x10} // AAC-LC, 44100, 2 channels
+
+	header := ADTSHeader(config, 100)
+	assert.True(t, IsADTS(header))
+	assert.Equal(t, ADTSHeaderSize, ADTSHeaderLen(header))
+	assert.Equal(t, ADTSHeaderSize+100, ADTSFrameLen(header))
+	assert.Equal(t, config, ADTSConfig(header))
+
+	sampleRate, channels := ConfigInfo(config)
+	assert.Equal(t, uint32(44100), sampleRate)
+	assert.Equal(t, uint16(2), channels)
+}
+
+func TestObjectType(t *testing.T) {
+	assert.Equal(t, byte(2), ObjectType([]byte{0x12, 0x10}))  // AAC-LC
+	assert.Equal(t, byte(5), ObjectType([]byte{0x2B, 0x92}))  // HE-AAC, 22050 Hz
+	assert.Equal(t, byte(42), ObjectType([]byte{0xF9, 0x46})) // escape value
+	assert.Equal(t, byte(0), ObjectType(nil))
+}
@@ -0,0 +1,80 @@
+package aac
+
+import (
+	"encoding/binary"
+	"github.com/AlexxIT/go2rtc/pkg/streamer"
+	"github.com/pion/rtp"
+)
+
+// RTPDepay splits RFC 3640 (mode=AAC-hbr) packet to raw access units,
+// one packet for each unit
+func RTPDepay(track *streamer.Track) streamer.WrapperFunc {
+	return func(push streamer.WriterFunc) streamer.WriterFunc {
+		return func(packet *rtp.Packet) error {
+			b := packet.Payload
+			if len(b) < 2 {
+				return nil
+			}
+
+			// AU-headers-length in bits, each header is 16 bits:
+			// 13 bits size and 3 bits index
+			headersSize := int(binary.BigEndian.Uint16(b)) / 8
+			if len(b) < 2+headersSize {
+				return nil
+			}
+
+			headers := b[2 : 2+headersSize]
+			units := b[2+headersSize:]
+
+			for i := 0; i+1 < len(headers); i += 2 {
+				size := int(binary.BigEndian.Uint16(headers[i:]) >> 3)
+				if size > len(units) {
+					return nil
+				}
+
+				clone := *packet
+				clone.Timestamp = packet.Timestamp + uint32(i/2*SamplesPerFrame)
+				clone.Payload = units[:size]
+				if err := push(&clone); err != nil {
+					return err
+				}
+
+				units = units[size:]
+			}
+
+			return nil
+		}
+	}
+}
+
+// RTPPay packs raw access units to RFC 3640 (mode=AAC-hbr),
+// one unit for each packet, AAC frame is always smaller than usual MTU
+func RTPPay() streamer.WrapperFunc {
+	sequencer := rtp.NewRandomSequencer()
+
+	return func(push streamer.WriterFunc) streamer.WriterFunc {
+		return func(packet *rtp.Packet) error {
+			size := len(packet.Payload)
+			if size > 0x1FFF {
+				return nil // wrong unit, size doesn't fit in 13 bits
+			}
+
+			// AU-headers-length: 16 bits, AU-size: 13 bits, AU-index: 3 bits
+			payload := make([]byte, 4+size)
+			payload[1] = 0x10
+			binary.BigEndian.PutUint16(payload[2:], uint16(size<<3))
+			copy(payload[4:], packet.Payload)
+
+			clone := rtp.Packet{
+				Header: rtp.Header{
+					Version:        2,
+					Marker:         true,
+					SequenceNumber: sequencer.NextSequenceNumber(),
+					Timestamp:      packet.Timestamp,
+				},
+				Payload: payload,
+			}
+			return push(&clone)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package aac
+
+import (
+	"github.com/AlexxIT/go2rtc/pkg/streamer"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRTPDepay(t *testing.T) {
+	// two AU headers (32 bits): sizes 3 and 2
+	packet := &rtp.Packet{
+		Header:  rtp.Header{Timestamp: 1000},
+		Payload: []byte{0x00, 0x20, 0x00, 0x18, 0x00, 0x10, 1, 2, 3, 4, 5},
+	}
+
+	var units []*rtp.Packet
+	push := RTPDepay(&streamer.Track{})(func(packet *rtp.Packet) error {
+		units = append(units, packet)
+		return nil
+	})
+
+	assert.Nil(t, push(packet))
+	assert.Len(t, units, 2)
+	assert.Equal(t, []byte{1, 2, 3}, units[0].Payload)
+	assert.Equal(t, uint32(1000), units[0].Timestamp)
+	assert.Equal(t, []byte{4, 5}, units[1].Payload)
+	assert.Equal(t, uint32(1000+SamplesPerFrame), units[1].Timestamp)
+}
+
+func TestRTPPay(t *testing.T) {
+	var units []*rtp.Packet
+	push := RTPPay()(RTPDepay(nil)(func(packet *rtp.Packet) error {
+		units = append(units, packet)
+		return nil
+	}))
+
+	assert.Nil(t, push(&rtp.Packet{Header: rtp.Header{Timestamp: 1024}, Payload: []byte{1, 2, 3}}))
+	assert.Len(t, units, 1)
+	assert.Equal(t, []byte{1, 2, 3}, units[0].Payload)
+	assert.Equal(t, uint32(1024), units[0].Timestamp)
+}
@@ -76,3 +76,34 @@ func SplitAVC(data []byte) [][]byte {
 	}
 	return nals
 }
+
+// SplitAnnexB returns NAL units without start codes (00 00 01 or 00 00 00 01)
+func SplitAnnexB(b []byte) [][]byte {
+	var nals [][]byte
+
+	start := -1
+	for i := 0; i+2 < len(b); i++ {
+		if b[i] != 0 || b[i+1] != 0 || b[i+2] != 1 {
+			continue
+		}
+
+		if start >= 0 {
+			end := i
+			if b[end-1] == 0 {
+				end-- // 4 bytes start code
+			}
+			if end > start {
+				nals = append(nals, b[start:end])
+			}
+		}
+
+		i += 2
+		start = i + 1
+	}
+
+	if start >= 0 && start < len(b) {
+		nals = append(nals, b[start:])
+	}
+
+	return nals
+}
@@ -2,6 +2,7 @@ package h264
 
 import (
 	"encoding/base64"
+	"fmt"
 	"github.com/AlexxIT/go2rtc/pkg/streamer"
 	"strings"
 )
@@ -26,7 +27,14 @@ func GetProfileLevelID(fmtp string) string {
 	if fmtp == "" {
 		return ""
 	}
-	return streamer.Between(fmtp, "profile-level-id=", ";")
+	if s := streamer.Between(fmtp, "profile-level-id=", ";"); s != "" {
+		return s
+	}
+	// some sources (ex. RTMP) have only SPS, profile, constraints and level are in it
+	if sps, _ := GetParameterSet(fmtp); len(sps) >= 4 {
+		return fmt.Sprintf("%02x%02x%02x", sps[1], sps[2], sps[3])
+	}
+	return ""
 }
 
 func GetParameterSet(fmtp string) (sps, pps []byte) {
@@ -49,3 +57,9 @@ func GetParameterSet(fmtp string) (sps, pps []byte) {
 
 	return
 }
+
+// FmtpLine for RTP with SPS and PPS, ex. for sources with Annex B or AVC stream
+func FmtpLine(sps, pps []byte) string {
+	return "packetization-mode=1;sprop-parameter-sets=" +
+		base64.StdEncoding.EncodeToString(sps) + "," + base64.StdEncoding.EncodeToString(pps)
+}
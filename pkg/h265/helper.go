@@ -7,6 +7,9 @@ import (
 
 const (
 	NALUnitTypeIFrame = 19
+	NALUnitTypeVPS    = 32
+	NALUnitTypeSPS    = 33
+	NALUnitTypePPS    = 34
 )
 
 func NALUnitType(b []byte) byte {
@@ -33,3 +36,10 @@ func GetParameterSet(fmtp string) (vps, sps, pps []byte) {
 
 	return
 }
+
+// FmtpLine for RTP with VPS, SPS and PPS
+func FmtpLine(vps, sps, pps []byte) string {
+	return "sprop-vps=" + base64.StdEncoding.EncodeToString(vps) +
+		";sprop-sps=" + base64.StdEncoding.EncodeToString(sps) +
+		";sprop-pps=" + base64.StdEncoding.EncodeToString(pps)
+}
@@ -0,0 +1,389 @@
+package hls
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"github.com/AlexxIT/go2rtc/pkg/mp4"
+	"github.com/AlexxIT/go2rtc/pkg/mpegts"
+	"github.com/AlexxIT/go2rtc/pkg/streamer"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LiveSegments - count of segments from the end of the live playlist
+// for start, HLS spec recommends at least three target durations
+const LiveSegments = 3
+
+// Dial - producer from HLS source, variant selected from the master playlist
+// by bandwidth and supported codecs, segments can be MPEG-TS or fMP4
+func Dial(uri string) (streamer.Producer, error) {
+	playlist, err := get(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.Contains(string(playlist), "#EXT-X-STREAM-INF") {
+		if uri, err = selectVariant(uri, playlist); err != nil {
+			return nil, err
+		}
+		if playlist, err = get(uri); err != nil {
+			return nil, err
+		}
+	}
+
+	rd := &segmentReader{uri: uri, done: make(chan struct{})}
+	if err = rd.update(playlist, true); err != nil {
+		return nil, err
+	}
+
+	// MPEG-TS starts from sync byte
+	br := bufio.NewReader(rd)
+	b, err := br.Peek(1)
+	if err != nil {
+		_ = rd.Close()
+		return nil, err
+	}
+
+	body := &readCloser{Reader: br, Closer: rd}
+
+	if b[0] == mpegts.SyncByte {
+		return mpegts.Open(uri, "HLS", body)
+	}
+	return mp4.Open(uri, "HLS", body)
+}
+
+// IsPlaylist checks link and content type of the HTTP source
+func IsPlaylist(uri, contentType string) bool {
+	switch strings.ToLower(contentType) {
+	case "application/vnd.apple.mpegurl", "application/x-mpegurl", "audio/mpegurl", "audio/x-mpegurl":
+		return true
+	}
+	if u, err := url.Parse(uri); err == nil {
+		return strings.HasSuffix(u.Path, ".m3u8")
+	}
+	return false
+}
+
+// selectVariant with max bandwidth from variants with supported codecs
+func selectVariant(uri string, playlist []byte) (string, error) {
+	var variant string
+	var bandwidth int
+
+	lines := strings.Split(string(playlist), "\n")
+	for i, line := range lines {
+		if !strings.HasPrefix(line, "#EXT-X-STREAM-INF:") {
+			continue
+		}
+
+		// next line with link
+		var link string
+		for _, s := range lines[i+1:] {
+			if s = strings.TrimSpace(s); s != "" && s[0] != '#' {
+				link = s
+				break
+			}
+		}
+		if link == "" {
+			continue
+		}
+
+		if codecs := attribute(line, "CODECS"); codecs != "" && !supportedCodecs(codecs) {
+			continue
+		}
+
+		n, _ := strconv.Atoi(attribute(line, "BANDWIDTH"))
+		if variant == "" || n > bandwidth {
+			variant = link
+			bandwidth = n
+		}
+	}
+
+	if variant == "" {
+		return "", errors.New("hls: can't find variant with supported codecs")
+	}
+
+	return resolve(uri, variant)
+}
+
+// supportedCodecs checks list in RFC 6381 format: avc1.640029,mp4a.40.2
+func supportedCodecs(codecs string) bool {
+	for _, codec := range strings.Split(codecs, ",") {
+		codec = strings.TrimSpace(codec)
+		if i := strings.IndexByte(codec, '.'); i > 0 {
+			codec = codec[:i]
+		}
+		switch codec {
+		case "avc1", "avc3", "hvc1", "hev1", "mp4a":
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// attribute from tag line: #EXT-X-STREAM-INF:BANDWIDTH=1000000,CODECS="avc1.640029,mp4a.40.2"
+func attribute(line, name string) string {
+	i := strings.Index(line, name+"=")
+	for i > 0 && line[i-1] != ':' && line[i-1] != ',' {
+		j := strings.Index(line[i+1:], name+"=")
+		if j < 0 {
+			return ""
+		}
+		i += 1 + j
+	}
+	if i < 0 {
+		return ""
+	}
+
+	s := line[i+len(name)+1:]
+	if strings.HasPrefix(s, `"`) {
+		if j := strings.IndexByte(s[1:], '"'); j >= 0 {
+			return s[1 : 1+j]
+		}
+		return s[1:]
+	}
+	if j := strings.IndexByte(s, ','); j >= 0 {
+		return s[:j]
+	}
+	return strings.TrimSpace(s)
+}
+
+func resolve(base, ref string) (string, error) {
+	u, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	if u, err = u.Parse(ref); err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+var client = &http.Client{Timeout: 30 * time.Second}
+
+func get(uri string) ([]byte, error) {
+	res, err := client.Get(uri)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.New("hls: wrong response status: " + res.Status)
+	}
+
+	return ioutil.ReadAll(res.Body)
+}
+
+type segment struct {
+	sequence int
+	uri      string
+	init     string // link to init segment from EXT-X-MAP
+}
+
+// segmentReader - segments of the media playlist as one stream, init segment
+// added before the first segment and after each change (ex. discontinuity)
+type segmentReader struct {
+	uri      string
+	segments []*segment
+	sequence int // next segment
+	target   time.Duration
+	ended    bool
+
+	init string // current init segment
+	rd   io.ReadCloser
+
+	closed bool
+	done   chan struct{}
+	mu     sync.Mutex
+}
+
+func (r *segmentReader) Read(p []byte) (n int, err error) {
+	for {
+		r.mu.Lock()
+		rd := r.rd
+		r.mu.Unlock()
+
+		if rd == nil {
+			if rd, err = r.next(); err != nil {
+				return
+			}
+
+			r.mu.Lock()
+			if r.closed {
+				r.mu.Unlock()
+				_ = rd.Close()
+				return 0, io.ErrClosedPipe
+			}
+			r.rd = rd
+			r.mu.Unlock()
+		}
+
+		if n, err = rd.Read(p); err == io.EOF {
+			r.mu.Lock()
+			_ = r.rd.Close()
+			r.rd = nil
+			r.mu.Unlock()
+
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return
+	}
+}
+
+func (r *segmentReader) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	close(r.done)
+
+	if r.rd != nil {
+		return r.rd.Close()
+	}
+	return nil
+}
+
+// next opens the next segment, reloads playlist until the segment is ready
+func (r *segmentReader) next() (io.ReadCloser, error) {
+	for len(r.segments) == 0 {
+		if r.ended {
+			return nil, io.EOF
+		}
+
+		// reload interval when playlist hasn't changed, HLS spec recommends
+		// half the target duration
+		select {
+		case <-time.After(r.target / 2):
+		case <-r.done:
+			return nil, io.ErrClosedPipe
+		}
+
+		playlist, err := get(r.uri)
+		if err != nil {
+			return nil, err
+		}
+		if err = r.update(playlist, false); err != nil {
+			return nil, err
+		}
+	}
+
+	seg := r.segments[0]
+	r.segments = r.segments[1:]
+
+	res, err := client.Get(seg.uri)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		_ = res.Body.Close()
+		return nil, errors.New("hls: wrong response status: " + res.Status)
+	}
+
+	if seg.init == "" || seg.init == r.init {
+		return res.Body, nil
+	}
+
+	init, err := get(seg.init)
+	if err != nil {
+		_ = res.Body.Close()
+		return nil, err
+	}
+
+	r.init = seg.init
+
+	return &readCloser{
+		Reader: io.MultiReader(bytes.NewReader(init), res.Body),
+		Closer: res.Body,
+	}, nil
+}
+
+// update adds new segments from the media playlist
+func (r *segmentReader) update(playlist []byte, first bool) error {
+	var segments []*segment
+	var sequence int
+	var init string
+
+	r.ended = false
+
+	for _, line := range strings.Split(string(playlist), "\n") {
+		line = strings.TrimSpace(line)
+
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#EXT-X-TARGETDURATION:"):
+			n, _ := strconv.ParseFloat(line[22:], 64)
+			r.target = time.Duration(n * float64(time.Second))
+		case strings.HasPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"):
+			sequence, _ = strconv.Atoi(line[22:])
+		case strings.HasPrefix(line, "#EXT-X-MAP:"):
+			if s := attribute(line, "URI"); s != "" {
+				var err error
+				if init, err = resolve(r.uri, s); err != nil {
+					return err
+				}
+			}
+		case line == "#EXT-X-ENDLIST":
+			r.ended = true
+		case line[0] == '#':
+		default:
+			uri, err := resolve(r.uri, line)
+			if err != nil {
+				return err
+			}
+			segments = append(segments, &segment{sequence: sequence, uri: uri, init: init})
+			sequence++
+		}
+	}
+
+	if r.target <= 0 {
+		r.target = time.Second
+	}
+
+	if len(segments) == 0 {
+		if first {
+			return errors.New("hls: empty playlist")
+		}
+		return nil
+	}
+
+	// media sequence was reset, ex. source restart
+	if last := segments[len(segments)-1]; last.sequence < r.sequence-len(segments) {
+		first = true
+	}
+
+	if first {
+		// live playlist starts near the end
+		if !r.ended && len(segments) > LiveSegments {
+			segments = segments[len(segments)-LiveSegments:]
+		}
+		r.sequence = segments[0].sequence
+	}
+
+	for _, seg := range segments {
+		if seg.sequence >= r.sequence {
+			r.segments = append(r.segments, seg)
+			r.sequence = seg.sequence + 1
+		}
+	}
+
+	return nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
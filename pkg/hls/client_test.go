@@ -0,0 +1,97 @@
+package hls
+
+import (
+	"github.com/AlexxIT/go2rtc/pkg/h264"
+	"github.com/AlexxIT/go2rtc/pkg/mp4"
+	"github.com/AlexxIT/go2rtc/pkg/streamer"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient(t *testing.T) {
+	var segment []byte
+
+	cons := &mp4.Consumer{}
+	cons.Listen(func(msg interface{}) {
+		if data, ok := msg.([]byte); ok {
+			segment = append(segment, data...)
+		}
+	})
+
+	video := &streamer.Track{Codec: &streamer.Codec{
+		Name: streamer.CodecH264, ClockRate: 90000, PayloadType: h264.PayloadTypeAVC,
+		FmtpLine: "packetization-mode=1;sprop-parameter-sets=Z0JAHqaAoD2QAA==,aM48gAA=",
+	}}
+	audio := &streamer.Track{Codec: &streamer.Codec{
+		Name: streamer.CodecAAC, ClockRate: 44100, Channels: 2,
+		FmtpLine: "streamtype=5;mode=AAC-hbr;sizelength=13;indexlength=3;indexdeltalength=3;config=1210",
+	}}
+
+	cons.AddTrack(nil, video)
+	cons.AddTrack(nil, audio)
+
+	init, err := cons.Init()
+	assert.Nil(t, err)
+
+	for i := uint32(0); i < 4; i++ {
+		payload := []byte{0x41, 3, 4}
+		if i == 0 {
+			payload = []byte{0x65, 1, 2}
+		}
+		_ = video.WriteRTP(&rtp.Packet{Header: rtp.Header{Timestamp: 1000 + i*3600}, Payload: h264.EncodeAVC(payload)})
+		_ = audio.WriteRTP(&rtp.Packet{Header: rtp.Header{Timestamp: 500 + i*1024}, Payload: []byte{0, 0x10, 0, 0x10, 0xBB, 0xCC}})
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/master.m3u8":
+			_, _ = w.Write([]byte("#EXTM3U\n" +
+				`#EXT-X-STREAM-INF:BANDWIDTH=2000000,CODECS="avc1.42401e,ac-3"` + "\n" + "ac3.m3u8\n" +
+				`#EXT-X-STREAM-INF:BANDWIDTH=500000,CODECS="avc1.42401e,mp4a.40.2"` + "\n" + "low.m3u8\n" +
+				`#EXT-X-STREAM-INF:BANDWIDTH=1000000,CODECS="avc1.42401e,mp4a.40.2"` + "\n" + "live/high.m3u8\n"))
+		case "/live/high.m3u8":
+			_, _ = w.Write([]byte("#EXTM3U\n#EXT-X-VERSION:7\n#EXT-X-TARGETDURATION:1\n#EXT-X-MEDIA-SEQUENCE:10\n" +
+				`#EXT-X-MAP:URI="init.mp4"` + "\n#EXTINF:0.120,\nsegment.m4s\n#EXT-X-ENDLIST\n"))
+		case "/live/init.mp4":
+			_, _ = w.Write(init)
+		case "/live/segment.m4s":
+			_, _ = w.Write(segment)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	prod, err := Dial(srv.URL + "/master.m3u8")
+	assert.Nil(t, err)
+
+	medias := prod.GetMedias()
+	assert.Len(t, medias, 2)
+	assert.Equal(t, streamer.CodecH264, medias[0].Codecs[0].Name)
+	assert.Equal(t, streamer.CodecAAC, medias[1].Codecs[0].Name)
+	assert.Equal(t, uint32(44100), medias[1].Codecs[0].ClockRate)
+
+	var packets []*rtp.Packet
+	track := prod.GetTrack(medias[0], medias[0].Codecs[0])
+	track.Bind(func(packet *rtp.Packet) error {
+		packets = append(packets, packet)
+		return nil
+	})
+
+	assert.Equal(t, io.EOF, prod.Start())
+
+	assert.Len(t, packets, 4)
+	assert.Equal(t, packets[2].Timestamp+3600, packets[3].Timestamp)
+	assert.Equal(t, []byte{0x65, 1, 2}, packets[0].Payload[4:])
+}
+
+func TestAttribute(t *testing.T) {
+	line := `#EXT-X-STREAM-INF:AVERAGE-BANDWIDTH=800000,BANDWIDTH=1000000,CODECS="avc1.640029,mp4a.40.2"`
+	assert.Equal(t, "1000000", attribute(line, "BANDWIDTH"))
+	assert.Equal(t, "avc1.640029,mp4a.40.2", attribute(line, "CODECS"))
+	assert.Equal(t, "", attribute(line, "RESOLUTION"))
+}
@@ -0,0 +1,374 @@
+package hls
+
+import (
+	"github.com/AlexxIT/go2rtc/pkg/mp4"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// WindowSize - count of segments in the live playlist
+	WindowSize = 6
+	// MinSegmentDuration - segments are cut on the first keyframe after it
+	MinSegmentDuration = time.Second
+)
+
+// Low-Latency HLS settings
+const (
+	// LowLatencyWindowSize - more segments, so delta updates can skip some of them
+	LowLatencyWindowSize = 12
+	// PartTarget - max duration of the partial segment
+	PartTarget = 500 * time.Millisecond
+	// PartDuration - parts are cut on the first sample after it,
+	// so the next frame fits in PartTarget
+	PartDuration = 400 * time.Millisecond
+	// PartSegments - count of last segments with parts in the playlist
+	PartSegments = 2
+)
+
+// Playlist - sliding window of CMAF segments, built from fragments of mp4.Consumer,
+// each segment starts from the video keyframe. Low-Latency playlist also
+// splits segments to partial segments
+type Playlist struct {
+	ID   string // session ID for links
+	Init []byte // init segment
+
+	segments []*Segment
+	current  *Segment
+	part     *Part // current part of the current segment
+	sequence int   // media sequence for the next segment
+	target   time.Duration
+
+	lowLatency bool
+	window     int
+	trackID    byte // parts are cut by samples of the track with keyframes
+
+	ready  chan struct{}
+	update chan struct{} // closed on each new part or segment
+	mu     sync.Mutex
+}
+
+type Segment struct {
+	Sequence int
+	Duration time.Duration
+	Data     []byte
+	Parts    []*Part
+
+	start time.Duration
+}
+
+type Part struct {
+	Duration    time.Duration
+	Independent bool // starts from keyframe
+	Data        []byte
+
+	start time.Duration
+}
+
+func NewPlaylist(id string) *Playlist {
+	return &Playlist{
+		ID:     id,
+		window: WindowSize,
+		ready:  make(chan struct{}),
+		update: make(chan struct{}),
+	}
+}
+
+func NewLowLatencyPlaylist(id string) *Playlist {
+	p := NewPlaylist(id)
+	p.lowLatency = true
+	p.window = LowLatencyWindowSize
+	return p
+}
+
+// Handle events of mp4.Consumer
+func (p *Playlist) Handle(msg interface{}) {
+	switch msg := msg.(type) {
+	case mp4.Sample:
+		p.sample(msg)
+	case []byte:
+		p.mu.Lock()
+		if p.current != nil {
+			p.current.Data = append(p.current.Data, msg...)
+			if p.part != nil {
+				p.part.Data = append(p.part.Data, msg...)
+			}
+		}
+		p.mu.Unlock()
+	}
+}
+
+// Wait until first segment is ready
+func (p *Playlist) Wait(timeout time.Duration) bool {
+	select {
+	case <-p.ready:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// WaitPart blocks until the segment (part < 0) or the part of the segment is ready,
+// used for blocking playlist reload and preload hints
+func (p *Playlist) WaitPart(sequence, part int, timeout time.Duration) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		p.mu.Lock()
+		ok := p.hasPart(sequence, part)
+		update := p.update
+		p.mu.Unlock()
+
+		if ok {
+			return true
+		}
+
+		select {
+		case <-update:
+		case <-timer.C:
+			return false
+		}
+	}
+}
+
+// NextSequence - media sequence of the segment in progress
+func (p *Playlist) NextSequence() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.current != nil {
+		return p.current.Sequence
+	}
+	return p.sequence
+}
+
+// TargetDuration - max duration of segments, rounded up to seconds
+func (p *Playlist) TargetDuration() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.targetDuration()
+}
+
+// Marshal returns media playlist with segments from the window, with skip
+// old segments are replaced with EXT-X-SKIP tag (Low-Latency delta update)
+func (p *Playlist) Marshal(skip bool) []byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	target := p.targetDuration()
+
+	var s string
+	if p.lowLatency {
+		s = "#EXTM3U\n#EXT-X-VERSION:9\n" +
+			"#EXT-X-TARGETDURATION:" + seconds(target, 0) + "\n" +
+			"#EXT-X-SERVER-CONTROL:CAN-BLOCK-RELOAD=YES,PART-HOLD-BACK=" + seconds(3*PartTarget, 3) +
+			",CAN-SKIP-UNTIL=" + seconds(6*target, 1) + "\n" +
+			"#EXT-X-PART-INF:PART-TARGET=" + seconds(PartTarget, 3) + "\n"
+	} else {
+		s = "#EXTM3U\n#EXT-X-VERSION:6\n" +
+			"#EXT-X-TARGETDURATION:" + seconds(target, 0) + "\n"
+	}
+
+	if len(p.segments) > 0 {
+		s += "#EXT-X-MEDIA-SEQUENCE:" + strconv.Itoa(p.segments[0].Sequence) + "\n"
+	}
+
+	s += "#EXT-X-INDEPENDENT-SEGMENTS\n" +
+		`#EXT-X-MAP:URI="init.mp4?id=` + p.ID + `"` + "\n"
+
+	segments := p.segments
+
+	if skip && p.lowLatency {
+		// skip segments that entirely older than CAN-SKIP-UNTIL from the end of the playlist
+		var n int
+		var duration time.Duration
+		for i := len(segments) - 1; i >= 0; i-- {
+			if duration += segments[i].Duration; duration > 6*target {
+				n = i
+				break
+			}
+		}
+		if n > 0 {
+			s += "#EXT-X-SKIP:SKIPPED-SEGMENTS=" + strconv.Itoa(n) + "\n"
+			segments = segments[n:]
+		}
+	}
+
+	for _, segment := range segments {
+		s += p.marshalParts(segment) +
+			"#EXTINF:" + seconds(segment.Duration, 3) + ",\n" +
+			"segment.m4s?id=" + p.ID + "&n=" + strconv.Itoa(segment.Sequence) + "\n"
+	}
+
+	if p.lowLatency && p.current != nil {
+		s += p.marshalParts(p.current) +
+			`#EXT-X-PRELOAD-HINT:TYPE=PART,URI="` + p.partURI(p.current.Sequence, len(p.current.Parts)) + `"` + "\n"
+	}
+
+	return []byte(s)
+}
+
+// Segment returns data of the segment from the window or nil
+func (p *Playlist) Segment(sequence int) []byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, segment := range p.segments {
+		if segment.Sequence == sequence {
+			return segment.Data
+		}
+	}
+	return nil
+}
+
+// Part returns data of the part or nil
+func (p *Playlist) Part(sequence, part int) []byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if segment := p.getSegment(sequence); segment != nil && part >= 0 && part < len(segment.Parts) {
+		return segment.Parts[part].Data
+	}
+	return nil
+}
+
+func (p *Playlist) sample(sample mp4.Sample) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.current == nil {
+		if sample.Keyframe {
+			p.trackID = sample.TrackID
+			p.newSegment(sample.Time)
+		}
+		return
+	}
+
+	if sample.TrackID != p.trackID {
+		return
+	}
+
+	if sample.Keyframe && sample.Time-p.current.start >= MinSegmentDuration {
+		p.closePart(sample.Time)
+
+		p.current.Duration = sample.Time - p.current.start
+		if p.current.Duration > p.target {
+			p.target = p.current.Duration
+		}
+
+		if len(p.segments) == 0 {
+			close(p.ready)
+		}
+
+		p.segments = append(p.segments, p.current)
+		if len(p.segments) > p.window {
+			p.segments = p.segments[1:]
+		}
+
+		// free memory of parts that are not in the playlist anymore
+		if i := len(p.segments) - PartSegments - 1; i >= 0 {
+			p.segments[i].Parts = nil
+		}
+
+		p.newSegment(sample.Time)
+		p.notify()
+		return
+	}
+
+	if p.part != nil && sample.Time-p.part.start >= PartDuration {
+		p.closePart(sample.Time)
+		p.part = &Part{Independent: sample.Keyframe, start: sample.Time}
+		p.notify()
+	}
+}
+
+func (p *Playlist) newSegment(ts time.Duration) {
+	p.current = &Segment{Sequence: p.sequence, start: ts}
+	p.sequence++
+
+	if p.lowLatency {
+		p.part = &Part{Independent: true, start: ts}
+	}
+}
+
+func (p *Playlist) closePart(ts time.Duration) {
+	if p.part == nil {
+		return
+	}
+	p.part.Duration = ts - p.part.start
+	p.current.Parts = append(p.current.Parts, p.part)
+	p.part = nil
+}
+
+func (p *Playlist) notify() {
+	close(p.update)
+	p.update = make(chan struct{})
+}
+
+func (p *Playlist) getSegment(sequence int) *Segment {
+	if p.current != nil && p.current.Sequence == sequence {
+		return p.current
+	}
+	for _, segment := range p.segments {
+		if segment.Sequence == sequence {
+			return segment
+		}
+	}
+	return nil
+}
+
+func (p *Playlist) hasPart(sequence, part int) bool {
+	if part < 0 {
+		for _, segment := range p.segments {
+			if segment.Sequence >= sequence {
+				return true
+			}
+		}
+		return false
+	}
+
+	if p.current == nil || sequence > p.current.Sequence {
+		return false
+	}
+	if sequence < p.current.Sequence {
+		return true // segment is complete with all its parts
+	}
+	return part < len(p.current.Parts)
+}
+
+func (p *Playlist) marshalParts(segment *Segment) string {
+	var s string
+	for i, part := range segment.Parts {
+		s += "#EXT-X-PART:DURATION=" + seconds(part.Duration, 3) +
+			`,URI="` + p.partURI(segment.Sequence, i) + `"`
+		if part.Independent {
+			s += ",INDEPENDENT=YES"
+		}
+		s += "\n"
+	}
+	return s
+}
+
+func (p *Playlist) partURI(sequence, part int) string {
+	return "part.m4s?id=" + p.ID + "&n=" + strconv.Itoa(sequence) + "&p=" + strconv.Itoa(part)
+}
+
+func (p *Playlist) targetDuration() time.Duration {
+	if p.target == 0 {
+		return time.Second
+	}
+	return time.Duration(math.Ceil(p.target.Seconds())) * time.Second
+}
+
+func seconds(d time.Duration, prec int) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', prec, 64)
+}
+
+// MasterPlaylist with one variant stream, codecs in RFC 6381 format
+func MasterPlaylist(uri, codecs string) []byte {
+	return []byte("#EXTM3U\n" +
+		`#EXT-X-STREAM-INF:BANDWIDTH=1000000,CODECS="` + codecs + `"` + "\n" +
+		uri + "\n")
+}
@@ -0,0 +1,102 @@
+package hls
+
+import (
+	"github.com/AlexxIT/go2rtc/pkg/mp4"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestPlaylist(t *testing.T) {
+	p := NewPlaylist("abc")
+
+	// fragments before first keyframe are skipped
+	p.Handle([]byte{0})
+
+	for i := 0; i < 10; i++ {
+		ts := time.Duration(i) * 500 * time.Millisecond
+		// segment isn't cut on keyframe 3, it's too short
+		keyframe := i == 0 || i == 2 || i == 3 || i == 6 || i == 8
+		p.Handle(mp4.Sample{Keyframe: keyframe, Time: ts})
+		p.Handle([]byte{byte(i)})
+	}
+
+	assert.True(t, p.Wait(time.Second))
+	assert.Equal(t, []byte{0, 1}, p.Segment(0))
+	assert.Equal(t, []byte{2, 3, 4, 5}, p.Segment(1))
+	assert.Nil(t, p.Segment(4))
+
+	playlist := `#EXTM3U
+#EXT-X-VERSION:6
+#EXT-X-TARGETDURATION:2
+#EXT-X-MEDIA-SEQUENCE:0
+#EXT-X-INDEPENDENT-SEGMENTS
+#EXT-X-MAP:URI="init.mp4?id=abc"
+#EXTINF:1.000,
+segment.m4s?id=abc&n=0
+#EXTINF:2.000,
+segment.m4s?id=abc&n=1
+#EXTINF:1.000,
+segment.m4s?id=abc&n=2
+`
+	assert.Equal(t, playlist, string(p.Marshal(false)))
+}
+
+func TestLowLatency(t *testing.T) {
+	p := NewLowLatencyPlaylist("abc")
+
+	// video 10 fps, keyframe each 1 second, audio track without keyframes
+	for i := 0; i < 25; i++ {
+		ts := time.Duration(i) * 100 * time.Millisecond
+		p.Handle(mp4.Sample{TrackID: 0, Keyframe: i%10 == 0, Time: ts})
+		p.Handle([]byte{byte(i)})
+		p.Handle(mp4.Sample{TrackID: 1, Time: ts})
+		p.Handle([]byte{0xFF})
+	}
+
+	assert.True(t, p.WaitPart(1, -1, time.Second))
+	assert.True(t, p.WaitPart(2, 0, time.Second))
+	assert.False(t, p.WaitPart(2, 1, 10*time.Millisecond))
+
+	assert.Equal(t, []byte{0, 0xFF, 1, 0xFF, 2, 0xFF, 3, 0xFF}, p.Part(0, 0))
+	assert.Equal(t, []byte{20, 0xFF, 21, 0xFF, 22, 0xFF, 23, 0xFF}, p.Part(2, 0))
+	assert.Nil(t, p.Part(0, -1))
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		for i := 25; i < 30; i++ {
+			p.Handle(mp4.Sample{TrackID: 0, Time: time.Duration(i) * 100 * time.Millisecond})
+		}
+	}()
+	assert.True(t, p.WaitPart(2, 1, time.Second))
+
+	playlist := `#EXTM3U
+#EXT-X-VERSION:9
+#EXT-X-TARGETDURATION:1
+#EXT-X-SERVER-CONTROL:CAN-BLOCK-RELOAD=YES,PART-HOLD-BACK=1.500,CAN-SKIP-UNTIL=6.0
+#EXT-X-PART-INF:PART-TARGET=0.500
+#EXT-X-MEDIA-SEQUENCE:0
+#EXT-X-INDEPENDENT-SEGMENTS
+#EXT-X-MAP:URI="init.mp4?id=abc"
+#EXT-X-PART:DURATION=0.400,URI="part.m4s?id=abc&n=0&p=0",INDEPENDENT=YES
+#EXT-X-PART:DURATION=0.400,URI="part.m4s?id=abc&n=0&p=1"
+#EXT-X-PART:DURATION=0.200,URI="part.m4s?id=abc&n=0&p=2"
+#EXTINF:1.000,
+segment.m4s?id=abc&n=0
+#EXT-X-PART:DURATION=0.400,URI="part.m4s?id=abc&n=1&p=0",INDEPENDENT=YES
+#EXT-X-PART:DURATION=0.400,URI="part.m4s?id=abc&n=1&p=1"
+#EXT-X-PART:DURATION=0.200,URI="part.m4s?id=abc&n=1&p=2"
+#EXTINF:1.000,
+segment.m4s?id=abc&n=1
+#EXT-X-PART:DURATION=0.400,URI="part.m4s?id=abc&n=2&p=0",INDEPENDENT=YES
+#EXT-X-PART:DURATION=0.400,URI="part.m4s?id=abc&n=2&p=1"
+#EXT-X-PRELOAD-HINT:TYPE=PART,URI="part.m4s?id=abc&n=2&p=2"
+`
+	assert.Equal(t, playlist, string(p.Marshal(false)))
+
+	// delta update skips segments older than 6 target durations
+	for i := 30; i < 100; i++ {
+		p.Handle(mp4.Sample{TrackID: 0, Keyframe: i%10 == 0, Time: time.Duration(i) * 100 * time.Millisecond})
+	}
+	assert.Contains(t, string(p.Marshal(true)), "#EXT-X-SKIP:SKIPPED-SEGMENTS=2\n#EXTINF:1.000,\nsegment.m4s?id=abc&n=2\n")
+}
@@ -0,0 +1,200 @@
+package mjpeg
+
+import (
+	"bufio"
+	"errors"
+	"github.com/AlexxIT/go2rtc/pkg/streamer"
+	"github.com/pion/rtp"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client - producer from MJPEG stream (multipart/x-mixed-replace), ex. HTTP
+// source of camera. JPEG frames are sent as RTP packets without transcoding
+type Client struct {
+	streamer.Element
+
+	URI string
+
+	medias []*streamer.Media
+	track  *streamer.Track
+
+	rd     *bufio.Reader
+	closer io.Closer
+	frame  []byte // first frame, read while open
+	start  time.Time
+	closed bool
+
+	receive int
+}
+
+// Open reads the first frame, so unsupported stream is found before use
+func Open(uri string, rd io.ReadCloser) (*Client, error) {
+	c := &Client{
+		URI:    uri,
+		rd:     bufio.NewReaderSize(rd, 64*1024),
+		closer: rd,
+	}
+
+	var err error
+	if c.frame, err = c.readFrame(); err == nil {
+		_, err = RTPPay(c.frame, PacketSize)
+	}
+	if err != nil {
+		_ = rd.Close()
+		return nil, err
+	}
+
+	codec := &streamer.Codec{Name: streamer.CodecJPEG, ClockRate: 90000, PayloadType: 26}
+
+	media := &streamer.Media{
+		Kind:      streamer.KindVideo,
+		Direction: streamer.DirectionSendonly,
+		Codecs:    []*streamer.Codec{codec},
+	}
+	c.medias = []*streamer.Media{media}
+	c.track = &streamer.Track{Codec: codec, Direction: media.Direction}
+
+	return c, nil
+}
+
+func (c *Client) Handle() error {
+	defer c.Fire(streamer.StateNull)
+
+	c.Fire(streamer.StatePlaying)
+
+	c.start = time.Now()
+
+	for frame := c.frame; ; {
+		c.receive += len(frame)
+		c.writeFrame(frame)
+
+		var err error
+		if frame, err = c.readFrame(); err != nil {
+			if c.closed {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+func (c *Client) Close() error {
+	c.closed = true
+	return c.closer.Close()
+}
+
+// readFrame skips boundary and part headers and returns JPEG of the next part.
+// Boundary isn't checked, because many cameras send it different from
+// the Content-Type header. Frame ends by Content-Length or by JPEG markers
+func (c *Client) readFrame() ([]byte, error) {
+	var size int
+
+	for {
+		if b, _ := c.rd.Peek(2); len(b) == 2 && b[0] == 0xFF && b[1] == 0xD8 {
+			break
+		}
+
+		line, err := c.rd.ReadSlice('\n')
+		if err != nil {
+			if err == bufio.ErrBufferFull {
+				return nil, errors.New("mjpeg: wrong multipart stream")
+			}
+			return nil, err
+		}
+
+		if i := strings.IndexByte(string(line), ':'); i > 0 {
+			k := strings.TrimSpace(string(line[:i]))
+			if strings.EqualFold(k, "Content-Length") {
+				size, _ = strconv.Atoi(strings.TrimSpace(string(line[i+1:])))
+			}
+		}
+	}
+
+	if size > 0 {
+		b := make([]byte, size)
+		if _, err := io.ReadFull(c.rd, b); err != nil {
+			return nil, err
+		}
+		return b, nil
+	}
+
+	return readJPEG(c.rd)
+}
+
+// readJPEG reads segments of JPEG until EOI marker
+func readJPEG(rd *bufio.Reader) ([]byte, error) {
+	b := make([]byte, 2, 64*1024)
+	if _, err := io.ReadFull(rd, b); err != nil {
+		return nil, err
+	}
+
+	for {
+		marker := make([]byte, 4)
+		if _, err := io.ReadFull(rd, marker[:2]); err != nil {
+			return nil, err
+		}
+		if marker[0] != 0xFF {
+			return nil, errors.New("mjpeg: wrong JPEG marker")
+		}
+
+		if marker[1] == 0xD9 { // EOI
+			return append(b, marker[:2]...), nil
+		}
+
+		if _, err := io.ReadFull(rd, marker[2:]); err != nil {
+			return nil, err
+		}
+
+		size := int(marker[2])<<8 | int(marker[3])
+		if size < 2 {
+			return nil, errors.New("mjpeg: wrong JPEG segment size")
+		}
+
+		segment := make([]byte, size-2)
+		if _, err := io.ReadFull(rd, segment); err != nil {
+			return nil, err
+		}
+		b = append(b, marker...)
+		b = append(b, segment...)
+
+		if marker[1] != 0xDA {
+			continue
+		}
+
+		// SOS, entropy-coded data can't contain markers, except RSTn
+		for prev := byte(0); ; {
+			c, err := rd.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			b = append(b, c)
+			if prev == 0xFF && c == 0xD9 {
+				return b, nil
+			}
+			prev = c
+		}
+	}
+}
+
+func (c *Client) writeFrame(frame []byte) {
+	payloads, err := RTPPay(frame, PacketSize)
+	if err != nil {
+		return // skip broken frame
+	}
+
+	// source doesn't have timestamps, so time of receive is used
+	timestamp := streamer.RTPTime(time.Since(c.start), c.track.Codec.ClockRate)
+
+	for i, payload := range payloads {
+		_ = c.track.WriteRTP(&rtp.Packet{
+			Header: rtp.Header{
+				Marker:    i == len(payloads)-1,
+				Timestamp: timestamp,
+			},
+			Payload: payload,
+		})
+	}
+}
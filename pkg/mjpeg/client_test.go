@@ -0,0 +1,67 @@
+package mjpeg
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/AlexxIT/go2rtc/pkg/streamer"
+	"github.com/stretchr/testify/assert"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io/ioutil"
+	"testing"
+)
+
+func TestClient(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 320, 240))
+	for y := 0; y < 240; y++ {
+		for x := 0; x < 320; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: uint8(x + y), A: 255})
+		}
+	}
+
+	buf := bytes.NewBuffer(nil)
+	assert.Nil(t, jpeg.Encode(buf, img, nil))
+	frame := buf.Bytes()
+
+	// one part with Content-Length and one part without it
+	stream := fmt.Sprintf("--frame\r\nContent-Type: image/jpeg\r\nContent-Length: %d\r\n\r\n", len(frame))
+	stream = stream + string(frame) + "\r\n--frame\r\nContent-Type: image/jpeg\r\n\r\n" + string(frame) + "\r\n"
+
+	client, err := Open("test", ioutil.NopCloser(bytes.NewBufferString(stream)))
+	assert.Nil(t, err)
+
+	var frames [][]byte
+
+	cons := &Consumer{}
+	cons.Listen(func(msg interface{}) {
+		if b, ok := msg.([]byte); ok {
+			frames = append(frames, b)
+		}
+	})
+
+	media := client.GetMedias()[0]
+	assert.Equal(t, streamer.CodecJPEG, media.Codecs[0].Name)
+	cons.AddTrack(media, client.GetTrack(media, media.Codecs[0]))
+
+	_ = client.Handle()
+
+	assert.Len(t, frames, 2)
+
+	want, _ := jpeg.Decode(bytes.NewReader(frame))
+	for _, b := range frames {
+		got, err := jpeg.Decode(bytes.NewReader(b))
+		assert.Nil(t, err)
+		assert.Equal(t, want, got)
+	}
+}
+
+func TestRTPPay(t *testing.T) {
+	// grayscale JPEG isn't supported by RFC 2435
+	img := image.NewGray(image.Rect(0, 0, 16, 16))
+	buf := bytes.NewBuffer(nil)
+	assert.Nil(t, jpeg.Encode(buf, img, nil))
+
+	_, err := RTPPay(buf.Bytes(), PacketSize)
+	assert.NotNil(t, err)
+}
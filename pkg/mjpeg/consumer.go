@@ -42,7 +42,9 @@ func (c *Consumer) AddTrack(media *streamer.Media, track *streamer.Track) *strea
 		t := b[4]
 
 		// 3.1.7.  Restart Marker header
+		var dri uint16
 		if 64 <= t && t <= 127 {
+			dri = uint16(b[8])<<8 | uint16(b[9])
 			b = b[12:] // skip it
 		} else {
 			b = b[8:]
@@ -64,7 +66,7 @@ func (c *Consumer) AddTrack(media *streamer.Media, track *streamer.Track) *strea
 			w := uint16(packet.Payload[6]) << 3
 			h := uint16(packet.Payload[7]) << 3
 			//fmt.Printf("t: %d, q: %d, w: %d, h: %d\n", t, q, w, h)
-			header = MakeHeaders(t, w, h, lqt, cqt)
+			header = MakeHeaders(t, w, h, lqt, cqt, dri)
 		}
 
 		// 3.1.9.  JPEG Payload
@@ -138,14 +138,19 @@ var chm_ac_symbols = []byte{
 	0xf9, 0xfa,
 }
 
-func MakeHeaders(t byte, w, h uint16, lqt, cqt []byte) []byte {
+// MakeHeaders - restart interval (dri) is used with types 64-127
+func MakeHeaders(t byte, w, h uint16, lqt, cqt []byte, dri uint16) []byte {
 	// Appendix A from https://www.rfc-editor.org/rfc/rfc2435
 	p := []byte{0xFF, 0xD8}
 
 	p = MakeQuantHeader(p, lqt, 0)
 	p = MakeQuantHeader(p, cqt, 1)
 
-	if t == 0 {
+	if dri > 0 {
+		p = append(p, 0xFF, 0xDD, 0, 4, byte(dri>>8), byte(dri))
+	}
+
+	if t&0x3F == 0 {
 		t = 0x21
 	} else {
 		t = 0x22
@@ -0,0 +1,140 @@
+package mjpeg
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// PacketSize - max size of RTP payload
+const PacketSize = 1400
+
+// RTPPay splits JPEG frame to RTP payloads (RFC 2435). Only baseline YUV 4:2:2
+// and 4:2:0 frames are supported. Quantization tables are sent in the first
+// packet (Q=255), Huffman tables aren't sent, so receiver uses the standard ones
+func RTPPay(frame []byte, size int) ([][]byte, error) {
+	if len(frame) < 4 || frame[0] != 0xFF || frame[1] != 0xD8 {
+		return nil, errors.New("mjpeg: wrong JPEG start")
+	}
+
+	var t byte
+	var w, h, dri uint16
+	var lqtID, cqtID byte
+	var data []byte
+
+	tables := map[byte][]byte{}
+
+	for i := 2; data == nil; {
+		if i+4 > len(frame) || frame[i] != 0xFF {
+			return nil, errors.New("mjpeg: wrong JPEG marker")
+		}
+
+		marker := frame[i+1]
+		if marker == 0xFF {
+			i++ // fill byte
+			continue
+		}
+
+		end := i + 2 + int(binary.BigEndian.Uint16(frame[i+2:]))
+		if end > len(frame) {
+			return nil, errors.New("mjpeg: wrong JPEG segment size")
+		}
+
+		b := frame[i+4 : end]
+
+		switch marker {
+		case 0xDB: // DQT, segment can contain several tables
+			for len(b) >= 65 {
+				if b[0]>>4 != 0 {
+					return nil, errors.New("mjpeg: unsupported 16-bit quantization table")
+				}
+				tables[b[0]&0x0F] = b[1:65]
+				b = b[65:]
+			}
+
+		case 0xC0: // SOF0, baseline
+			if len(b) != 15 || b[0] != 8 || b[5] != 3 {
+				return nil, errors.New("mjpeg: unsupported JPEG components")
+			}
+
+			h = binary.BigEndian.Uint16(b[1:])
+			w = binary.BigEndian.Uint16(b[3:])
+			if w == 0 || h == 0 || w > 2040 || h > 2040 {
+				return nil, errors.New("mjpeg: unsupported JPEG size")
+			}
+
+			// luma sampling, chroma must be 1x1
+			switch b[7] {
+			case 0x21:
+				t = 0
+			case 0x22:
+				t = 1
+			default:
+				return nil, errors.New("mjpeg: unsupported JPEG sampling")
+			}
+			if b[10] != 0x11 || b[13] != 0x11 || b[11] != b[14] {
+				return nil, errors.New("mjpeg: unsupported JPEG sampling")
+			}
+
+			lqtID, cqtID = b[8], b[11]
+
+		case 0xC1, 0xC2, 0xC3, 0xC5, 0xC6, 0xC7, 0xC9, 0xCA, 0xCB, 0xCD, 0xCE, 0xCF:
+			return nil, errors.New("mjpeg: only baseline JPEG is supported")
+
+		case 0xDD: // DRI
+			if len(b) >= 2 {
+				dri = binary.BigEndian.Uint16(b)
+			}
+
+		case 0xDA: // SOS, entropy-coded data until EOI
+			data = frame[end:]
+			if n := len(data); n >= 2 && data[n-2] == 0xFF && data[n-1] == 0xD9 {
+				data = data[:n-2]
+			}
+		}
+
+		i = end
+	}
+
+	lqt, cqt := tables[lqtID], tables[cqtID]
+	if w == 0 || lqt == nil || cqt == nil || len(data) == 0 {
+		return nil, errors.New("mjpeg: can't find JPEG params")
+	}
+
+	// 3.1.  JPEG header
+	header := []byte{0, 0, 0, 0, t, 255, byte(w >> 3), byte(h >> 3)}
+
+	// 3.1.7.  Restart Marker header, F=1, L=1, count=0x3FFF
+	if dri > 0 {
+		header[4] += 64
+		header = append(header, byte(dri>>8), byte(dri), 0xFF, 0xFF)
+	}
+
+	var payloads [][]byte
+
+	for offset := 0; offset < len(data); {
+		payload := make([]byte, len(header), size)
+		copy(payload, header)
+
+		// 3.1.2.  Fragment Offset, 24 bit
+		payload[1] = byte(offset >> 16)
+		payload[2] = byte(offset >> 8)
+		payload[3] = byte(offset)
+
+		// 3.1.8.  Quantization Table header
+		if offset == 0 {
+			payload = append(payload, 0, 0, 0, 128)
+			payload = append(payload, lqt...)
+			payload = append(payload, cqt...)
+		}
+
+		n := size - len(payload)
+		if n > len(data)-offset {
+			n = len(data) - offset
+		}
+
+		payloads = append(payloads, append(payload, data[offset:offset+n]...))
+		offset += n
+	}
+
+	return payloads, nil
+}
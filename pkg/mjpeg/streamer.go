@@ -0,0 +1,37 @@
+package mjpeg
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/AlexxIT/go2rtc/pkg/streamer"
+)
+
+func (c *Client) GetMedias() []*streamer.Media {
+	return c.medias
+}
+
+func (c *Client) GetTrack(media *streamer.Media, codec *streamer.Codec) *streamer.Track {
+	if c.track.Codec == codec {
+		return c.track
+	}
+	panic(fmt.Sprintf("wrong media/codec: %+v %+v", media, codec))
+}
+
+func (c *Client) Start() error {
+	return c.Handle()
+}
+
+func (c *Client) Stop() error {
+	return c.Close()
+}
+
+func (c *Client) MarshalJSON() ([]byte, error) {
+	v := map[string]interface{}{
+		streamer.JSONReceive: c.receive,
+		streamer.JSONType:    "MJPEG client producer",
+		"url":                c.URI,
+		"media:0":            c.medias[0].String(),
+		"track:0":            c.track.String(),
+	}
+	return json.Marshal(v)
+}
@@ -0,0 +1,168 @@
+package mp4
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/AlexxIT/go2rtc/pkg/aac"
+	"github.com/AlexxIT/go2rtc/pkg/h264"
+	"github.com/AlexxIT/go2rtc/pkg/streamer"
+	"github.com/pion/rtp"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// Client - producer from fragmented MP4 stream, ex. HLS with fMP4 segments.
+// H264 and H265 are sent as AVC packets, one NAL unit per packet
+type Client struct {
+	streamer.Element
+
+	URI string
+
+	medias []*streamer.Media
+	tracks []*streamer.Track
+
+	demuxer  *Demuxer
+	closer   io.Closer
+	proto    string
+	writers  map[*streamer.Codec]streamer.WriterFunc
+	timeline streamer.Timeline
+	closed   bool
+
+	receive int
+}
+
+func Open(uri, proto string, rd io.ReadCloser) (*Client, error) {
+	c := &Client{
+		URI:     uri,
+		demuxer: NewDemuxer(rd),
+		closer:  rd,
+		proto:   proto,
+		writers: map[*streamer.Codec]streamer.WriterFunc{},
+	}
+
+	if err := c.demuxer.ReadInit(); err != nil {
+		_ = rd.Close()
+		return nil, err
+	}
+
+	for _, demuxTrack := range c.demuxer.Tracks {
+		codec := demuxTrack.Codec
+
+		media := &streamer.Media{
+			Kind:      streamer.GetKind(codec.Name),
+			Direction: streamer.DirectionSendonly,
+			Codecs:    []*streamer.Codec{codec},
+		}
+		c.medias = append(c.medias, media)
+
+		track := &streamer.Track{Codec: codec, Direction: media.Direction}
+		c.tracks = append(c.tracks, track)
+
+		if codec.Name == streamer.CodecAAC {
+			// raw access units to AU headers (RFC 3640)
+			c.writers[codec] = aac.RTPPay()(track.WriteRTP)
+		} else {
+			c.writers[codec] = track.WriteRTP
+		}
+	}
+
+	c.Fire(streamer.StateReady)
+
+	return c, nil
+}
+
+func (c *Client) GetMedias() []*streamer.Media {
+	return c.medias
+}
+
+func (c *Client) GetTrack(media *streamer.Media, codec *streamer.Codec) *streamer.Track {
+	for _, track := range c.tracks {
+		if track.Codec == codec {
+			return track
+		}
+	}
+	panic(fmt.Sprintf("wrong media/codec: %+v %+v", media, codec))
+}
+
+func (c *Client) Start() error {
+	defer c.Fire(streamer.StateNull)
+
+	c.Fire(streamer.StatePlaying)
+
+	for {
+		packets, err := c.demuxer.ReadPackets()
+		if err != nil {
+			if c.closed {
+				return nil
+			}
+			return err
+		}
+
+		// samples of tracks aren't interleaved inside the fragment
+		sort.SliceStable(packets, func(i, j int) bool {
+			return packets[i].Time() < packets[j].Time()
+		})
+
+		for _, pkt := range packets {
+			c.writePacket(pkt)
+		}
+	}
+}
+
+func (c *Client) Stop() error {
+	c.closed = true
+	return c.closer.Close()
+}
+
+func (c *Client) MarshalJSON() ([]byte, error) {
+	v := map[string]interface{}{
+		streamer.JSONReceive: c.receive,
+		streamer.JSONType:    c.proto + " client producer",
+		"url":                c.URI,
+	}
+	for i, media := range c.medias {
+		k := "media:" + strconv.Itoa(i)
+		v[k] = media.String()
+	}
+	for i, track := range c.tracks {
+		k := "track:" + strconv.Itoa(i)
+		v[k] = track.String()
+	}
+	return json.Marshal(v)
+}
+
+func (c *Client) writePacket(pkt *DemuxPacket) {
+	c.receive += len(pkt.Data)
+
+	if len(pkt.Data) < 4 {
+		return
+	}
+
+	codec := pkt.Track.Codec
+
+	write := c.writers[codec]
+	if write == nil {
+		return // new codec after discontinuity
+	}
+
+	ts := c.timeline.Time(pkt.Time())
+	c.timeline.Wait(ts)
+
+	timestamp := streamer.RTPTime(ts, codec.ClockRate)
+
+	if codec.Name == streamer.CodecAAC {
+		_ = write(&rtp.Packet{
+			Header:  rtp.Header{Timestamp: timestamp},
+			Payload: pkt.Data,
+		})
+		return
+	}
+
+	for _, payload := range h264.SplitAVC(pkt.Data) {
+		_ = write(&rtp.Packet{
+			Header:  rtp.Header{Timestamp: timestamp},
+			Payload: payload,
+		})
+	}
+}
@@ -3,10 +3,13 @@ package mp4
 import (
 	"encoding/json"
 	"fmt"
+	"github.com/AlexxIT/go2rtc/pkg/aac"
 	"github.com/AlexxIT/go2rtc/pkg/h264"
 	"github.com/AlexxIT/go2rtc/pkg/h265"
 	"github.com/AlexxIT/go2rtc/pkg/streamer"
 	"github.com/pion/rtp"
+	"sync"
+	"time"
 )
 
 type Consumer struct {
@@ -15,9 +18,13 @@ type Consumer struct {
 	UserAgent  string
 	RemoteAddr string
 
+	// WallClock adds prft boxes with capture time if source provides it
+	WallClock bool
+
 	muxer  *Muxer
 	codecs []*streamer.Codec
 	start  bool
+	mx     sync.Mutex
 
 	send int
 }
@@ -32,17 +39,19 @@ func (c *Consumer) GetMedias() []*streamer.Media {
 				{Name: streamer.CodecH265, ClockRate: 90000},
 			},
 		},
-		//{
-		//	Kind:      streamer.KindAudio,
-		//	Direction: streamer.DirectionRecvonly,
-		//	Codecs: []*streamer.Codec{
-		//		{Name: streamer.CodecAAC, ClockRate: 16000},
-		//	},
-		//},
+		{
+			Kind:      streamer.KindAudio,
+			Direction: streamer.DirectionRecvonly,
+			Codecs: []*streamer.Codec{
+				{Name: streamer.CodecAAC},
+			},
+		},
 	}
 }
 
 func (c *Consumer) AddTrack(media *streamer.Media, track *streamer.Track) *streamer.Track {
+	trackID := byte(len(c.codecs))
+
 	codec := track.Codec
 	switch codec.Name {
 	case streamer.CodecH264:
@@ -53,20 +62,23 @@ func (c *Consumer) AddTrack(media *streamer.Media, track *streamer.Track) *strea
 				return nil
 			}
 
+			var keyframe bool
+
 			switch h264.NALUType(packet.Payload) {
 			case h264.NALUTypeIFrame:
+				c.mx.Lock()
 				c.start = true
+				c.mx.Unlock()
+				keyframe = true
 			case h264.NALUTypePFrame:
-				if !c.start {
+				if !c.started() {
 					return nil
 				}
 			default:
 				return nil
 			}
 
-			buf := c.muxer.Marshal(packet)
-			c.send += len(buf)
-			c.Fire(buf)
+			c.fire(trackID, track, packet, keyframe)
 
 			return nil
 		}
@@ -86,17 +98,17 @@ func (c *Consumer) AddTrack(media *streamer.Media, track *streamer.Track) *strea
 				return nil
 			}
 
-			if !c.start {
-				if h265.IsKeyframe(packet.Payload) {
-					c.start = true
-				} else {
-					return nil
-				}
+			keyframe := h265.IsKeyframe(packet.Payload)
+
+			if keyframe {
+				c.mx.Lock()
+				c.start = true
+				c.mx.Unlock()
+			} else if !c.started() {
+				return nil
 			}
 
-			buf := c.muxer.Marshal(packet)
-			c.send += len(buf)
-			c.Fire(buf)
+			c.fire(trackID, track, packet, keyframe)
 
 			return nil
 		}
@@ -107,6 +119,24 @@ func (c *Consumer) AddTrack(media *streamer.Media, track *streamer.Track) *strea
 		}
 
 		return track.Bind(push)
+
+	case streamer.CodecAAC:
+		c.codecs = append(c.codecs, track.Codec)
+
+		push := func(packet *rtp.Packet) error {
+			hasVideo, ok := c.audioStart()
+			if !ok {
+				return nil
+			}
+
+			// any audio sample is keyframe for stream without video
+			c.fire(trackID, track, packet, !hasVideo)
+
+			return nil
+		}
+
+		wrapper := aac.RTPDepay(track)
+		return track.Bind(wrapper(push))
 	}
 
 	fmt.Printf("[rtmp] unsupported codec: %+v\n", track.Codec)
@@ -125,6 +155,64 @@ func (c *Consumer) Init() ([]byte, error) {
 	return c.muxer.GetInit(c.codecs)
 }
 
+// Sample event is fired before each fragment, so fragments can be grouped
+// to segments starting from keyframe (ex. for HLS)
+type Sample struct {
+	TrackID  byte
+	Keyframe bool
+	// Time - decode time of the sample from the start of the track
+	Time time.Duration
+}
+
+// fire sends fragment, tracks can be pushed from different goroutines,
+// so fragments are muxed and sent under lock to keep their order
+func (c *Consumer) fire(trackID byte, track *streamer.Track, packet *rtp.Packet, keyframe bool) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	var buf []byte
+	if c.WallClock {
+		buf = c.muxer.MarshalPRFT(trackID, track.WallClock(packet.Timestamp))
+	}
+	buf = append(buf, c.muxer.Marshal(trackID, packet)...)
+	c.send += len(buf)
+
+	c.Fire(Sample{
+		TrackID:  trackID,
+		Keyframe: keyframe,
+		Time:     c.muxer.DecodeTime(trackID, track.Codec.ClockRate),
+	})
+	c.Fire(buf)
+}
+
+func (c *Consumer) started() bool {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return c.start
+}
+
+// audioStart - audio waits for video keyframe, if there is video track
+func (c *Consumer) audioStart() (hasVideo bool, ok bool) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if c.hasVideo() {
+		return true, c.start
+	}
+
+	c.start = true
+	return false, true
+}
+
+func (c *Consumer) hasVideo() bool {
+	for _, codec := range c.codecs {
+		if codec.Name == streamer.CodecH264 || codec.Name == streamer.CodecH265 {
+			return true
+		}
+	}
+	return false
+}
+
 //
 
 func (c *Consumer) MarshalJSON() ([]byte, error) {
@@ -0,0 +1,590 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"errors"
+	"github.com/AlexxIT/go2rtc/pkg/aac"
+	"github.com/AlexxIT/go2rtc/pkg/h264"
+	"github.com/AlexxIT/go2rtc/pkg/h265"
+	"github.com/AlexxIT/go2rtc/pkg/streamer"
+	"io"
+	"time"
+)
+
+// Demuxer - reader of fragmented MP4 stream: init segment (moov) and
+// fragments (moof + mdat), ex. HLS with fMP4 segments
+type Demuxer struct {
+	Tracks []*DemuxTrack // supported tracks from the last moov
+
+	rd      io.Reader
+	samples []*demuxSample // from the last moof
+	moofLen int
+}
+
+type DemuxTrack struct {
+	ID        uint32
+	Codec     *streamer.Codec
+	TimeScale uint32
+
+	duration uint32 // default sample duration from trex
+	size     uint32 // default sample size from trex
+}
+
+// MaxSamples - limit of samples in one track run without per-sample fields,
+// sample count is from the stream, so it can't be trusted
+const MaxSamples = 10000
+
+// DemuxPacket - one sample of the track
+type DemuxPacket struct {
+	Track *DemuxTrack
+	DTS   uint64 // in the track time scale
+	Data  []byte
+}
+
+// Time - DTS as duration
+func (p *DemuxPacket) Time() time.Duration {
+	scale := uint64(p.Track.TimeScale)
+	return time.Duration(p.DTS/scale)*time.Second +
+		time.Duration(p.DTS%scale)*time.Second/time.Duration(scale)
+}
+
+type demuxSample struct {
+	track  *DemuxTrack
+	dts    uint64
+	offset int // from the start of mdat data, -1 - after previous sample
+	size   uint32
+}
+
+func NewDemuxer(rd io.Reader) *Demuxer {
+	return &Demuxer{rd: rd}
+}
+
+// ReadInit reads boxes until init segment with supported tracks
+func (d *Demuxer) ReadInit() error {
+	for {
+		typ, body, err := d.readBox()
+		if err != nil {
+			return err
+		}
+		if typ == "moov" {
+			if err = d.parseMOOV(body); err != nil {
+				return err
+			}
+			return nil
+		}
+	}
+}
+
+// ReadPackets reads boxes until next mdat and returns its samples,
+// new moov (ex. after discontinuity) updates tracks
+func (d *Demuxer) ReadPackets() ([]*DemuxPacket, error) {
+	for {
+		typ, body, err := d.readBox()
+		if err != nil {
+			return nil, err
+		}
+
+		switch typ {
+		case "moov":
+			if err = d.parseMOOV(body); err != nil {
+				return nil, err
+			}
+		case "moof":
+			d.moofLen = 8 + len(body)
+			d.parseMOOF(body)
+		case "mdat":
+			packets := d.packets(body)
+			d.samples = nil
+			return packets, nil
+		}
+	}
+}
+
+func (d *Demuxer) readBox() (string, []byte, error) {
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(d.rd, header); err != nil {
+		return "", nil, err
+	}
+
+	size := uint64(binary.BigEndian.Uint32(header))
+	typ := string(header[4:])
+
+	switch size {
+	case 0:
+		return "", nil, errors.New("mp4: box until end of file not supported")
+	case 1:
+		if _, err := io.ReadFull(d.rd, header); err != nil {
+			return "", nil, err
+		}
+		size = binary.BigEndian.Uint64(header) - 8
+	}
+
+	if size < 8 || size > 0x10000000 {
+		return "", nil, errors.New("mp4: wrong box size")
+	}
+
+	body := make([]byte, size-8)
+	if _, err := io.ReadFull(d.rd, body); err != nil {
+		return "", nil, err
+	}
+
+	return typ, body, nil
+}
+
+func (d *Demuxer) parseMOOV(b []byte) error {
+	var tracks []*DemuxTrack
+
+	for _, box := range boxes(b) {
+		switch box.typ {
+		case "trak":
+			if track := parseTRAK(box.body); track != nil {
+				tracks = append(tracks, track)
+			}
+		case "mvex":
+			for _, trex := range boxes(box.body) {
+				if trex.typ != "trex" || len(trex.body) < 24 {
+					continue
+				}
+				id := binary.BigEndian.Uint32(trex.body[4:])
+				for _, track := range tracks {
+					if track.ID == id {
+						track.duration = binary.BigEndian.Uint32(trex.body[12:])
+						track.size = binary.BigEndian.Uint32(trex.body[16:])
+					}
+				}
+			}
+		}
+	}
+
+	if len(tracks) == 0 {
+		return errors.New("mp4: can't find supported codecs")
+	}
+
+	// keep codecs from the first init segment, so producer tracks stay the same
+	if d.Tracks != nil {
+		for _, track := range tracks {
+			if old := d.getTrack(track.ID); old != nil && old.Codec.Name == track.Codec.Name {
+				track.Codec = old.Codec
+			}
+		}
+	}
+
+	d.Tracks = tracks
+
+	return nil
+}
+
+func (d *Demuxer) parseMOOF(b []byte) {
+	d.samples = nil
+
+	for _, traf := range boxes(b) {
+		if traf.typ != "traf" {
+			continue
+		}
+
+		var track *DemuxTrack
+		var duration, size uint32
+		var dts uint64
+
+		for _, box := range boxes(traf.body) {
+			b := box.body
+
+			switch box.typ {
+			case "tfhd":
+				if len(b) < 8 {
+					break
+				}
+				flags := binary.BigEndian.Uint32(b) & 0xFFFFFF
+				if track = d.getTrack(binary.BigEndian.Uint32(b[4:])); track == nil {
+					break
+				}
+				duration, size = track.duration, track.size
+
+				i := 8
+				if flags&0x01 != 0 {
+					i += 8 // base data offset
+				}
+				if flags&0x02 != 0 {
+					i += 4 // sample description index
+				}
+				if flags&0x08 != 0 && i+4 <= len(b) {
+					duration = binary.BigEndian.Uint32(b[i:])
+					i += 4
+				}
+				if flags&0x10 != 0 && i+4 <= len(b) {
+					size = binary.BigEndian.Uint32(b[i:])
+				}
+
+			case "tfdt":
+				if len(b) >= 12 && b[0] == 1 {
+					dts = binary.BigEndian.Uint64(b[4:])
+				} else if len(b) >= 8 {
+					dts = uint64(binary.BigEndian.Uint32(b[4:]))
+				}
+
+			case "trun":
+				if track == nil || len(b) < 8 {
+					break
+				}
+				flags := binary.BigEndian.Uint32(b) & 0xFFFFFF
+				count := int(binary.BigEndian.Uint32(b[4:]))
+
+				offset := -1
+				i := 8
+				if flags&0x01 != 0 && i+4 <= len(b) {
+					// data offset from the start of moof
+					offset = int(int32(binary.BigEndian.Uint32(b[i:]))) - d.moofLen - 8
+					i += 4
+				}
+				if flags&0x04 != 0 {
+					i += 4 // first sample flags
+				}
+
+				// each sample has its fields in the box, so count can't be more
+				var fields int
+				for _, flag := range []uint32{0x100, 0x200, 0x400, 0x800} {
+					if flags&flag != 0 {
+						fields += 4
+					}
+				}
+				if fields > 0 {
+					if limit := (len(b) - i) / fields; count > limit {
+						count = limit
+					}
+				} else if count > MaxSamples {
+					count = MaxSamples
+				}
+
+				for n := 0; n < count && i <= len(b); n++ {
+					sample := &demuxSample{track: track, dts: dts, offset: offset, size: size}
+					sampleDuration := duration
+
+					if flags&0x100 != 0 && i+4 <= len(b) {
+						sampleDuration = binary.BigEndian.Uint32(b[i:])
+						i += 4
+					}
+					if flags&0x200 != 0 && i+4 <= len(b) {
+						sample.size = binary.BigEndian.Uint32(b[i:])
+						i += 4
+					}
+					if flags&0x400 != 0 {
+						i += 4 // sample flags
+					}
+					if flags&0x800 != 0 {
+						i += 4 // composition time offset
+					}
+
+					d.samples = append(d.samples, sample)
+
+					dts += uint64(sampleDuration)
+					offset = -1
+				}
+			}
+		}
+	}
+}
+
+func (d *Demuxer) packets(mdat []byte) []*DemuxPacket {
+	var packets []*DemuxPacket
+
+	var i int
+	for _, sample := range d.samples {
+		if sample.offset >= 0 {
+			i = sample.offset
+		}
+
+		end := i + int(sample.size)
+		if i < 0 || end > len(mdat) {
+			break
+		}
+
+		packets = append(packets, &DemuxPacket{
+			Track: sample.track, DTS: sample.dts, Data: mdat[i:end],
+		})
+
+		i = end
+	}
+
+	return packets
+}
+
+func (d *Demuxer) getTrack(id uint32) *DemuxTrack {
+	for _, track := range d.Tracks {
+		if track.ID == id {
+			return track
+		}
+	}
+	return nil
+}
+
+type box struct {
+	typ  string
+	body []byte
+}
+
+// boxes returns child boxes of the container box
+func boxes(b []byte) (items []box) {
+	for len(b) >= 8 {
+		size := int(binary.BigEndian.Uint32(b))
+		if size < 8 || size > len(b) {
+			break
+		}
+		items = append(items, box{typ: string(b[4:8]), body: b[8:size]})
+		b = b[size:]
+	}
+	return
+}
+
+// child returns first box by path, ex. child(trak, "mdia", "minf", "stbl")
+func child(b []byte, path ...string) []byte {
+	for _, typ := range path {
+		var ok bool
+		for _, box := range boxes(b) {
+			if box.typ == typ {
+				b = box.body
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return nil
+		}
+	}
+	return b
+}
+
+func parseTRAK(b []byte) *DemuxTrack {
+	track := &DemuxTrack{}
+
+	// track ID after version, flags, creation and modification time
+	if tkhd := child(b, "tkhd"); len(tkhd) >= 24 && tkhd[0] == 1 {
+		track.ID = binary.BigEndian.Uint32(tkhd[20:])
+	} else if len(tkhd) >= 16 {
+		track.ID = binary.BigEndian.Uint32(tkhd[12:])
+	}
+
+	mdia := child(b, "mdia")
+	if mdhd := child(mdia, "mdhd"); len(mdhd) >= 24 && mdhd[0] == 1 {
+		track.TimeScale = binary.BigEndian.Uint32(mdhd[20:])
+	} else if len(mdhd) >= 16 {
+		track.TimeScale = binary.BigEndian.Uint32(mdhd[12:])
+	}
+
+	if track.TimeScale == 0 {
+		return nil
+	}
+
+	// skip version, flags and entry count
+	stsd := child(mdia, "minf", "stbl", "stsd")
+	if len(stsd) < 8 {
+		return nil
+	}
+
+	for _, entry := range boxes(stsd[8:]) {
+		switch entry.typ {
+		case "avc1", "avc3":
+			track.Codec = parseAVCC(child(sampleEntry(entry.body, 78), "avcC"))
+		case "hvc1", "hev1":
+			track.Codec = parseHVCC(child(sampleEntry(entry.body, 78), "hvcC"))
+		case "mp4a":
+			track.Codec = parseESDS(child(sampleEntry(entry.body, 28), "esds"))
+		}
+		if track.Codec != nil {
+			return track
+		}
+	}
+
+	return nil
+}
+
+// sampleEntry returns child boxes of the sample entry after its fields
+func sampleEntry(b []byte, size int) []byte {
+	if len(b) < size {
+		return nil
+	}
+	return b[size:]
+}
+
+func parseAVCC(b []byte) *streamer.Codec {
+	// version, profile, compatibility, level, NAL length size, SPS count
+	if len(b) < 6 {
+		return nil
+	}
+
+	var sps, pps []byte
+
+	i := 6
+	for n := int(b[5] & 0x1F); n > 0; n-- {
+		if sps, i = readNAL(b, i); sps == nil {
+			return nil
+		}
+	}
+
+	if i >= len(b) {
+		return nil
+	}
+
+	n := int(b[i])
+	i++
+	for ; n > 0; n-- {
+		if pps, i = readNAL(b, i); pps == nil {
+			return nil
+		}
+	}
+
+	if sps == nil || pps == nil {
+		return nil
+	}
+
+	return &streamer.Codec{
+		Name:        streamer.CodecH264,
+		ClockRate:   90000,
+		FmtpLine:    h264.FmtpLine(sps, pps),
+		PayloadType: h264.PayloadTypeAVC,
+	}
+}
+
+func parseHVCC(b []byte) *streamer.Codec {
+	// config fields, count of NAL arrays
+	if len(b) < 23 {
+		return nil
+	}
+
+	var vps, sps, pps []byte
+
+	i := 23
+	for arrays := int(b[22]); arrays > 0 && i+3 <= len(b); arrays-- {
+		typ := b[i] & 0x3F
+		n := int(binary.BigEndian.Uint16(b[i+1:]))
+		i += 3
+
+		for ; n > 0; n-- {
+			var nal []byte
+			if nal, i = readNAL(b, i); nal == nil {
+				return nil
+			}
+
+			switch typ {
+			case h265.NALUnitTypeVPS:
+				vps = nal
+			case h265.NALUnitTypeSPS:
+				sps = nal
+			case h265.NALUnitTypePPS:
+				pps = nal
+			}
+		}
+	}
+
+	if vps == nil || sps == nil || pps == nil {
+		return nil
+	}
+
+	return &streamer.Codec{
+		Name:        streamer.CodecH265,
+		ClockRate:   90000,
+		FmtpLine:    h265.FmtpLine(vps, sps, pps),
+		PayloadType: h264.PayloadTypeAVC,
+	}
+}
+
+// readNAL with 2 bytes size
+func readNAL(b []byte, i int) ([]byte, int) {
+	if i+2 > len(b) {
+		return nil, i
+	}
+	size := int(binary.BigEndian.Uint16(b[i:]))
+	i += 2
+	if i+size > len(b) {
+		return nil, i
+	}
+	return b[i : i+size], i + size
+}
+
+func parseESDS(b []byte) *streamer.Codec {
+	// skip version and flags
+	if len(b) < 4 {
+		return nil
+	}
+	b = b[4:]
+
+	// ES_Descriptor => DecoderConfigDescriptor => DecoderSpecificInfo
+	for _, tag := range []byte{3, 4, 5} {
+		var ok bool
+		for len(b) >= 2 {
+			t := b[0]
+
+			// size with variable length
+			var size, i int
+			for i = 1; i < len(b) && i <= 4; i++ {
+				size = size<<7 | int(b[i]&0x7F)
+				if b[i]&0x80 == 0 {
+					break
+				}
+			}
+			i++
+
+			if i > len(b) {
+				return nil
+			}
+			// some muxers (ex. vdk) write wrong length for the last descriptors
+			if i+size > len(b) {
+				size = len(b) - i
+			}
+
+			if t != tag {
+				b = b[i+size:]
+				continue
+			}
+
+			b = b[i : i+size]
+
+			switch tag {
+			case 3:
+				// ES_ID and flags with optional fields
+				if len(b) < 3 {
+					return nil
+				}
+				flags := b[2]
+				i = 3
+				if flags&0x80 != 0 {
+					i += 2
+				}
+				if flags&0x40 != 0 && i < len(b) {
+					i += 1 + int(b[i])
+				}
+				if flags&0x20 != 0 {
+					i += 2
+				}
+				if i > len(b) {
+					return nil
+				}
+				b = b[i:]
+			case 4:
+				// object type, stream type, buffer size, bitrates
+				if len(b) < 13 {
+					return nil
+				}
+				b = b[13:]
+			}
+
+			ok = true
+			break
+		}
+		if !ok {
+			return nil
+		}
+	}
+
+	sampleRate, channels := aac.ConfigInfo(b)
+	if sampleRate == 0 {
+		return nil
+	}
+
+	return &streamer.Codec{
+		Name:        streamer.CodecAAC,
+		ClockRate:   sampleRate,
+		Channels:    channels,
+		FmtpLine:    aac.FmtpLine(b),
+		PayloadType: 96,
+	}
+}
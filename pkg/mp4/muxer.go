@@ -3,6 +3,7 @@ package mp4
 import (
 	"encoding/binary"
 	"fmt"
+	"github.com/AlexxIT/go2rtc/pkg/aac"
 	"github.com/AlexxIT/go2rtc/pkg/h264"
 	"github.com/AlexxIT/go2rtc/pkg/h265"
 	"github.com/AlexxIT/go2rtc/pkg/streamer"
@@ -12,12 +13,13 @@ import (
 	"github.com/deepch/vdk/format/mp4/mp4io"
 	"github.com/deepch/vdk/format/mp4f/mp4fio"
 	"github.com/pion/rtp"
+	"time"
 )
 
 type Muxer struct {
 	fragIndex uint32
-	dts       uint64
-	pts       uint32
+	dts       []uint64 // for each track
+	pts       []uint32
 	data      []byte
 	total     int
 }
@@ -25,23 +27,46 @@ type Muxer struct {
 func (m *Muxer) MimeType(codecs []*streamer.Codec) string {
 	s := `video/mp4; codecs="`
 
-	for _, codec := range codecs {
+	for i, codec := range codecs {
+		if i > 0 {
+			s += ","
+		}
+
 		switch codec.Name {
 		case streamer.CodecH264:
 			s += "avc1." + h264.GetProfileLevelID(codec.FmtpLine)
 		case streamer.CodecH265:
 			// +Safari +Chrome +Edge -iOS15 -Android13
 			s += "hvc1.1.6.L93.B0" // hev1.1.6.L93.B0
+		case streamer.CodecAAC:
+			// object type from config, AAC-LC by default
+			objectType := aac.ObjectType(aac.GetConfig(codec.FmtpLine))
+			if objectType == 0 {
+				objectType = 2
+			}
+			s += fmt.Sprintf("mp4a.40.%d", objectType)
 		}
 	}
 
 	return s + `"`
 }
 
+// GetInit returns init segment, track ID is codec index in the list
 func (m *Muxer) GetInit(codecs []*streamer.Codec) ([]byte, error) {
 	moov := MOOV()
 
-	for _, codec := range codecs {
+	m.dts = make([]uint64, len(codecs))
+	m.pts = make([]uint32, len(codecs))
+
+	for i, codec := range codecs {
+		trackID := int32(i + 1)
+
+		moov.MovieExtend.Tracks = append(moov.MovieExtend.Tracks, &mp4io.TrackExtend{
+			TrackId:               uint32(trackID),
+			DefaultSampleDescIdx:  1,
+			DefaultSampleDuration: 40,
+		})
+
 		switch codec.Name {
 		case streamer.CodecH264:
 			sps, pps := h264.GetParameterSet(codec.FmtpLine)
@@ -58,7 +83,7 @@ func (m *Muxer) GetInit(codecs []*streamer.Codec) ([]byte, error) {
 			width := codecData.Width()
 			height := codecData.Height()
 
-			trak := TRAK()
+			trak := TRAK(trackID)
 			trak.Media.Header.TimeScale = int32(codec.ClockRate)
 			trak.Header.TrackWidth = float64(width)
 			trak.Header.TrackHeight = float64(height)
@@ -101,7 +126,7 @@ func (m *Muxer) GetInit(codecs []*streamer.Codec) ([]byte, error) {
 			width := codecData.Width()
 			height := codecData.Height()
 
-			trak := TRAK()
+			trak := TRAK(trackID)
 			trak.Media.Header.TimeScale = int32(codec.ClockRate)
 			trak.Header.TrackWidth = float64(width)
 			trak.Header.TrackHeight = float64(height)
@@ -128,6 +153,35 @@ func (m *Muxer) GetInit(codecs []*streamer.Codec) ([]byte, error) {
 				Name:    []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 'm', 'a', 'i', 'n', 0},
 			}
 
+			moov.Tracks = append(moov.Tracks, trak)
+
+		case streamer.CodecAAC:
+			config := aac.GetConfig(codec.FmtpLine)
+			if config == nil {
+				return nil, fmt.Errorf("empty AAC config: %#v", codec)
+			}
+
+			trak := TRAK(trackID)
+			trak.Media.Header.TimeScale = int32(codec.ClockRate)
+			trak.Header.Volume = 1
+			trak.Header.AlternateGroup = 1
+
+			trak.Media.Info.Sound = &mp4io.SoundMediaInfo{}
+			trak.Media.Info.Sample.SampleDesc.MP4ADesc = &mp4io.MP4ADesc{
+				DataRefIdx:       1,
+				NumberOfChannels: int16(codec.Channels),
+				SampleSize:       16,
+				SampleRate:       float64(codec.ClockRate),
+				Conf: &mp4io.ElemStreamDesc{
+					DecConfig: config,
+				},
+			}
+
+			trak.Media.Handler = &mp4io.HandlerRefer{
+				SubType: [4]byte{'s', 'o', 'u', 'n'},
+				Name:    []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 'm', 'a', 'i', 'n', 0},
+			}
+
 			moov.Tracks = append(moov.Tracks, trak)
 		}
 	}
@@ -139,13 +193,14 @@ func (m *Muxer) GetInit(codecs []*streamer.Codec) ([]byte, error) {
 }
 
 func (m *Muxer) Rewind() {
-	m.dts = 0
-	m.pts = 0
+	for i := range m.dts {
+		m.dts[i] = 0
+		m.pts[i] = 0
+	}
 }
 
-func (m *Muxer) Marshal(packet *rtp.Packet) []byte {
-	trackID := uint8(1)
-
+// Marshal returns fragment with one sample for the track with index from GetInit
+func (m *Muxer) Marshal(trackID byte, packet *rtp.Packet) []byte {
 	run := &mp4fio.TrackFragRun{
 		Flags:            0x000b05,
 		FirstSampleFlags: uint32(fmp4io.SampleNoDependencies),
@@ -160,12 +215,12 @@ func (m *Muxer) Marshal(packet *rtp.Packet) []byte {
 		Tracks: []*mp4fio.TrackFrag{
 			{
 				Header: &mp4fio.TrackFragHeader{
-					Data: []byte{0x00, 0x02, 0x00, 0x20, 0x00, 0x00, 0x00, trackID, 0x01, 0x01, 0x00, 0x00},
+					Data: []byte{0x00, 0x02, 0x00, 0x20, 0x00, 0x00, 0x00, trackID + 1, 0x01, 0x01, 0x00, 0x00},
 				},
 				DecodeTime: &mp4fio.TrackFragDecodeTime{
 					Version: 1,
 					Flags:   0,
-					Time:    m.dts,
+					Time:    m.dts[trackID],
 				},
 				Run: run,
 			},
@@ -178,12 +233,11 @@ func (m *Muxer) Marshal(packet *rtp.Packet) []byte {
 	}
 
 	newTime := packet.Timestamp
-	if m.pts > 0 {
-		//m.dts += uint64(newTime - m.pts)
-		entry.Duration = newTime - m.pts
-		m.dts += uint64(entry.Duration)
+	if m.pts[trackID] > 0 {
+		entry.Duration = newTime - m.pts[trackID]
+		m.dts[trackID] += uint64(entry.Duration)
 	}
-	m.pts = newTime
+	m.pts[trackID] = newTime
 
 	// important before moof.Len()
 	run.Entries = append(run.Entries, entry)
@@ -207,3 +261,31 @@ func (m *Muxer) Marshal(packet *rtp.Packet) []byte {
 
 	return buf
 }
+
+// DecodeTime of the last marshaled sample of the track
+func (m *Muxer) DecodeTime(trackID byte, clockRate uint32) time.Duration {
+	if clockRate == 0 {
+		return 0
+	}
+	return time.Duration(m.dts[trackID]) * time.Second / time.Duration(clockRate)
+}
+
+// MarshalPRFT returns Producer Reference Time box for the next fragment
+// of the track or nil if capture time is unknown
+func (m *Muxer) MarshalPRFT(trackID byte, wallClock time.Time) []byte {
+	if wallClock.IsZero() {
+		return nil
+	}
+
+	// ISO/IEC 14496-12. 8.16.5 Producer Reference Time Box
+	// version 1 (64-bit media time), flags 0x18 (time of sample capture)
+	b := make([]byte, 32)
+	binary.BigEndian.PutUint32(b, 32)
+	copy(b[4:], "prft")
+	b[8] = 1
+	b[11] = 0x18
+	binary.BigEndian.PutUint32(b[12:], uint32(trackID)+1) // reference track ID
+	binary.BigEndian.PutUint64(b[16:], streamer.ToNTP(wallClock))
+	binary.BigEndian.PutUint64(b[24:], m.dts[trackID]) // same as tfdt of next moof
+	return b
+}
@@ -0,0 +1,265 @@
+package mpegts
+
+import (
+	"errors"
+	"github.com/AlexxIT/go2rtc/pkg/aac"
+	"github.com/AlexxIT/go2rtc/pkg/h264"
+	"github.com/AlexxIT/go2rtc/pkg/h265"
+	"github.com/AlexxIT/go2rtc/pkg/streamer"
+	"github.com/pion/rtp"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProbeSize - max count of PES packets for search of codecs params
+const ProbeSize = 500
+
+// Client - producer from MPEG-TS stream, ex. HLS segments or HTTP source.
+// H264 and H265 are sent as AVC packets, one NAL unit per packet
+type Client struct {
+	streamer.Element
+
+	URI string
+
+	medias []*streamer.Media
+	tracks []*streamer.Track
+
+	rd       *Reader
+	closer   io.Closer
+	proto    string // HLS, HTTP-TS...
+	writers  map[uint16]streamer.WriterFunc
+	codecs   map[uint16]*streamer.Codec
+	probe    []*Packet // packets read while describe
+	timeline streamer.Timeline
+	closed   bool
+
+	receive int
+}
+
+// Open - producer from MPEG-TS stream, program can be selected
+// with link param: http://192.168.1.123/stream.ts#program=2
+func Open(uri, proto string, rd io.ReadCloser) (*Client, error) {
+	c := &Client{
+		URI:     uri,
+		rd:      NewReader(rd),
+		closer:  rd,
+		proto:   proto,
+		writers: map[uint16]streamer.WriterFunc{},
+		codecs:  map[uint16]*streamer.Codec{},
+	}
+
+	if i := strings.Index(uri, "#program="); i > 0 {
+		program, _ := strconv.Atoi(uri[i+9:])
+		c.rd.Program = uint16(program)
+		c.URI = uri[:i]
+	}
+	if err := c.describe(); err != nil {
+		_ = rd.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// describe reads packets until params of all streams from the PMT are found
+func (c *Client) describe() error {
+	for len(c.probe) < ProbeSize {
+		pkt, err := c.rd.ReadPacket()
+		if err != nil {
+			return err
+		}
+
+		c.probe = append(c.probe, pkt)
+
+		if c.codecs[pkt.PID] == nil {
+			if codec := newCodec(pkt); codec != nil {
+				c.codecs[pkt.PID] = codec
+			}
+		}
+
+		if len(c.codecs) == len(c.rd.pids) {
+			break
+		}
+	}
+
+	for _, pid := range c.rd.pids {
+		codec := c.codecs[pid]
+		if codec == nil {
+			continue
+		}
+
+		media := &streamer.Media{
+			Kind:      streamer.GetKind(codec.Name),
+			Direction: streamer.DirectionSendonly,
+			Codecs:    []*streamer.Codec{codec},
+		}
+		c.medias = append(c.medias, media)
+
+		track := &streamer.Track{Codec: codec, Direction: media.Direction}
+		c.tracks = append(c.tracks, track)
+
+		if codec.Name == streamer.CodecAAC {
+			// ADTS frames to AU headers (RFC 3640)
+			c.writers[pid] = aac.RTPPay()(track.WriteRTP)
+		} else {
+			c.writers[pid] = track.WriteRTP
+		}
+	}
+
+	if len(c.medias) == 0 {
+		return errors.New("mpegts: can't find supported codecs")
+	}
+
+	c.Fire(streamer.StateReady)
+
+	return nil
+}
+
+func (c *Client) Handle() error {
+	defer c.Fire(streamer.StateNull)
+
+	c.Fire(streamer.StatePlaying)
+
+	for _, pkt := range c.probe {
+		c.writePacket(pkt)
+	}
+	c.probe = nil
+
+	for {
+		pkt, err := c.rd.ReadPacket()
+		if err != nil {
+			if c.closed {
+				return nil
+			}
+			return err
+		}
+
+		c.writePacket(pkt)
+	}
+}
+
+func (c *Client) Close() error {
+	c.closed = true
+	return c.closer.Close()
+}
+
+func (c *Client) writePacket(pkt *Packet) {
+	c.receive += len(pkt.Payload)
+
+	write := c.writers[pkt.PID]
+	if write == nil {
+		return
+	}
+
+	// DTS because consumers expect increasing timestamps
+	ts := c.timeline.Time(time.Duration(pkt.DTS) * time.Second / ClockRate)
+	c.timeline.Wait(ts)
+
+	codec := c.codecs[pkt.PID]
+
+	switch codec.Name {
+	case streamer.CodecH264, streamer.CodecH265:
+		timestamp := streamer.RTPTime(ts, codec.ClockRate)
+		for _, nal := range h264.SplitAnnexB(pkt.Payload) {
+			_ = write(&rtp.Packet{
+				Header:  rtp.Header{Timestamp: timestamp},
+				Payload: h264.EncodeAVC(nal),
+			})
+		}
+
+	case streamer.CodecAAC:
+		timestamp := streamer.RTPTime(ts, codec.ClockRate)
+		for b := pkt.Payload; aac.IsADTS(b); {
+			// broken frame length, skip the rest of PES
+			size := aac.ADTSFrameLen(b)
+			if size < aac.ADTSHeaderLen(b) || size > len(b) {
+				break
+			}
+			_ = write(&rtp.Packet{
+				Header:  rtp.Header{Timestamp: timestamp},
+				Payload: b[aac.ADTSHeaderLen(b):size],
+			})
+			timestamp += aac.SamplesPerFrame
+			b = b[size:]
+		}
+
+	case streamer.CodecPCMA, streamer.CodecPCMU:
+		_ = write(&rtp.Packet{
+			Header:  rtp.Header{Timestamp: streamer.RTPTime(ts, codec.ClockRate)},
+			Payload: pkt.Payload,
+		})
+	}
+}
+
+// newCodec returns codec with params from the packet or nil
+func newCodec(pkt *Packet) *streamer.Codec {
+	switch pkt.StreamType {
+	case StreamTypeH264:
+		var sps, pps []byte
+		for _, nal := range h264.SplitAnnexB(pkt.Payload) {
+			switch nal[0] & 0x1F {
+			case h264.NALUTypeSPS:
+				sps = nal
+			case h264.NALUTypePPS:
+				pps = nal
+			}
+		}
+		if sps == nil || pps == nil {
+			return nil
+		}
+		return &streamer.Codec{
+			Name:        streamer.CodecH264,
+			ClockRate:   90000,
+			FmtpLine:    h264.FmtpLine(sps, pps),
+			PayloadType: h264.PayloadTypeAVC,
+		}
+
+	case StreamTypeH265:
+		var vps, sps, pps []byte
+		for _, nal := range h264.SplitAnnexB(pkt.Payload) {
+			switch nal[0] >> 1 & 0x3F {
+			case h265.NALUnitTypeVPS:
+				vps = nal
+			case h265.NALUnitTypeSPS:
+				sps = nal
+			case h265.NALUnitTypePPS:
+				pps = nal
+			}
+		}
+		if vps == nil || sps == nil || pps == nil {
+			return nil
+		}
+		return &streamer.Codec{
+			Name:        streamer.CodecH265,
+			ClockRate:   90000,
+			FmtpLine:    h265.FmtpLine(vps, sps, pps),
+			PayloadType: h264.PayloadTypeAVC,
+		}
+
+	case StreamTypeAAC:
+		if !aac.IsADTS(pkt.Payload) {
+			return nil
+		}
+		config := aac.ADTSConfig(pkt.Payload)
+		sampleRate, channels := aac.ConfigInfo(config)
+		if sampleRate == 0 {
+			return nil
+		}
+		return &streamer.Codec{
+			Name:        streamer.CodecAAC,
+			ClockRate:   sampleRate,
+			Channels:    channels,
+			FmtpLine:    aac.FmtpLine(config),
+			PayloadType: 96,
+		}
+
+	case StreamTypePCMA:
+		return &streamer.Codec{Name: streamer.CodecPCMA, ClockRate: 8000, PayloadType: 8}
+
+	case StreamTypePCMU:
+		return &streamer.Codec{Name: streamer.CodecPCMU, ClockRate: 8000}
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package mpegts
+
+import (
+	"encoding/base64"
+	"github.com/AlexxIT/go2rtc/pkg/h264"
+	"github.com/AlexxIT/go2rtc/pkg/streamer"
+	"github.com/deepch/vdk/av"
+	"github.com/deepch/vdk/codec/aacparser"
+	"github.com/deepch/vdk/codec/h264parser"
+	"github.com/deepch/vdk/format/ts"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestClient(t *testing.T) {
+	sps, _ := base64.StdEncoding.DecodeString("Z0JAHqaAoD2QAA==")
+	pps, _ := base64.StdEncoding.DecodeString("aM48gAA=")
+
+	videoData, err := h264parser.NewCodecDataFromSPSAndPPS(sps, pps)
+	assert.Nil(t, err)
+	audioData, err := aacparser.NewCodecDataFromMPEG4AudioConfigBytes([]byte{0x12, 0x10})
+	assert.Nil(t, err)
+
+	r, w := io.Pipe()
+
+	go func() {
+		muxer := ts.NewMuxer(w)
+		_ = muxer.WriteHeader([]av.CodecData{videoData, audioData})
+		for i := 0; i < 4; i++ {
+			payload := []byte{0x41, 3, 4}
+			if i == 0 {
+				payload = []byte{0x65, 1, 2}
+			}
+			_ = muxer.WritePacket(av.Packet{
+				Idx: 0, IsKeyFrame: i == 0, Time: time.Duration(i) * 40 * time.Millisecond,
+				Data: h264.EncodeAVC(payload),
+			})
+			_ = muxer.WritePacket(av.Packet{
+				Idx: 1, Time: time.Duration(i) * 40 * time.Millisecond, Data: []byte{0xBB, 0xCC},
+			})
+		}
+		_ = w.Close()
+	}()
+
+	prod, err := Open("http://localhost/stream.ts", "HTTP-TS", r)
+	assert.Nil(t, err)
+
+	medias := prod.GetMedias()
+	assert.Len(t, medias, 2)
+
+	// vdk muxer writes streams to the PMT in random order
+	if medias[0].Kind == streamer.KindAudio {
+		medias[0], medias[1] = medias[1], medias[0]
+	}
+
+	assert.Equal(t, streamer.CodecH264, medias[0].Codecs[0].Name)
+	assert.Equal(t, streamer.CodecAAC, medias[1].Codecs[0].Name)
+	assert.Equal(t, uint32(44100), medias[1].Codecs[0].ClockRate)
+	assert.Equal(t, uint16(2), medias[1].Codecs[0].Channels)
+
+	var video, audio []*rtp.Packet
+	prod.GetTrack(medias[0], medias[0].Codecs[0]).Bind(func(packet *rtp.Packet) error {
+		if h264.NALUType(packet.Payload) == h264.NALUTypeIFrame || h264.NALUType(packet.Payload) == h264.NALUTypePFrame {
+			video = append(video, packet)
+		}
+		return nil
+	})
+	prod.GetTrack(medias[1], medias[1].Codecs[0]).Bind(func(packet *rtp.Packet) error {
+		audio = append(audio, packet)
+		return nil
+	})
+
+	assert.Equal(t, io.EOF, prod.Handle())
+
+	// last PES packets are flushed only with the next packets
+	assert.Len(t, video, 3)
+	assert.Equal(t, []byte{0x65, 1, 2}, video[0].Payload[4:])
+	assert.Equal(t, video[0].Timestamp+3600, video[1].Timestamp)
+	assert.Len(t, audio, 4)
+	assert.Equal(t, []byte{0xBB, 0xCC}, audio[0].Payload[4:])
+}
@@ -0,0 +1,349 @@
+package mpegts
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/AlexxIT/go2rtc/pkg/aac"
+	"github.com/AlexxIT/go2rtc/pkg/h264"
+	"github.com/AlexxIT/go2rtc/pkg/h265"
+	"github.com/AlexxIT/go2rtc/pkg/streamer"
+	"github.com/pion/rtp"
+	"sync"
+)
+
+// HeaderInterval - PAT and PMT are repeated before video keyframes,
+// and every N packets for streams without video
+const HeaderInterval = 50
+
+// Consumer - MPEG-TS stream from H264/H265 and AAC/PCMA/PCMU tracks,
+// sent with Fire as []byte chunks of TS packets
+type Consumer struct {
+	streamer.Element
+
+	UserAgent  string
+	RemoteAddr string
+
+	muxer  *Muxer
+	codecs []*streamer.Codec
+	start  bool
+	count  int // packets after the last header
+	mx     sync.Mutex
+
+	send int
+}
+
+func (c *Consumer) GetMedias() []*streamer.Media {
+	return []*streamer.Media{
+		{
+			Kind:      streamer.KindVideo,
+			Direction: streamer.DirectionRecvonly,
+			Codecs: []*streamer.Codec{
+				{Name: streamer.CodecH264, ClockRate: 90000},
+				{Name: streamer.CodecH265, ClockRate: 90000},
+			},
+		},
+		{
+			Kind:      streamer.KindAudio,
+			Direction: streamer.DirectionRecvonly,
+			Codecs: []*streamer.Codec{
+				{Name: streamer.CodecAAC},
+				{Name: streamer.CodecPCMA},
+				{Name: streamer.CodecPCMU},
+			},
+		},
+	}
+}
+
+func (c *Consumer) AddTrack(media *streamer.Media, track *streamer.Track) *streamer.Track {
+	if c.muxer == nil {
+		c.muxer = NewMuxer()
+	}
+
+	codec := track.Codec
+	clock := &trackClock{clockRate: codec.ClockRate}
+
+	switch codec.Name {
+	case streamer.CodecH264:
+		pid := c.muxer.AddTrack(StreamTypeH264)
+		c.codecs = append(c.codecs, codec)
+
+		sps, pps := h264.GetParameterSet(codec.FmtpLine)
+
+		push := func(packet *rtp.Packet) error {
+			if packet.Version != h264.RTPPacketVersionAVC {
+				return nil
+			}
+
+			var keyframe bool
+
+			switch h264.NALUType(packet.Payload) {
+			case h264.NALUTypeSPS:
+				sps = packet.Payload[4:]
+				return nil
+			case h264.NALUTypePPS:
+				pps = packet.Payload[4:]
+				return nil
+			case h264.NALUTypeIFrame:
+				c.mx.Lock()
+				c.start = true
+				c.mx.Unlock()
+				keyframe = true
+			case h264.NALUTypePFrame:
+				if !c.started() {
+					return nil
+				}
+			default:
+				return nil
+			}
+
+			// slices of one frame have same timestamp
+			var payload []byte
+			if clock.newFrame(packet.Timestamp) {
+				// access unit delimiter, it's required for H264 in MPEG-TS
+				payload = []byte{0, 0, 0, 1, 0x09, 0xF0}
+				if keyframe {
+					payload = appendParams(payload, sps, pps)
+				}
+			} else {
+				keyframe = false
+			}
+			payload = append(payload, annexB(packet.Payload)...)
+
+			c.fire(pid, clock.time(packet.Timestamp), payload, keyframe)
+			return nil
+		}
+
+		if !h264.IsAVC(codec) {
+			wrapper := h264.RTPDepay(track)
+			push = wrapper(push)
+		}
+
+		return track.Bind(push)
+
+	case streamer.CodecH265:
+		pid := c.muxer.AddTrack(StreamTypeH265)
+		c.codecs = append(c.codecs, codec)
+
+		vps, sps, pps := h265.GetParameterSet(codec.FmtpLine)
+
+		push := func(packet *rtp.Packet) error {
+			if packet.Version != h264.RTPPacketVersionAVC {
+				return nil
+			}
+
+			nut := h265.NALUnitType(packet.Payload)
+
+			switch {
+			case nut == h265.NALUnitTypeVPS:
+				vps = packet.Payload[4:]
+				return nil
+			case nut == h265.NALUnitTypeSPS:
+				sps = packet.Payload[4:]
+				return nil
+			case nut == h265.NALUnitTypePPS:
+				pps = packet.Payload[4:]
+				return nil
+			case nut >= 32:
+				return nil // non-VCL units
+			}
+
+			// IRAP pictures: BLA, IDR, CRA
+			keyframe := nut >= 16 && nut <= 21
+
+			if keyframe {
+				c.mx.Lock()
+				c.start = true
+				c.mx.Unlock()
+			} else if !c.started() {
+				return nil
+			}
+
+			var payload []byte
+			if clock.newFrame(packet.Timestamp) {
+				// access unit delimiter
+				payload = []byte{0, 0, 0, 1, 0x46, 0x01, 0x50}
+				if keyframe {
+					payload = appendParams(payload, vps, sps, pps)
+				}
+			} else {
+				keyframe = false
+			}
+			payload = append(payload, annexB(packet.Payload)...)
+
+			c.fire(pid, clock.time(packet.Timestamp), payload, keyframe)
+			return nil
+		}
+
+		if !h264.IsAVC(codec) {
+			wrapper := h265.RTPDepay(track)
+			push = wrapper(push)
+		}
+
+		return track.Bind(push)
+
+	case streamer.CodecAAC:
+		config := aac.GetConfig(codec.FmtpLine)
+		if len(config) < 2 {
+			// track without config can't be muxed, skip its packets
+			return track.Bind(func(packet *rtp.Packet) error { return nil })
+		}
+
+		pid := c.muxer.AddTrack(StreamTypeAAC)
+		c.codecs = append(c.codecs, codec)
+
+		push := func(packet *rtp.Packet) error {
+			keyframe, ok := c.audioStart()
+			if !ok {
+				return nil
+			}
+
+			payload := aac.ADTSHeader(config, len(packet.Payload))
+			payload = append(payload, packet.Payload...)
+
+			c.fire(pid, clock.time(packet.Timestamp), payload, keyframe)
+			return nil
+		}
+
+		wrapper := aac.RTPDepay(track)
+		return track.Bind(wrapper(push))
+
+	case streamer.CodecPCMA, streamer.CodecPCMU:
+		streamType := byte(StreamTypePCMA)
+		if codec.Name == streamer.CodecPCMU {
+			streamType = StreamTypePCMU
+		}
+
+		pid := c.muxer.AddTrack(streamType)
+		c.codecs = append(c.codecs, codec)
+
+		push := func(packet *rtp.Packet) error {
+			keyframe, ok := c.audioStart()
+			if !ok {
+				return nil
+			}
+
+			c.fire(pid, clock.time(packet.Timestamp), packet.Payload, keyframe)
+			return nil
+		}
+
+		return track.Bind(push)
+	}
+
+	fmt.Printf("[mpegts] unsupported codec: %+v\n", track.Codec)
+
+	return nil
+}
+
+func (c *Consumer) MarshalJSON() ([]byte, error) {
+	v := map[string]interface{}{
+		streamer.JSONType:       "TS server consumer",
+		streamer.JSONRemoteAddr: c.RemoteAddr,
+		streamer.JSONUserAgent:  c.UserAgent,
+		streamer.JSONSend:       c.send,
+	}
+	return json.Marshal(v)
+}
+
+func (c *Consumer) started() bool {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return c.start
+}
+
+// audioStart - audio waits for video keyframe, if there is video track,
+// header repeated by interval for streams without video
+func (c *Consumer) audioStart() (header bool, ok bool) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if c.hasVideo() {
+		return false, c.start
+	}
+
+	if !c.start {
+		c.start = true
+		return true, true
+	}
+
+	return c.count >= HeaderInterval, true
+}
+
+// fire sends PES packet, tracks can be pushed from different goroutines,
+// so packets are muxed and sent under lock to keep their order
+func (c *Consumer) fire(pid uint16, time uint64, payload []byte, keyframe bool) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	var buf []byte
+	if keyframe {
+		buf = c.muxer.GetHeader()
+		c.count = 0
+	}
+	buf = append(buf, c.muxer.GetPayload(pid, time, payload, keyframe)...)
+
+	c.count++
+	c.send += len(buf)
+
+	c.Fire(buf)
+}
+
+func (c *Consumer) hasVideo() bool {
+	for _, codec := range c.codecs {
+		if codec.Name == streamer.CodecH264 || codec.Name == streamer.CodecH265 {
+			return true
+		}
+	}
+	return false
+}
+
+// trackClock - 90 kHz time from the first packet of the track,
+// 64 bits, so it doesn't wrap like RTP timestamps
+type trackClock struct {
+	clockRate uint32
+	last      uint32
+	total     uint64
+	ok        bool
+}
+
+func (t *trackClock) time(ts uint32) uint64 {
+	if t.ok {
+		t.total += uint64(ts - t.last)
+	}
+	t.last = ts
+	t.ok = true
+
+	if t.clockRate == 0 || t.clockRate == ClockRate {
+		return t.total
+	}
+	return t.total * ClockRate / uint64(t.clockRate)
+}
+
+// newFrame checks if the timestamp differs from the previous packet
+func (t *trackClock) newFrame(ts uint32) bool {
+	return !t.ok || ts != t.last
+}
+
+// appendParams - parameter sets before keyframe, so decoder can start from any keyframe
+func appendParams(b []byte, params ...[]byte) []byte {
+	for _, nal := range params {
+		if nal != nil {
+			b = append(b, 0, 0, 0, 1)
+			b = append(b, nal...)
+		}
+	}
+	return b
+}
+
+// annexB converts AVC units (with 4 bytes size) to Annex B (with start code)
+func annexB(avc []byte) []byte {
+	b := make([]byte, len(avc))
+	copy(b, avc)
+
+	for i := 0; i+4 <= len(b); {
+		size := int(b[i])<<24 | int(b[i+1])<<16 | int(b[i+2])<<8 | int(b[i+3])
+		b[i], b[i+1], b[i+2], b[i+3] = 0, 0, 0, 1
+		i += 4 + size
+	}
+
+	return b
+}
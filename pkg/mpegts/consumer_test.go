@@ -0,0 +1,81 @@
+package mpegts
+
+import (
+	"bytes"
+	"github.com/AlexxIT/go2rtc/pkg/h264"
+	"github.com/AlexxIT/go2rtc/pkg/streamer"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func TestConsumer(t *testing.T) {
+	var buf []byte
+
+	cons := &Consumer{}
+	cons.Listen(func(msg interface{}) {
+		if data, ok := msg.([]byte); ok {
+			assert.Equal(t, 0, len(data)%PacketSize)
+			buf = append(buf, data...)
+		}
+	})
+
+	video := &streamer.Track{Codec: &streamer.Codec{
+		Name: streamer.CodecH264, ClockRate: 90000, PayloadType: h264.PayloadTypeAVC,
+		FmtpLine: "packetization-mode=1;sprop-parameter-sets=Z0JAHqaAoD2QAA==,aM48gAA=",
+	}}
+	audio := &streamer.Track{Codec: &streamer.Codec{
+		Name: streamer.CodecAAC, ClockRate: 44100, Channels: 2,
+		FmtpLine: "streamtype=5;mode=AAC-hbr;sizelength=13;indexlength=3;indexdeltalength=3;config=1210",
+	}}
+
+	cons.AddTrack(nil, video)
+	cons.AddTrack(nil, audio)
+
+	// audio before keyframe is skipped
+	_ = audio.WriteRTP(&rtp.Packet{Header: rtp.Header{Timestamp: 100}, Payload: []byte{0, 0x10, 0, 0x10, 0xBB, 0xCC}})
+
+	for i := uint32(0); i < 4; i++ {
+		payload := bytes.Repeat([]byte{0x41}, 300)
+		if i == 0 {
+			payload = []byte{0x65, 1, 2}
+		}
+		_ = video.WriteRTP(&rtp.Packet{Header: rtp.Header{Timestamp: 1000 + i*3600}, Payload: h264.EncodeAVC(payload)})
+		_ = audio.WriteRTP(&rtp.Packet{Header: rtp.Header{Timestamp: 500 + i*1024}, Payload: []byte{0, 0x10, 0, 0x10, 0xBB, 0xCC}})
+	}
+
+	prod, err := Open("", "TS", ioutil.NopCloser(bytes.NewReader(buf)))
+	assert.Nil(t, err)
+
+	medias := prod.GetMedias()
+	assert.Len(t, medias, 2)
+	assert.Equal(t, streamer.CodecH264, medias[0].Codecs[0].Name)
+	assert.Equal(t, streamer.CodecAAC, medias[1].Codecs[0].Name)
+	assert.Equal(t, "1210", medias[1].Codecs[0].FmtpLine[len(medias[1].Codecs[0].FmtpLine)-4:])
+
+	var frames, units []*rtp.Packet
+	prod.GetTrack(medias[0], medias[0].Codecs[0]).Bind(func(packet *rtp.Packet) error {
+		switch h264.NALUType(packet.Payload) {
+		case h264.NALUTypeIFrame, h264.NALUTypePFrame:
+			frames = append(frames, packet)
+		}
+		return nil
+	})
+	prod.GetTrack(medias[1], medias[1].Codecs[0]).Bind(func(packet *rtp.Packet) error {
+		units = append(units, packet)
+		return nil
+	})
+
+	assert.Equal(t, io.EOF, prod.Handle())
+
+	// last video PES is flushed only with the next packet
+	assert.Len(t, frames, 3)
+	assert.Equal(t, []byte{0x65, 1, 2}, frames[0].Payload[4:])
+	assert.Equal(t, frames[0].Timestamp+3600, frames[1].Timestamp)
+	assert.Len(t, frames[1].Payload, 4+300)
+
+	assert.Len(t, units, 4)
+	assert.Equal(t, []byte{0xBB, 0xCC}, units[0].Payload[4:])
+}
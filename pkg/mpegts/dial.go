@@ -0,0 +1,112 @@
+package mpegts
+
+import (
+	"errors"
+	"github.com/pion/rtp"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Timeout - source is dead if there is no data, ex. multicast group without sender
+const Timeout = 5 * time.Second
+
+// Dial - producer from MPEG-TS over UDP (unicast or multicast) or TCP:
+//   - udp://@239.0.0.1:1234 - multicast group
+//   - udp://@:1234 - unicast on the local port
+//   - tcp://192.168.1.123:1234 - connect to the server
+//
+// Program can be selected with link param: udp://@239.0.0.1:1234#program=2
+func Dial(uri string) (*Client, error) {
+	link := uri
+	if i := strings.IndexByte(link, '#'); i > 0 {
+		link = link[:i]
+	}
+
+	u, err := url.Parse(link)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "udp":
+		addr, err := net.ResolveUDPAddr("udp", u.Host)
+		if err != nil {
+			return nil, err
+		}
+
+		var conn *net.UDPConn
+		if addr.IP.IsMulticast() {
+			conn, err = net.ListenMulticastUDP("udp", nil, addr)
+		} else {
+			conn, err = net.ListenUDP("udp", addr)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// big buffer for high bitrate streams, because UDP packets can be lost
+		_ = conn.SetReadBuffer(1024 * 1024)
+
+		rd := &netReader{conn: conn, buf: make([]byte, 0xFFFF)}
+		return Open(uri, "UDP-TS", rd)
+
+	case "tcp":
+		conn, err := net.DialTimeout("tcp", u.Host, Timeout)
+		if err != nil {
+			return nil, err
+		}
+
+		return Open(uri, "TCP-TS", &netReader{conn: conn})
+	}
+
+	return nil, errors.New("mpegts: unsupported scheme: " + u.Scheme)
+}
+
+// netReader - reads with timeout, UDP datagrams are read whole and buffered,
+// because read to the small buffer discards the rest of the datagram
+type netReader struct {
+	conn net.Conn
+	buf  []byte // nil for TCP
+	data []byte
+}
+
+func (r *netReader) Read(p []byte) (n int, err error) {
+	if r.buf == nil {
+		_ = r.conn.SetReadDeadline(time.Now().Add(Timeout))
+		return r.conn.Read(p)
+	}
+
+	for len(r.data) == 0 {
+		_ = r.conn.SetReadDeadline(time.Now().Add(Timeout))
+		if n, err = r.conn.Read(r.buf); err != nil {
+			return 0, err
+		}
+
+		// skip empty datagrams
+		if n == 0 {
+			continue
+		}
+
+		r.data = r.buf[:n]
+
+		// MPEG-TS in RTP packets (RFC 2250), common for IPTV multicast
+		if n >= 12 && r.data[0] != SyncByte && r.data[0]>>6 == 2 {
+			packet := &rtp.Packet{}
+			if err = packet.Unmarshal(r.data); err != nil {
+				r.data = nil
+				continue
+			}
+			r.data = packet.Payload
+		}
+	}
+
+	n = copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func (r *netReader) Close() error {
+	return r.conn.Close()
+}
@@ -0,0 +1,83 @@
+package mpegts
+
+import (
+	"bytes"
+	"github.com/AlexxIT/go2rtc/pkg/streamer"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestDialUDP(t *testing.T) {
+	// free local port
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	assert.Nil(t, err)
+	addr := conn.LocalAddr().String()
+	_ = conn.Close()
+
+	muxer := NewMuxer()
+	pid := muxer.AddTrack(StreamTypePCMA)
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		sender, err := net.Dial("udp", addr)
+		if err != nil {
+			return
+		}
+		defer sender.Close()
+
+		// empty and short datagrams are skipped
+		_, _ = sender.Write(nil)
+		_, _ = sender.Write([]byte{0x80})
+
+		for i := uint64(0); ; i++ {
+			b := muxer.GetHeader()
+			b = append(b, muxer.GetPayload(pid, i*1440, make([]byte, 160), false)...)
+
+			// odd datagrams in RTP packets
+			if i%2 == 1 {
+				packet := &rtp.Packet{
+					Header:  rtp.Header{Version: 2, PayloadType: 33, SequenceNumber: uint16(i)},
+					Payload: b,
+				}
+				b, _ = packet.Marshal()
+			}
+
+			_, _ = sender.Write(b)
+
+			select {
+			case <-done:
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}()
+
+	client, err := Dial("udp://@" + addr + "#program=1")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	assert.Equal(t, "udp://@"+addr, client.URI)
+
+	medias := client.GetMedias()
+	assert.Len(t, medias, 1)
+	assert.Equal(t, streamer.CodecPCMA, medias[0].Codecs[0].Name)
+	assert.Equal(t, uint32(8000), medias[0].Codecs[0].ClockRate)
+}
+
+func TestReaderProgram(t *testing.T) {
+	muxer := NewMuxer()
+	muxer.AddTrack(StreamTypePCMA)
+
+	rd := NewReader(bytes.NewReader(muxer.GetHeader()))
+	rd.Program = 2
+
+	_, err := rd.ReadPacket()
+	assert.NotNil(t, err)
+	assert.NotEqual(t, io.EOF, err)
+}
@@ -0,0 +1,288 @@
+package mpegts
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+const (
+	PacketSize = 188
+	SyncByte   = 0x47
+)
+
+// Stream types from PMT
+const (
+	StreamTypeAAC  = 0x0F
+	StreamTypeH264 = 0x1B
+	StreamTypeH265 = 0x24
+
+	// private stream types, not in the standard, ex. Tapo cameras use 0x90 for PCMA
+	StreamTypePCMA = 0x90
+	StreamTypePCMU = 0x91
+)
+
+// ClockRate of PTS and DTS
+const ClockRate = 90000
+
+// Packet - PES packet of elementary stream
+type Packet struct {
+	StreamType byte
+	PID        uint16
+	PTS        uint32 // 90 kHz, lower bits of 33-bit value
+	DTS        uint32 // same as PTS if absent
+	Payload    []byte
+}
+
+// Reader - MPEG-TS demuxer, reads PES packets of supported streams
+// of the selected program from the PAT
+type Reader struct {
+	// Program number from the PAT, 0 - first program
+	Program uint16
+
+	rd  io.Reader
+	buf []byte
+
+	pmtPID  uint16
+	streams map[uint16]*stream
+	pids    []uint16 // streams in the PMT order
+}
+
+type stream struct {
+	streamType byte
+	pts, dts   uint32
+	data       []byte
+	size       int // payload size from the PES header, 0 - unknown
+}
+
+func NewReader(rd io.Reader) *Reader {
+	return &Reader{
+		rd:      rd,
+		buf:     make([]byte, PacketSize),
+		streams: map[uint16]*stream{},
+	}
+}
+
+// Streams returns PID => stream type of supported streams from the PMT
+func (r *Reader) Streams() map[uint16]byte {
+	streams := map[uint16]byte{}
+	for _, pid := range r.pids {
+		streams[pid] = r.streams[pid].streamType
+	}
+	return streams
+}
+
+func (r *Reader) ReadPacket() (*Packet, error) {
+	for {
+		if err := r.readTS(); err != nil {
+			return nil, err
+		}
+
+		b := r.buf
+
+		pusi := b[1]&0x40 != 0
+		pid := uint16(b[1]&0x1F)<<8 | uint16(b[2])
+
+		// adaptation field control
+		i := 4
+		if b[3]&0x20 != 0 {
+			i += 1 + int(b[4])
+		}
+		if b[3]&0x10 == 0 || i >= PacketSize {
+			continue // no payload
+		}
+
+		payload := b[i:]
+
+		switch {
+		case pid == 0:
+			if pusi {
+				if err := r.parsePAT(payload); err != nil {
+					return nil, err
+				}
+			}
+		case pid == r.pmtPID:
+			if pusi {
+				r.parsePMT(payload)
+			}
+		default:
+			s := r.streams[pid]
+			if s == nil {
+				continue
+			}
+
+			var pkt *Packet
+
+			if pusi {
+				// new PES packet, flush the previous one
+				if len(s.data) > 0 {
+					pkt = s.packet(pid)
+				}
+				s.parseHeader(payload)
+			} else if s.data != nil {
+				s.data = append(s.data, payload...)
+			}
+
+			// flush when size is known
+			if pkt == nil && s.size > 0 && len(s.data) >= s.size {
+				s.data = s.data[:s.size]
+				pkt = s.packet(pid)
+			}
+
+			if pkt != nil {
+				return pkt, nil
+			}
+		}
+	}
+}
+
+// readTS reads one TS packet to the buffer, with resync on wrong sync byte
+func (r *Reader) readTS() error {
+	if _, err := io.ReadFull(r.rd, r.buf); err != nil {
+		return err
+	}
+
+	for r.buf[0] != SyncByte {
+		i := bytes.IndexByte(r.buf[1:], SyncByte)
+		if i < 0 {
+			if _, err := io.ReadFull(r.rd, r.buf); err != nil {
+				return err
+			}
+			continue
+		}
+
+		n := copy(r.buf, r.buf[1+i:])
+		if _, err := io.ReadFull(r.rd, r.buf[n:]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *Reader) parsePAT(b []byte) error {
+	b, err := section(b)
+	if err != nil {
+		return nil // broken PAT, wait for the next one
+	}
+
+	// skip table header, loop of programs
+	for i := 8; i+4 <= len(b); i += 4 {
+		program := uint16(b[i])<<8 | uint16(b[i+1])
+		if program == 0 || (r.Program != 0 && program != r.Program) {
+			continue // network PID or another program
+		}
+
+		r.pmtPID = uint16(b[i+2]&0x1F)<<8 | uint16(b[i+3])
+		return nil
+	}
+
+	return errors.New("mpegts: can't find program in PAT")
+}
+
+func (r *Reader) parsePMT(b []byte) {
+	b, err := section(b)
+	if err != nil || len(b) < 12 {
+		return
+	}
+
+	i := 12 + (int(b[10]&0x0F)<<8 | int(b[11]))
+
+	for i+5 <= len(b) {
+		streamType := b[i]
+		pid := uint16(b[i+1]&0x1F)<<8 | uint16(b[i+2])
+		i += 5 + (int(b[i+3]&0x0F)<<8 | int(b[i+4]))
+
+		switch streamType {
+		case StreamTypeAAC, StreamTypeH264, StreamTypeH265, StreamTypePCMA, StreamTypePCMU:
+		default:
+			continue
+		}
+
+		if s := r.streams[pid]; s != nil {
+			s.streamType = streamType
+			continue
+		}
+
+		r.streams[pid] = &stream{streamType: streamType}
+		r.pids = append(r.pids, pid)
+	}
+}
+
+// section returns PSI section from the payload without CRC
+func section(b []byte) ([]byte, error) {
+	if len(b) < 1 {
+		return nil, errors.New("mpegts: wrong section")
+	}
+
+	// pointer field
+	i := 1 + int(b[0])
+	if i+3 > len(b) {
+		return nil, errors.New("mpegts: wrong section")
+	}
+	b = b[i:]
+
+	size := 3 + (int(b[1]&0x0F)<<8 | int(b[2]))
+	if size < 7 || size > len(b) {
+		return nil, errors.New("mpegts: section in several packets")
+	}
+
+	return b[:size-4], nil
+}
+
+func (s *stream) parseHeader(b []byte) {
+	s.data = nil
+	s.size = 0
+
+	// start code, stream id, packet length, flags, header length
+	if len(b) < 9 || b[0] != 0 || b[1] != 0 || b[2] != 1 {
+		return
+	}
+
+	i := 9 + int(b[8])
+	if i > len(b) {
+		return
+	}
+
+	// header length must contain PTS (5 bytes) or PTS and DTS (10 bytes),
+	// otherwise PES is dropped
+	switch b[7] >> 6 {
+	case 2:
+		if b[8] < 5 {
+			return
+		}
+		s.pts = timestamp(b[9:])
+		s.dts = s.pts
+	case 3:
+		if b[8] < 10 {
+			return
+		}
+		s.pts = timestamp(b[9:])
+		s.dts = timestamp(b[14:])
+	}
+
+	if size := 6 + (int(b[4])<<8 | int(b[5])) - i; size > 0 && size < 0xFFFF {
+		s.size = size
+	}
+
+	s.data = append(make([]byte, 0, s.size), b[i:]...)
+}
+
+func (s *stream) packet(pid uint16) *Packet {
+	pkt := &Packet{
+		StreamType: s.streamType,
+		PID:        pid,
+		PTS:        s.pts,
+		DTS:        s.dts,
+		Payload:    s.data,
+	}
+	s.data = nil
+	s.size = 0
+	return pkt
+}
+
+// timestamp - 33-bit PTS or DTS with marker bits
+func timestamp(b []byte) uint32 {
+	return uint32(b[0]&0x0E)<<29 | uint32(b[1])<<22 | uint32(b[2]&0xFE)<<14 |
+		uint32(b[3])<<7 | uint32(b[4])>>1
+}
@@ -0,0 +1,39 @@
+package mpegts
+
+import (
+	"github.com/AlexxIT/go2rtc/pkg/streamer"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestReaderShortPES(t *testing.T) {
+	s := &stream{}
+
+	// PTS and DTS flags with header length less than 10 bytes
+	s.parseHeader([]byte{0, 0, 1, 0xE0, 0, 0, 0x80, 0xC0, 0, 0, 0, 0})
+	assert.Nil(t, s.data)
+
+	s.parseHeader([]byte{0, 0, 1, 0xE0, 0, 0, 0x80, 0x80, 5, 0x21, 0, 1, 0, 1, 0xAA})
+	assert.Equal(t, []byte{0xAA}, s.data)
+}
+
+func TestClientBrokenADTS(t *testing.T) {
+	c := &Client{
+		writers: map[uint16]streamer.WriterFunc{},
+		codecs:  map[uint16]*streamer.Codec{0x101: {Name: streamer.CodecAAC, ClockRate: 44100}},
+	}
+
+	var payloads [][]byte
+	c.writers[0x101] = func(packet *rtp.Packet) error {
+		payloads = append(payloads, packet.Payload)
+		return nil
+	}
+
+	// valid frame with 2 bytes payload and frame with zero length
+	frame := []byte{0xFF, 0xF1, 0x50, 0x80, 0x01, 0x3F, 0xFC, 0xBB, 0xCC}
+	empty := []byte{0xFF, 0xF1, 0x50, 0x80, 0x00, 0x1F, 0xFC, 0xDD}
+
+	c.writePacket(&Packet{PID: 0x101, Payload: append(frame, empty...)})
+	assert.Equal(t, [][]byte{{0xBB, 0xCC}}, payloads)
+}
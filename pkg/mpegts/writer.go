@@ -0,0 +1,218 @@
+package mpegts
+
+const (
+	pmtPID   = 0x1000
+	firstPID = 0x100
+	// PCRDelay - PTS is ahead of PCR, so decoder has time to buffer the frame
+	PCRDelay = ClockRate / 2
+)
+
+// Muxer packs PES packets of elementary streams to TS packets,
+// with PAT and PMT for one program
+type Muxer struct {
+	pids   []uint16
+	types  []byte
+	pcrPID uint16
+	cc     map[uint16]byte // continuity counters
+}
+
+func NewMuxer() *Muxer {
+	return &Muxer{cc: map[uint16]byte{}}
+}
+
+// AddTrack returns PID for the stream, first video stream carries PCR
+func (m *Muxer) AddTrack(streamType byte) uint16 {
+	pid := firstPID + uint16(len(m.pids))
+	m.pids = append(m.pids, pid)
+	m.types = append(m.types, streamType)
+
+	switch streamType {
+	case StreamTypeH264, StreamTypeH265:
+		if m.pcrPID == 0 || !isVideo(m.typeOf(m.pcrPID)) {
+			m.pcrPID = pid
+		}
+	default:
+		if m.pcrPID == 0 {
+			m.pcrPID = pid
+		}
+	}
+
+	return pid
+}
+
+// GetHeader returns PAT and PMT, should be before keyframes
+func (m *Muxer) GetHeader() []byte {
+	// program number 1 with PMT PID
+	pat := []byte{0x00, 0x01, 0xE0 | pmtPID>>8, pmtPID & 0xFF}
+
+	// PCR PID, empty program info, loop of streams
+	pmt := []byte{0xE0 | byte(m.pcrPID>>8), byte(m.pcrPID), 0xF0, 0x00}
+	for i, pid := range m.pids {
+		pmt = append(pmt, m.types[i], 0xE0|byte(pid>>8), byte(pid), 0xF0, 0x00)
+	}
+
+	b := m.writeSection(0, 0x00, 0x0001, pat)
+	return append(b, m.writeSection(pmtPID, 0x02, 0x0001, pmt)...)
+}
+
+// GetPayload returns PES packet with the access unit in TS packets,
+// time - 90 kHz time from the start of the stream
+func (m *Muxer) GetPayload(pid uint16, time uint64, payload []byte, keyframe bool) []byte {
+	streamType := m.typeOf(pid)
+
+	pts := time + PCRDelay
+
+	// PES header with PTS only
+	var streamID byte
+	switch {
+	case isVideo(streamType):
+		streamID = 0xE0
+	case streamType == StreamTypeAAC:
+		streamID = 0xC0
+	default:
+		streamID = 0xBD // private stream 1
+	}
+
+	pes := make([]byte, 14, 14+len(payload))
+	pes[2] = 1
+	pes[3] = streamID
+
+	// video length can be more than 16 bits, zero is allowed for video
+	if size := 8 + len(payload); size <= 0xFFFF && !isVideo(streamType) {
+		pes[4] = byte(size >> 8)
+		pes[5] = byte(size)
+	}
+
+	pes[6] = 0x80 // marker bits
+	pes[7] = 0x80 // PTS flag
+	pes[8] = 5    // header data length
+	pes[9] = 0x21 | byte(pts>>29)&0x0E
+	pes[10] = byte(pts >> 22)
+	pes[11] = byte(pts>>14) | 1
+	pes[12] = byte(pts >> 7)
+	pes[13] = byte(pts<<1) | 1
+
+	pes = append(pes, payload...)
+
+	var b []byte
+
+	for first := true; len(pes) > 0; first = false {
+		var adaptation []byte
+		if first && pid == m.pcrPID {
+			// random access flag, PCR flag, 33 bits base, 6 reserved bits, 9 bits extension
+			flags := byte(0x10)
+			if keyframe {
+				flags |= 0x40
+			}
+			adaptation = []byte{
+				flags, byte(time >> 25), byte(time >> 17), byte(time >> 9), byte(time >> 1),
+				byte(time<<7) | 0x7E, 0,
+			}
+		} else if first && keyframe {
+			adaptation = []byte{0x40}
+		}
+
+		size := 184
+		if adaptation != nil {
+			size -= 1 + len(adaptation)
+		}
+
+		// stuffing in the adaptation field for the last packet
+		if n := size - len(pes); n > 0 {
+			if adaptation == nil {
+				if n == 1 {
+					adaptation = []byte{} // only length byte
+				} else {
+					adaptation = []byte{0x00}
+					n -= 2
+				}
+			}
+			for ; n > 0; n-- {
+				adaptation = append(adaptation, 0xFF)
+			}
+			size = 184 - 1 - len(adaptation)
+		}
+
+		b = m.writeHeader(b, pid, first, adaptation)
+		b = append(b, pes[:size]...)
+		pes = pes[size:]
+	}
+
+	return b
+}
+
+func (m *Muxer) writeHeader(b []byte, pid uint16, pusi bool, adaptation []byte) []byte {
+	cc := m.cc[pid]
+	m.cc[pid] = (cc + 1) & 0x0F
+
+	header := []byte{SyncByte, byte(pid>>8) & 0x1F, byte(pid), 0x10 | cc}
+	if pusi {
+		header[1] |= 0x40
+	}
+	if adaptation != nil {
+		header[3] |= 0x20
+		header = append(header, byte(len(adaptation)))
+		header = append(header, adaptation...)
+	}
+
+	return append(b, header...)
+}
+
+// writeSection returns PSI section in one TS packet
+func (m *Muxer) writeSection(pid uint16, tableID byte, tableIDExt uint16, data []byte) []byte {
+	// pointer field, table header, data, CRC
+	size := 5 + len(data) + 4
+
+	section := []byte{
+		0, tableID, 0xB0 | byte(size>>8), byte(size),
+		byte(tableIDExt >> 8), byte(tableIDExt), 0xC1, 0, 0,
+	}
+	section = append(section, data...)
+
+	crc := checksum(section[1:])
+	section = append(section, byte(crc>>24), byte(crc>>16), byte(crc>>8), byte(crc))
+
+	b := m.writeHeader(nil, pid, true, nil)
+	b = append(b, section...)
+	for len(b) < PacketSize {
+		b = append(b, 0xFF)
+	}
+	return b
+}
+
+func (m *Muxer) typeOf(pid uint16) byte {
+	for i, p := range m.pids {
+		if p == pid {
+			return m.types[i]
+		}
+	}
+	return 0
+}
+
+func isVideo(streamType byte) bool {
+	return streamType == StreamTypeH264 || streamType == StreamTypeH265
+}
+
+var crcTable = func() (table [256]uint32) {
+	for i := range table {
+		crc := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if crc&0x80000000 != 0 {
+				crc = crc<<1 ^ 0x04C11DB7
+			} else {
+				crc <<= 1
+			}
+		}
+		table[i] = crc
+	}
+	return
+}()
+
+// checksum - CRC-32/MPEG-2 of PSI section
+func checksum(b []byte) uint32 {
+	crc := uint32(0xFFFFFFFF)
+	for _, v := range b {
+		crc = crc<<8 ^ crcTable[byte(crc>>24)^v]
+	}
+	return crc
+}
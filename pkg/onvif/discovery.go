@@ -0,0 +1,223 @@
+package onvif
+
+import (
+	"crypto/rand"
+	"encoding/xml"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DiscoveryAddress - WS-Discovery multicast group and port
+const DiscoveryAddress = "239.255.255.250:3702"
+
+// Device - camera found with WS-Discovery
+type Device struct {
+	ID       string   `json:"id"`  // endpoint reference, ex. urn:uuid:...
+	URL      string   `json:"url"` // link ready for streams config
+	Name     string   `json:"name,omitempty"`
+	Hardware string   `json:"hardware,omitempty"`
+	Location string   `json:"location,omitempty"`
+	XAddrs   []string `json:"xaddrs"`
+	Scopes   []string `json:"scopes,omitempty"`
+}
+
+// Discovery sends Probe for NetworkVideoTransmitter devices and collects
+// ProbeMatches until timeout
+func Discovery(timeout time.Duration) ([]*Device, error) {
+	addr, err := net.ResolveUDPAddr("udp4", DiscoveryAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if _, err = conn.WriteTo(probeMessage(), addr); err != nil {
+		return nil, err
+	}
+
+	if err = conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+
+	var devices []*Device
+
+	b := make([]byte, 64*1024)
+	for {
+		n, _, err := conn.ReadFrom(b)
+		if err != nil {
+			break // timeout
+		}
+
+		for _, device := range ParseProbeMatches(b[:n]) {
+			devices = appendDevice(devices, device)
+		}
+	}
+
+	return devices, nil
+}
+
+// appendDevice skips device that already answered, devices without endpoint
+// reference are compared by XAddrs
+func appendDevice(devices []*Device, device *Device) []*Device {
+	for _, d := range devices {
+		if device.ID != "" {
+			if d.ID == device.ID {
+				return devices
+			}
+		} else if d.ID == "" && strings.Join(d.XAddrs, " ") == strings.Join(device.XAddrs, " ") {
+			return devices
+		}
+	}
+	return append(devices, device)
+}
+
+// ParseProbeMatches parses WS-Discovery ProbeMatches message
+func ParseProbeMatches(b []byte) []*Device {
+	var msg struct {
+		Matches []struct {
+			Address string `xml:"EndpointReference>Address"`
+			Scopes  string `xml:"Scopes"`
+			XAddrs  string `xml:"XAddrs"`
+		} `xml:"Body>ProbeMatches>ProbeMatch"`
+	}
+
+	if err := xml.Unmarshal(b, &msg); err != nil {
+		return nil
+	}
+
+	var devices []*Device
+
+	for _, match := range msg.Matches {
+		device := &Device{
+			ID:     strings.TrimSpace(match.Address),
+			XAddrs: strings.Fields(match.XAddrs),
+			Scopes: strings.Fields(match.Scopes),
+		}
+
+		if len(device.XAddrs) == 0 {
+			continue
+		}
+
+		// onvif://www.onvif.org/name/IPC-model
+		for _, scope := range device.Scopes {
+			const prefix = "onvif://www.onvif.org/"
+			if !strings.HasPrefix(scope, prefix) {
+				continue
+			}
+
+			ss := strings.SplitN(scope[len(prefix):], "/", 2)
+			if len(ss) != 2 {
+				continue
+			}
+
+			value, err := url.PathUnescape(ss[1])
+			if err != nil {
+				value = ss[1]
+			}
+
+			switch ss[0] {
+			case "name":
+				device.Name = value
+			case "hardware":
+				device.Hardware = value
+			case "location":
+				device.Location = value
+			}
+		}
+
+		if u, err := url.Parse(device.XAddrs[0]); err == nil {
+			device.URL = "onvif://" + u.Host
+			if u.Path != "/onvif/device_service" {
+				device.URL += u.Path
+			}
+		}
+
+		devices = append(devices, device)
+	}
+
+	return devices
+}
+
+// ParseProbe returns MessageID of WS-Discovery Probe message for
+// NetworkVideoTransmitter or any device, empty string for other messages
+func ParseProbe(b []byte) string {
+	var msg struct {
+		MessageID string `xml:"Header>MessageID"`
+		Probe     *struct {
+			Types string `xml:"Types"`
+		} `xml:"Body>Probe"`
+	}
+
+	if err := xml.Unmarshal(b, &msg); err != nil || msg.Probe == nil {
+		return ""
+	}
+
+	types := strings.Fields(msg.Probe.Types)
+	if len(types) == 0 {
+		return strings.TrimSpace(msg.MessageID)
+	}
+
+	// types with any namespace prefix, ex. dn:NetworkVideoTransmitter
+	for _, item := range types {
+		if strings.HasSuffix(item, "NetworkVideoTransmitter") || strings.HasSuffix(item, "Device") {
+			return strings.TrimSpace(msg.MessageID)
+		}
+	}
+
+	return ""
+}
+
+// ProbeMatchesMessage - answer to Probe with relatesTo MessageID, address is
+// endpoint reference (urn:uuid:...)
+func ProbeMatchesMessage(relatesTo, address string, xaddrs, scopes []string) []byte {
+	return []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope" xmlns:a="http://schemas.xmlsoap.org/ws/2004/08/addressing" xmlns:d="http://schemas.xmlsoap.org/ws/2005/04/discovery" xmlns:dn="http://www.onvif.org/ver10/network/wsdl">
+<s:Header>
+<a:Action s:mustUnderstand="1">http://schemas.xmlsoap.org/ws/2005/04/discovery/ProbeMatches</a:Action>
+<a:MessageID>` + newUUID() + `</a:MessageID>
+<a:RelatesTo>` + escape(relatesTo) + `</a:RelatesTo>
+<a:To s:mustUnderstand="1">http://schemas.xmlsoap.org/ws/2004/08/addressing/role/anonymous</a:To>
+</s:Header>
+<s:Body>
+<d:ProbeMatches>
+<d:ProbeMatch>
+<a:EndpointReference><a:Address>` + escape(address) + `</a:Address></a:EndpointReference>
+<d:Types>dn:NetworkVideoTransmitter</d:Types>
+<d:Scopes>` + escape(strings.Join(scopes, " ")) + `</d:Scopes>
+<d:XAddrs>` + escape(strings.Join(xaddrs, " ")) + `</d:XAddrs>
+<d:MetadataVersion>1</d:MetadataVersion>
+</d:ProbeMatch>
+</d:ProbeMatches>
+</s:Body>
+</s:Envelope>`)
+}
+
+func probeMessage() []byte {
+	return []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope" xmlns:a="http://schemas.xmlsoap.org/ws/2004/08/addressing">
+<s:Header>
+<a:Action s:mustUnderstand="1">http://schemas.xmlsoap.org/ws/2005/04/discovery/Probe</a:Action>
+<a:MessageID>` + newUUID() + `</a:MessageID>
+<a:ReplyTo><a:Address>http://schemas.xmlsoap.org/ws/2004/08/addressing/role/anonymous</a:Address></a:ReplyTo>
+<a:To s:mustUnderstand="1">urn:schemas-xmlsoap-org:ws:2005:04:discovery</a:To>
+</s:Header>
+<s:Body>
+<Probe xmlns="http://schemas.xmlsoap.org/ws/2005/04/discovery">
+<d:Types xmlns:d="http://schemas.xmlsoap.org/ws/2005/04/discovery" xmlns:dn="http://www.onvif.org/ver10/network/wsdl">dn:NetworkVideoTransmitter</d:Types>
+</Probe>
+</s:Body>
+</s:Envelope>`)
+}
+
+func newUUID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", b[:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
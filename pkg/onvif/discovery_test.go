@@ -0,0 +1,57 @@
+package onvif
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseProbeMatches(t *testing.T) {
+	msg := `<?xml version="1.0" encoding="UTF-8"?>
+<SOAP-ENV:Envelope xmlns:SOAP-ENV="http://www.w3.org/2003/05/soap-envelope" xmlns:wsa="http://schemas.xmlsoap.org/ws/2004/08/addressing" xmlns:d="http://schemas.xmlsoap.org/ws/2005/04/discovery">
+<SOAP-ENV:Body><d:ProbeMatches><d:ProbeMatch>
+<wsa:EndpointReference><wsa:Address>urn:uuid:2419d68a-2dd2-21b2-a205-ec71db000001</wsa:Address></wsa:EndpointReference>
+<d:Types>dn:NetworkVideoTransmitter tds:Device</d:Types>
+<d:Scopes>onvif://www.onvif.org/type/video_encoder onvif://www.onvif.org/name/IPC%20Camera onvif://www.onvif.org/hardware/DS-2CD2043 onvif://www.onvif.org/location/city/hangzhou</d:Scopes>
+<d:XAddrs>http://192.168.1.123/onvif/device_service http://[fe80::1]/onvif/device_service</d:XAddrs>
+<d:MetadataVersion>10</d:MetadataVersion>
+</d:ProbeMatch></d:ProbeMatches></SOAP-ENV:Body>
+</SOAP-ENV:Envelope>`
+
+	devices := ParseProbeMatches([]byte(msg))
+	assert.Len(t, devices, 1)
+
+	device := devices[0]
+	assert.Equal(t, "urn:uuid:2419d68a-2dd2-21b2-a205-ec71db000001", device.ID)
+	assert.Equal(t, "onvif://192.168.1.123", device.URL)
+	assert.Equal(t, "IPC Camera", device.Name)
+	assert.Equal(t, "DS-2CD2043", device.Hardware)
+	assert.Equal(t, "city/hangzhou", device.Location)
+	assert.Len(t, device.XAddrs, 2)
+}
+
+func TestProbeMatches(t *testing.T) {
+	id := ParseProbe(probeMessage())
+	assert.Regexp(t, `^urn:uuid:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`, id)
+
+	msg := ProbeMatchesMessage(
+		id, "urn:uuid:1", []string{"http://192.168.1.2:1984/onvif/device_service"},
+		[]string{"onvif://www.onvif.org/name/go2rtc"},
+	)
+	assert.Equal(t, "", ParseProbe(msg))
+
+	devices := ParseProbeMatches(msg)
+	assert.Len(t, devices, 1)
+	assert.Equal(t, "onvif://192.168.1.2:1984", devices[0].URL)
+	assert.Equal(t, "go2rtc", devices[0].Name)
+}
+
+func TestAppendDevice(t *testing.T) {
+	var devices []*Device
+	devices = appendDevice(devices, &Device{ID: "urn:uuid:1", XAddrs: []string{"http://192.168.1.2/"}})
+	devices = appendDevice(devices, &Device{ID: "urn:uuid:1", XAddrs: []string{"http://192.168.1.2/"}})
+	devices = appendDevice(devices, &Device{XAddrs: []string{"http://192.168.1.3/"}})
+	devices = appendDevice(devices, &Device{XAddrs: []string{"http://192.168.1.3/"}})
+	devices = appendDevice(devices, &Device{XAddrs: []string{"http://192.168.1.4/"}})
+	assert.Len(t, devices, 3)
+	assert.Equal(t, "http://192.168.1.4/", devices[2].XAddrs[0])
+}
@@ -0,0 +1,95 @@
+package onvif
+
+import (
+	"encoding/xml"
+	"errors"
+	"strconv"
+	"time"
+)
+
+const (
+	EventsNamespace = "http://www.onvif.org/ver10/events/wsdl"
+	WSNNamespace    = "http://docs.oasis-open.org/wsn/b-2"
+)
+
+// Subscription - PullPoint subscription of ONVIF Events service
+type Subscription struct {
+	Address string
+
+	client *Client
+}
+
+// CreatePullPointSubscription - subscription will be terminated by camera after ttl
+// without Renew
+func (c *Client) CreatePullPointSubscription(ttl time.Duration) (*Subscription, error) {
+	if err := c.initMedia(); err != nil {
+		return nil, err
+	}
+	if c.EventsURL == "" {
+		return nil, errors.New("onvif: events service not supported")
+	}
+
+	b, err := c.Request(c.EventsURL, `<CreatePullPointSubscription xmlns="`+EventsNamespace+`">`+
+		`<InitialTerminationTime>`+duration(ttl)+`</InitialTerminationTime></CreatePullPointSubscription>`)
+	if err != nil {
+		return nil, err
+	}
+
+	var res struct {
+		Address string `xml:"Body>CreatePullPointSubscriptionResponse>SubscriptionReference>Address"`
+	}
+	if err = xml.Unmarshal(b, &res); err != nil {
+		return nil, err
+	}
+	if res.Address == "" {
+		return nil, errors.New("onvif: empty subscription address")
+	}
+
+	return &Subscription{Address: c.fixHost(res.Address), client: c}, nil
+}
+
+// PullMessages waits for events up to timeout (long polling)
+func (s *Subscription) PullMessages(timeout time.Duration, limit int) ([]*Event, error) {
+	b, err := s.request(`<PullMessages xmlns="`+EventsNamespace+`">`+
+		`<Timeout>`+duration(timeout)+`</Timeout>`+
+		`<MessageLimit>`+strconv.Itoa(limit)+`</MessageLimit></PullMessages>`, timeout+Timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	var res struct {
+		Messages []notificationMessage `xml:"Body>PullMessagesResponse>NotificationMessage"`
+	}
+	if err = xml.Unmarshal(b, &res); err != nil {
+		return nil, err
+	}
+
+	events := make([]*Event, 0, len(res.Messages))
+	for _, msg := range res.Messages {
+		events = append(events, msg.Event())
+	}
+	return events, nil
+}
+
+func (s *Subscription) Renew(ttl time.Duration) error {
+	_, err := s.request(`<Renew xmlns="`+WSNNamespace+`">`+
+		`<TerminationTime>`+duration(ttl)+`</TerminationTime></Renew>`, Timeout)
+	return err
+}
+
+func (s *Subscription) Unsubscribe() error {
+	_, err := s.request(`<Unsubscribe xmlns="`+WSNNamespace+`"/>`, Timeout)
+	return err
+}
+
+func (s *Subscription) request(body string, timeout time.Duration) ([]byte, error) {
+	// many cameras want WS-Addressing header with subscription address
+	header := `<To xmlns="http://www.w3.org/2005/08/addressing" s:mustUnderstand="1">` +
+		escape(s.Address) + `</To>`
+	return s.client.request(s.Address, header, body, timeout)
+}
+
+// duration in xs:duration format, ex. PT60S
+func duration(d time.Duration) string {
+	return "PT" + strconv.Itoa(int(d.Seconds())) + "S"
+}
@@ -0,0 +1,111 @@
+package onvif
+
+import (
+	"encoding/xml"
+	"github.com/pion/rtp"
+	"strings"
+	"time"
+)
+
+// Event - ONVIF notification message (motion, analytics, IO and other events)
+type Event struct {
+	Topic     string            `json:"topic"`
+	Time      time.Time         `json:"time"`
+	Operation string            `json:"operation,omitempty"` // Initialized, Changed or Deleted
+	Source    map[string]string `json:"source,omitempty"`
+	Data      map[string]string `json:"data,omitempty"`
+}
+
+func (e *Event) String() string {
+	s := e.Topic
+	for k, v := range e.Data {
+		s += " " + k + "=" + v
+	}
+	return s
+}
+
+// Metadata collects XML documents from RTP packets of metadata stream,
+// marker bit is set on the last packet of each document
+type Metadata struct {
+	buf []byte
+}
+
+// MaxMetadataSize - protection from broken streams without marker bit
+const MaxMetadataSize = 1024 * 1024
+
+// Push returns events from the XML document when it is completed
+func (m *Metadata) Push(packet *rtp.Packet) []*Event {
+	m.buf = append(m.buf, packet.Payload...)
+
+	if !packet.Marker {
+		if len(m.buf) > MaxMetadataSize {
+			m.buf = nil
+		}
+		return nil
+	}
+
+	events, _ := ParseMetadata(m.buf)
+	m.buf = nil
+	return events
+}
+
+// ParseMetadata parses tt:MetadataStream document, only events part
+// https://www.onvif.org/specs/stream/ONVIF-Streaming-Spec.pdf
+func ParseMetadata(b []byte) ([]*Event, error) {
+	var stream struct {
+		Events []struct {
+			Messages []notificationMessage `xml:"NotificationMessage"`
+		} `xml:"Event"`
+	}
+
+	if err := xml.Unmarshal(b, &stream); err != nil {
+		return nil, err
+	}
+
+	var events []*Event
+	for _, item := range stream.Events {
+		for _, msg := range item.Messages {
+			events = append(events, msg.Event())
+		}
+	}
+	return events, nil
+}
+
+// notificationMessage - wsnt:NotificationMessage, same for metadata stream
+// and for event service
+type notificationMessage struct {
+	Topic   string `xml:"Topic"`
+	Message struct {
+		UtcTime           string       `xml:"UtcTime,attr"`
+		PropertyOperation string       `xml:"PropertyOperation,attr"`
+		Source            []simpleItem `xml:"Source>SimpleItem"`
+		Data              []simpleItem `xml:"Data>SimpleItem"`
+	} `xml:"Message>Message"`
+}
+
+type simpleItem struct {
+	Name  string `xml:"Name,attr"`
+	Value string `xml:"Value,attr"`
+}
+
+func (n *notificationMessage) Event() *Event {
+	event := &Event{
+		Topic:     strings.TrimSpace(n.Topic),
+		Operation: n.Message.PropertyOperation,
+		Source:    simpleItems(n.Message.Source),
+		Data:      simpleItems(n.Message.Data),
+	}
+	event.Time, _ = time.Parse(time.RFC3339, n.Message.UtcTime)
+	return event
+}
+
+func simpleItems(items []simpleItem) map[string]string {
+	if items == nil {
+		return nil
+	}
+	m := make(map[string]string, len(items))
+	for _, item := range items {
+		m[item.Name] = item.Value
+	}
+	return m
+}
@@ -0,0 +1,39 @@
+package onvif
+
+import (
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+const metadataStream = `<?xml version="1.0" encoding="UTF-8"?>
+<tt:MetadataStream xmlns:tt="http://www.onvif.org/ver10/schema" xmlns:wsnt="http://docs.oasis-open.org/wsn/b-2" xmlns:tns1="http://www.onvif.org/ver10/topics">
+<tt:Event><wsnt:NotificationMessage>
+<wsnt:Topic Dialect="http://www.onvif.org/ver10/tev/topicExpression/ConcreteSet">tns1:RuleEngine/CellMotionDetector/Motion</wsnt:Topic>
+<wsnt:Message><tt:Message UtcTime="2023-01-02T03:04:05Z" PropertyOperation="Changed">
+<tt:Source><tt:SimpleItem Name="VideoSourceConfigurationToken" Value="VideoSourceToken"/></tt:Source>
+<tt:Data><tt:SimpleItem Name="IsMotion" Value="true"/></tt:Data>
+</tt:Message></wsnt:Message>
+</wsnt:NotificationMessage></tt:Event>
+</tt:MetadataStream>`
+
+func TestMetadata(t *testing.T) {
+	m := &Metadata{}
+
+	// document split between two packets
+	i := len(metadataStream) / 2
+	events := m.Push(&rtp.Packet{Payload: []byte(metadataStream[:i])})
+	assert.Nil(t, events)
+
+	events = m.Push(&rtp.Packet{
+		Header: rtp.Header{Marker: true}, Payload: []byte(metadataStream[i:]),
+	})
+	assert.Len(t, events, 1)
+
+	event := events[0]
+	assert.Equal(t, "tns1:RuleEngine/CellMotionDetector/Motion", event.Topic)
+	assert.Equal(t, "Changed", event.Operation)
+	assert.Equal(t, "VideoSourceToken", event.Source["VideoSourceConfigurationToken"])
+	assert.Equal(t, "true", event.Data["IsMotion"])
+	assert.Equal(t, 2023, event.Time.Year())
+}
@@ -0,0 +1,116 @@
+package onvif
+
+import (
+	"encoding/xml"
+	"errors"
+	"strconv"
+)
+
+const PTZNamespace = "http://www.onvif.org/ver20/ptz/wsdl"
+
+type Preset struct {
+	Token string `json:"token"`
+	Name  string `json:"name"`
+}
+
+// ContinuousMove starts moving with velocity from -1 to 1 until Stop
+func (c *Client) ContinuousMove(profile string, x, y, zoom float64) error {
+	return c.ptz(`<ContinuousMove xmlns="` + PTZNamespace + `">` +
+		profileToken(profile) + `<Velocity>` + vector(x, y, &zoom) + `</Velocity></ContinuousMove>`)
+}
+
+func (c *Client) Stop(profile string) error {
+	return c.ptz(`<Stop xmlns="` + PTZNamespace + `">` + profileToken(profile) +
+		`<PanTilt>true</PanTilt><Zoom>true</Zoom></Stop>`)
+}
+
+// AbsoluteMove moves to position, pan and tilt from -1 to 1, zoom from 0 to 1,
+// nil zoom keeps current zoom
+func (c *Client) AbsoluteMove(profile string, x, y float64, zoom *float64) error {
+	return c.ptz(`<AbsoluteMove xmlns="` + PTZNamespace + `">` +
+		profileToken(profile) + `<Position>` + vector(x, y, zoom) + `</Position></AbsoluteMove>`)
+}
+
+func (c *Client) GetPresets(profile string) ([]*Preset, error) {
+	b, err := c.ptzRequest(`<GetPresets xmlns="` + PTZNamespace + `">` + profileToken(profile) + `</GetPresets>`)
+	if err != nil {
+		return nil, err
+	}
+
+	var res struct {
+		Presets []struct {
+			Token string `xml:"token,attr"`
+			Name  string `xml:"Name"`
+		} `xml:"Body>GetPresetsResponse>Preset"`
+	}
+	if err = xml.Unmarshal(b, &res); err != nil {
+		return nil, err
+	}
+
+	presets := make([]*Preset, 0, len(res.Presets))
+	for _, item := range res.Presets {
+		presets = append(presets, &Preset{Token: item.Token, Name: item.Name})
+	}
+	return presets, nil
+}
+
+func (c *Client) GotoPreset(profile, preset string) error {
+	return c.ptz(`<GotoPreset xmlns="` + PTZNamespace + `">` + profileToken(profile) +
+		`<PresetToken>` + escape(preset) + `</PresetToken></GotoPreset>`)
+}
+
+// SetPreset saves current position as preset with name, returns preset token
+func (c *Client) SetPreset(profile, name string) (string, error) {
+	b, err := c.ptzRequest(`<SetPreset xmlns="` + PTZNamespace + `">` + profileToken(profile) +
+		`<PresetName>` + escape(name) + `</PresetName></SetPreset>`)
+	if err != nil {
+		return "", err
+	}
+
+	var res struct {
+		Token string `xml:"Body>SetPresetResponse>PresetToken"`
+	}
+	if err = xml.Unmarshal(b, &res); err != nil {
+		return "", err
+	}
+	return res.Token, nil
+}
+
+func (c *Client) GotoHomePosition(profile string) error {
+	return c.ptz(`<GotoHomePosition xmlns="` + PTZNamespace + `">` + profileToken(profile) + `</GotoHomePosition>`)
+}
+
+func (c *Client) SetHomePosition(profile string) error {
+	return c.ptz(`<SetHomePosition xmlns="` + PTZNamespace + `">` + profileToken(profile) + `</SetHomePosition>`)
+}
+
+func (c *Client) ptz(body string) error {
+	_, err := c.ptzRequest(body)
+	return err
+}
+
+func (c *Client) ptzRequest(body string) ([]byte, error) {
+	if err := c.initMedia(); err != nil {
+		return nil, err
+	}
+	if c.PTZURL == "" {
+		return nil, errors.New("onvif: PTZ service not supported")
+	}
+	return c.Request(c.PTZURL, body)
+}
+
+func profileToken(profile string) string {
+	return `<ProfileToken>` + escape(profile) + `</ProfileToken>`
+}
+
+func vector(x, y float64, zoom *float64) string {
+	s := `<PanTilt xmlns="` + SchemaNamespace + `" x="` + ftoa(x) + `" y="` + ftoa(y) + `"/>`
+	if zoom != nil {
+		s += `<Zoom xmlns="` + SchemaNamespace + `" x="` + ftoa(*zoom) + `"/>`
+	}
+	return s
+}
+
+func ftoa(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,176 @@
+package onvif
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strconv"
+	"time"
+)
+
+// Server side of Device and Media services, enough for NVRs with Profile S:
+// they read capabilities, profiles and RTSP link for each profile
+
+const serverEnvelopeHeader = `<?xml version="1.0" encoding="UTF-8"?>
+<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope" xmlns:tds="` + DeviceNamespace +
+	`" xmlns:trt="` + MediaNamespace + `" xmlns:tt="` + SchemaNamespace +
+	`" xmlns:ter="http://www.onvif.org/ver10/error">`
+
+// ServerEnvelope builds SOAP response, body can use tds, trt and tt prefixes
+func ServerEnvelope(body string) []byte {
+	return []byte(serverEnvelopeHeader + "<s:Body>" + body + "</s:Body></s:Envelope>")
+}
+
+// FaultEnvelope builds SOAP Fault response, ex. for ActionNotSupported
+func FaultEnvelope(code, reason string) []byte {
+	return ServerEnvelope(`<s:Fault><s:Code><s:Value>s:Sender</s:Value>` +
+		`<s:Subcode><s:Value>ter:` + code + `</s:Value></s:Subcode></s:Code>` +
+		`<s:Reason><s:Text xml:lang="en">` + escape(reason) + `</s:Text></s:Reason></s:Fault>`)
+}
+
+// GetRequestAction returns name of the first element in the SOAP Body,
+// ex. GetProfiles
+func GetRequestAction(b []byte) string {
+	var body bool
+	d := xml.NewDecoder(bytes.NewReader(b))
+	for {
+		token, err := d.Token()
+		if err != nil {
+			return ""
+		}
+		if el, ok := token.(xml.StartElement); ok {
+			if body {
+				return el.Name.Local
+			}
+			body = el.Name.Local == "Body"
+		}
+	}
+}
+
+// GetRequestValue returns text of the first element with name in the request,
+// ex. ProfileToken
+func GetRequestValue(b []byte, name string) string {
+	d := xml.NewDecoder(bytes.NewReader(b))
+	for {
+		token, err := d.Token()
+		if err != nil {
+			return ""
+		}
+		if el, ok := token.(xml.StartElement); ok && el.Name.Local == name {
+			var s string
+			if err = d.DecodeElement(&s, &el); err != nil {
+				return ""
+			}
+			return s
+		}
+	}
+}
+
+func SystemDateAndTimeResponse(ts time.Time) string {
+	ts = ts.UTC()
+	return `<tds:GetSystemDateAndTimeResponse><tds:SystemDateAndTime>` +
+		`<tt:DateTimeType>NTP</tt:DateTimeType><tt:DaylightSavings>false</tt:DaylightSavings>` +
+		`<tt:TimeZone><tt:TZ>UTC</tt:TZ></tt:TimeZone><tt:UTCDateTime>` +
+		`<tt:Time><tt:Hour>` + itoa(ts.Hour()) + `</tt:Hour><tt:Minute>` + itoa(ts.Minute()) +
+		`</tt:Minute><tt:Second>` + itoa(ts.Second()) + `</tt:Second></tt:Time>` +
+		`<tt:Date><tt:Year>` + itoa(ts.Year()) + `</tt:Year><tt:Month>` + itoa(int(ts.Month())) +
+		`</tt:Month><tt:Day>` + itoa(ts.Day()) + `</tt:Day></tt:Date>` +
+		`</tt:UTCDateTime></tds:SystemDateAndTime></tds:GetSystemDateAndTimeResponse>`
+}
+
+// CapabilitiesResponse with device and media services on host, ex. 192.168.1.123:1984
+func CapabilitiesResponse(host string) string {
+	return `<tds:GetCapabilitiesResponse><tds:Capabilities>` +
+		`<tt:Device><tt:XAddr>http://` + escape(host) + DevicePath + `</tt:XAddr></tt:Device>` +
+		`<tt:Media><tt:XAddr>http://` + escape(host) + MediaPath + `</tt:XAddr>` +
+		`<tt:StreamingCapabilities><tt:RTPMulticast>false</tt:RTPMulticast>` +
+		`<tt:RTP_TCP>true</tt:RTP_TCP><tt:RTP_RTSP_TCP>true</tt:RTP_RTSP_TCP></tt:StreamingCapabilities>` +
+		`</tt:Media></tds:Capabilities></tds:GetCapabilitiesResponse>`
+}
+
+func ServicesResponse(host string) string {
+	return `<tds:GetServicesResponse>` +
+		service(DeviceNamespace, "http://"+host+DevicePath) +
+		service(MediaNamespace, "http://"+host+MediaPath) +
+		`</tds:GetServicesResponse>`
+}
+
+func DeviceInformationResponse(info *DeviceInformation) string {
+	return `<tds:GetDeviceInformationResponse>` +
+		`<tds:Manufacturer>` + escape(info.Manufacturer) + `</tds:Manufacturer>` +
+		`<tds:Model>` + escape(info.Model) + `</tds:Model>` +
+		`<tds:FirmwareVersion>` + escape(info.FirmwareVersion) + `</tds:FirmwareVersion>` +
+		`<tds:SerialNumber>` + escape(info.SerialNumber) + `</tds:SerialNumber>` +
+		`<tds:HardwareId>` + escape(info.HardwareId) + `</tds:HardwareId>` +
+		`</tds:GetDeviceInformationResponse>`
+}
+
+func ScopesResponse(scopes []string) string {
+	s := `<tds:GetScopesResponse>`
+	for _, scope := range scopes {
+		s += `<tds:Scopes><tt:ScopeDef>Fixed</tt:ScopeDef><tt:ScopeItem>` + escape(scope) + `</tt:ScopeItem></tds:Scopes>`
+	}
+	return s + `</tds:GetScopesResponse>`
+}
+
+// ProfilesResponse - profile token is also name of its video source and encoder
+func ProfilesResponse(profiles []*Profile) string {
+	s := `<trt:GetProfilesResponse>`
+	for _, p := range profiles {
+		s += profile("trt:Profiles", p)
+	}
+	return s + `</trt:GetProfilesResponse>`
+}
+
+func ProfileResponse(p *Profile) string {
+	return `<trt:GetProfileResponse>` + profile("trt:Profile", p) + `</trt:GetProfileResponse>`
+}
+
+func VideoSourcesResponse(profiles []*Profile) string {
+	s := `<trt:GetVideoSourcesResponse>`
+	for _, p := range profiles {
+		s += `<trt:VideoSources token="` + escape(p.Token) + `"><tt:Framerate>25</tt:Framerate>` +
+			resolution(p) + `</trt:VideoSources>`
+	}
+	return s + `</trt:GetVideoSourcesResponse>`
+}
+
+func StreamUriResponse(uri string) string {
+	return `<trt:GetStreamUriResponse><trt:MediaUri><tt:Uri>` + escape(uri) + `</tt:Uri>` +
+		`<tt:InvalidAfterConnect>false</tt:InvalidAfterConnect>` +
+		`<tt:InvalidAfterReboot>false</tt:InvalidAfterReboot>` +
+		`<tt:Timeout>PT0S</tt:Timeout></trt:MediaUri></trt:GetStreamUriResponse>`
+}
+
+const (
+	DevicePath = "/onvif/device_service"
+	MediaPath  = "/onvif/media_service"
+)
+
+func service(namespace, xaddr string) string {
+	return `<tds:Service><tds:Namespace>` + namespace + `</tds:Namespace>` +
+		`<tds:XAddr>` + escape(xaddr) + `</tds:XAddr>` +
+		`<tds:Version><tt:Major>2</tt:Major><tt:Minor>5</tt:Minor></tds:Version></tds:Service>`
+}
+
+// profile with video source and encoder configurations from the profile codec
+func profile(tag string, p *Profile) string {
+	token := escape(p.Token)
+	name := escape(p.Name)
+	return `<` + tag + ` token="` + token + `" fixed="true"><tt:Name>` + name + `</tt:Name>` +
+		`<tt:VideoSourceConfiguration token="` + token + `"><tt:Name>` + name + `</tt:Name>` +
+		`<tt:UseCount>1</tt:UseCount><tt:SourceToken>` + token + `</tt:SourceToken>` +
+		`<tt:Bounds x="0" y="0" width="` + itoa(p.Width) + `" height="` + itoa(p.Height) + `"/>` +
+		`</tt:VideoSourceConfiguration>` +
+		`<tt:VideoEncoderConfiguration token="` + token + `"><tt:Name>` + name + `</tt:Name>` +
+		`<tt:UseCount>1</tt:UseCount><tt:Encoding>` + escape(p.Encoding) + `</tt:Encoding>` + resolution(p) +
+		`<tt:Quality>5</tt:Quality></tt:VideoEncoderConfiguration></` + tag + `>`
+}
+
+func resolution(p *Profile) string {
+	return `<tt:Resolution><tt:Width>` + itoa(p.Width) + `</tt:Width><tt:Height>` + itoa(p.Height) +
+		`</tt:Height></tt:Resolution>`
+}
+
+func itoa(i int) string {
+	return strconv.Itoa(i)
+}
@@ -0,0 +1,108 @@
+package onvif
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+const (
+	DeviceNamespace = "http://www.onvif.org/ver10/device/wsdl"
+	MediaNamespace  = "http://www.onvif.org/ver10/media/wsdl"
+	SchemaNamespace = "http://www.onvif.org/ver10/schema"
+)
+
+const envelopeHeader = `<?xml version="1.0" encoding="UTF-8"?>
+<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope">`
+
+// Envelope builds SOAP 1.2 message with optional WS-Security UsernameToken,
+// header should be empty for requests without auth
+func Envelope(header, body string) []byte {
+	s := envelopeHeader
+	if header != "" {
+		s += "<s:Header>" + header + "</s:Header>"
+	}
+	s += "<s:Body>" + body + "</s:Body></s:Envelope>"
+	return []byte(s)
+}
+
+// UsernameToken - WS-Security header with password digest
+// Digest = Base64(SHA1(Nonce + Created + Password))
+func UsernameToken(username, password string, created time.Time) string {
+	nonce := make([]byte, 16)
+	_, _ = rand.Read(nonce)
+
+	ts := created.UTC().Format("2006-01-02T15:04:05.000Z")
+
+	h := sha1.New()
+	h.Write(nonce)
+	h.Write([]byte(ts))
+	h.Write([]byte(password))
+	digest := base64.StdEncoding.EncodeToString(h.Sum(nil))
+
+	return `<Security s:mustUnderstand="1" xmlns="http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-secext-1.0.xsd">` +
+		`<UsernameToken><Username>` + escape(username) + `</Username>` +
+		`<Password Type="http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-username-token-profile-1.0#PasswordDigest">` + digest + `</Password>` +
+		`<Nonce EncodingType="http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-soap-message-security-1.0#Base64Binary">` +
+		base64.StdEncoding.EncodeToString(nonce) + `</Nonce>` +
+		`<Created xmlns="http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-utility-1.0.xsd">` + ts + `</Created>` +
+		`</UsernameToken></Security>`
+}
+
+// Timeout - default timeout for SOAP requests
+const Timeout = 5 * time.Second
+
+// Post sends SOAP request and returns response body, SOAP Fault returns as error
+func Post(url string, envelope []byte) ([]byte, error) {
+	return post(url, envelope, Timeout)
+}
+
+func post(url string, envelope []byte, timeout time.Duration) ([]byte, error) {
+	client := http.Client{Timeout: timeout}
+
+	res, err := client.Post(url, "application/soap+xml; charset=utf-8", bytes.NewReader(envelope))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		if msg := faultReason(b); msg != "" {
+			return nil, errors.New(msg)
+		}
+		return nil, errors.New("wrong response: " + res.Status)
+	}
+
+	return b, nil
+}
+
+func faultReason(b []byte) string {
+	var fault struct {
+		Reason string `xml:"Body>Fault>Reason>Text"`
+		Code   string `xml:"Body>Fault>Code>Subcode>Value"`
+	}
+	if err := xml.Unmarshal(b, &fault); err != nil {
+		return ""
+	}
+	if fault.Reason != "" {
+		return fault.Reason
+	}
+	return fault.Code
+}
+
+func escape(s string) string {
+	var buf bytes.Buffer
+	_ = xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
@@ -2,8 +2,8 @@ package rtmp
 
 import (
 	"encoding/base64"
-	"encoding/hex"
 	"fmt"
+	"github.com/AlexxIT/go2rtc/pkg/aac"
 	"github.com/AlexxIT/go2rtc/pkg/h264"
 	"github.com/AlexxIT/go2rtc/pkg/streamer"
 	"github.com/deepch/vdk/av"
@@ -11,7 +11,6 @@ import (
 	"github.com/deepch/vdk/codec/h264parser"
 	"github.com/deepch/vdk/format/rtmp"
 	"github.com/pion/rtp"
-	"time"
 )
 
 type Client struct {
@@ -22,22 +21,37 @@ type Client struct {
 	medias []*streamer.Media
 	tracks []*streamer.Track
 
-	conn   *rtmp.Conn
-	closed bool
+	codecs  []*streamer.Codec
+	writers []streamer.WriterFunc
+
+	conn       av.DemuxCloser
+	remoteAddr string
+	proto      string // RTMP, HTTP-FLV or WS-FLV
+	closed     bool
+	server     bool // connection from the RTMP server publish
 
 	receive int
 }
 
 func NewClient(uri string) *Client {
-	return &Client{URI: uri}
+	return &Client{URI: uri, proto: "RTMP"}
 }
 
 func (c *Client) Dial() (err error) {
-	c.conn, err = rtmp.Dial(c.URI)
+	conn, err := rtmp.Dial(c.URI)
 	if err != nil {
 		return
 	}
 
+	c.conn = conn
+	c.remoteAddr = conn.NetConn().RemoteAddr().String()
+
+	return c.describe()
+}
+
+// describe reads codecs of the remote stream, same for client play
+// and for server publish
+func (c *Client) describe() (err error) {
 	// important to get SPS/PPS
 	streams, err := c.conn.Streams()
 	if err != nil {
@@ -45,6 +59,9 @@ func (c *Client) Dial() (err error) {
 	}
 
 	for _, stream := range streams {
+		var codec *streamer.Codec
+		var writer streamer.WriterFunc
+
 		switch stream.Type() {
 		case av.H264:
 			cd := stream.(h264parser.CodecData)
@@ -52,7 +69,7 @@ func (c *Client) Dial() (err error) {
 				base64.StdEncoding.EncodeToString(cd.RecordInfo.SPS[0]) + "," +
 				base64.StdEncoding.EncodeToString(cd.RecordInfo.PPS[0])
 
-			codec := &streamer.Codec{
+			codec = &streamer.Codec{
 				Name:        streamer.CodecH264,
 				ClockRate:   90000,
 				FmtpLine:    fmtp,
@@ -71,21 +88,17 @@ func (c *Client) Dial() (err error) {
 			}
 			c.tracks = append(c.tracks, track)
 
+			writer = track.WriteRTP
+
 		case av.AAC:
-			// TODO: fix support
 			cd := stream.(aacparser.CodecData)
 
-			// a=fmtp:97 streamtype=5;profile-level-id=1;mode=AAC-hbr;sizelength=13;indexlength=3;indexdeltalength=3;config=1588
-			fmtp := fmt.Sprintf(
-				"config=%s",
-				hex.EncodeToString(cd.ConfigBytes),
-			)
-
-			codec := &streamer.Codec{
-				Name:      streamer.CodecAAC,
-				ClockRate: uint32(cd.Config.SampleRate),
-				Channels:  uint16(cd.Config.ChannelConfig),
-				FmtpLine:  fmtp,
+			codec = &streamer.Codec{
+				Name:        streamer.CodecAAC,
+				ClockRate:   uint32(cd.Config.SampleRate),
+				Channels:    uint16(cd.Config.ChannelConfig),
+				FmtpLine:    aac.FmtpLine(cd.ConfigBytes),
+				PayloadType: 96,
 			}
 
 			media := &streamer.Media{
@@ -100,9 +113,16 @@ func (c *Client) Dial() (err error) {
 			}
 			c.tracks = append(c.tracks, track)
 
+			// RTMP has raw access units, RTP needs AU headers (RFC 3640)
+			writer = aac.RTPPay()(track.WriteRTP)
+
 		default:
 			fmt.Printf("[rtmp] unsupported codec %+v\n", stream)
 		}
+
+		// same index as packets of the stream, nil for unsupported codecs
+		c.codecs = append(c.codecs, codec)
+		c.writers = append(c.writers, writer)
 	}
 
 	c.Fire(streamer.StateReady)
@@ -127,12 +147,16 @@ func (c *Client) Handle() (err error) {
 
 		c.receive += len(pkt.Data)
 
-		track := c.tracks[int(pkt.Idx)]
+		if int(pkt.Idx) >= len(c.writers) || c.writers[pkt.Idx] == nil {
+			continue
+		}
+
+		codec := c.codecs[pkt.Idx]
 
-		timestamp := uint32(pkt.Time / time.Duration(track.Codec.ClockRate))
+		timestamp := streamer.RTPTime(pkt.Time, codec.ClockRate)
 
 		var payloads [][]byte
-		if track.Codec.Name == streamer.CodecH264 {
+		if codec.Name == streamer.CodecH264 {
 			payloads = h264.SplitAVC(pkt.Data)
 		} else {
 			payloads = [][]byte{pkt.Data}
@@ -143,7 +167,7 @@ func (c *Client) Handle() (err error) {
 				Header:  rtp.Header{Timestamp: timestamp},
 				Payload: payload,
 			}
-			_ = track.WriteRTP(packet)
+			_ = c.writers[pkt.Idx](packet)
 		}
 	}
 }
@@ -0,0 +1,331 @@
+package rtmp
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/AlexxIT/go2rtc/pkg/aac"
+	"github.com/AlexxIT/go2rtc/pkg/h264"
+	"github.com/AlexxIT/go2rtc/pkg/streamer"
+	"github.com/deepch/vdk/av"
+	"github.com/deepch/vdk/codec/aacparser"
+	"github.com/deepch/vdk/codec/h264parser"
+	"github.com/deepch/vdk/format/rtmp"
+	"github.com/pion/rtp"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Consumer packs H264 and AAC to FLV tags of the RTMP connection,
+// it can be server play connection or client publish connection,
+// or to the FLV stream for HTTP and WebSocket clients
+type Consumer struct {
+	streamer.Element
+
+	URI        string
+	RemoteAddr string
+	UserAgent  string
+
+	conn    av.Muxer  // RTMP connection or FLV muxer
+	closer  io.Closer // nil for FLV stream
+	reader  io.Reader // incoming data of RTMP connection
+	proto   string    // RTMP, HTTP-FLV or WS-FLV
+	client  bool      // client publish connection
+	streams []av.CodecData
+	header  bool
+	video   bool // stream with video starts from the keyframe
+	mx      sync.Mutex
+
+	send int
+}
+
+func NewConsumer(conn *rtmp.Conn) *Consumer {
+	c := &Consumer{conn: conn, closer: conn, proto: "RTMP"}
+	if conn.URL != nil {
+		u := *conn.URL
+		u.RawQuery = ""
+		c.URI = u.String()
+	}
+	if netConn := conn.NetConn(); netConn != nil {
+		c.RemoteAddr = netConn.RemoteAddr().String()
+		c.reader = netConn
+	}
+	return c
+}
+
+// NewPublisher connects to the RTMP(S) server and sends publish command:
+// rtmp://host/app/stream_key
+func NewPublisher(uri string) (*Consumer, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	var netConn net.Conn
+
+	switch u.Scheme {
+	case "rtmp":
+		if u.Port() == "" {
+			u.Host += ":1935"
+		}
+		netConn, err = net.DialTimeout("tcp", u.Host, Timeout)
+	case "rtmps":
+		if u.Port() == "" {
+			u.Host += ":443"
+		}
+		dialer := &net.Dialer{Timeout: Timeout}
+		netConn, err = tls.DialWithDialer(dialer, "tcp", u.Host, &tls.Config{ServerName: u.Hostname()})
+	default:
+		return nil, errors.New("unsupported scheme: " + u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	conn := rtmp.NewConn(netConn)
+	conn.URL = u
+
+	// handshake, connect and publish commands
+	_ = netConn.SetDeadline(time.Now().Add(Timeout))
+	if err = conn.Prepare(); err != nil {
+		_ = netConn.Close()
+		return nil, err
+	}
+	_ = netConn.SetDeadline(time.Time{})
+
+	c := NewConsumer(conn)
+	c.client = true
+	return c, nil
+}
+
+// Timeout - connection and handshake timeout for publish
+const Timeout = 10 * time.Second
+
+func (c *Consumer) GetMedias() []*streamer.Media {
+	return []*streamer.Media{
+		{
+			Kind:      streamer.KindVideo,
+			Direction: streamer.DirectionRecvonly,
+			Codecs: []*streamer.Codec{
+				{Name: streamer.CodecH264, ClockRate: 90000},
+			},
+		},
+		{
+			Kind:      streamer.KindAudio,
+			Direction: streamer.DirectionRecvonly,
+			Codecs: []*streamer.Codec{
+				{Name: streamer.CodecAAC},
+			},
+		},
+	}
+}
+
+func (c *Consumer) AddTrack(media *streamer.Media, track *streamer.Track) *streamer.Track {
+	idx := len(c.streams)
+
+	switch track.Codec.Name {
+	case streamer.CodecH264:
+		c.streams = append(c.streams, nil)
+
+		c.mx.Lock()
+		c.video = true
+		c.mx.Unlock()
+
+		sps, pps := h264.GetParameterSet(track.Codec.FmtpLine)
+		if sps != nil && pps != nil {
+			c.streams[idx], _ = h264parser.NewCodecDataFromSPSAndPPS(sps, pps)
+		}
+
+		var start uint32
+
+		push := func(packet *rtp.Packet) error {
+			if packet.Version != h264.RTPPacketVersionAVC {
+				return nil
+			}
+
+			switch h264.NALUType(packet.Payload) {
+			case h264.NALUTypeSPS:
+				sps = packet.Payload[4:]
+				return nil
+			case h264.NALUTypePPS:
+				pps = packet.Payload[4:]
+				return nil
+			case h264.NALUTypeIFrame:
+				if !c.started() {
+					// SPS/PPS can be changed in the stream
+					if cd, err := h264parser.NewCodecDataFromSPSAndPPS(sps, pps); err == nil {
+						c.mx.Lock()
+						c.streams[idx] = cd
+						c.mx.Unlock()
+					}
+					if !c.writeHeader() {
+						return nil
+					}
+					start = packet.Timestamp
+				}
+			case h264.NALUTypePFrame:
+				if !c.started() {
+					return nil
+				}
+			default:
+				return nil
+			}
+
+			return c.writePacket(av.Packet{
+				Idx:        int8(idx),
+				IsKeyFrame: h264.IsKeyframe(packet.Payload),
+				Time:       duration(packet.Timestamp-start, 90000),
+				Data:       packet.Payload,
+			})
+		}
+
+		if !h264.IsAVC(track.Codec) {
+			wrapper := h264.RTPDepay(track)
+			push = wrapper(push)
+		}
+
+		return track.Bind(push)
+
+	case streamer.CodecAAC:
+		cd, err := aacparser.NewCodecDataFromMPEG4AudioConfigBytes(aac.GetConfig(track.Codec.FmtpLine))
+		if err != nil {
+			// track without config can't be muxed, skip its packets
+			return track.Bind(func(packet *rtp.Packet) error { return nil })
+		}
+
+		c.streams = append(c.streams, cd)
+
+		var start uint32
+		var ok bool
+
+		push := func(packet *rtp.Packet) error {
+			if !c.audioStart() {
+				return nil
+			}
+
+			if !ok {
+				start = packet.Timestamp
+				ok = true
+			}
+
+			return c.writePacket(av.Packet{
+				Idx:  int8(idx),
+				Time: duration(packet.Timestamp-start, track.Codec.ClockRate),
+				Data: packet.Payload,
+			})
+		}
+
+		wrapper := aac.RTPDepay(track)
+		return track.Bind(wrapper(push))
+	}
+
+	fmt.Printf("[rtmp] unsupported codec: %+v\n", track.Codec)
+
+	return nil
+}
+
+// Start blocks until connection is closed, remote side doesn't send anything
+// important, so all incoming data is skipped
+func (c *Consumer) Start() error {
+	if c.reader == nil {
+		return errors.New("rtmp: consumer without connection")
+	}
+	_, err := io.Copy(ioutil.Discard, c.reader)
+	return err
+}
+
+func (c *Consumer) Stop() error {
+	if c.closer == nil {
+		return nil
+	}
+	return c.closer.Close()
+}
+
+func (c *Consumer) MarshalJSON() ([]byte, error) {
+	v := map[string]interface{}{
+		streamer.JSONType:       c.proto + " server consumer",
+		streamer.JSONSend:       c.send,
+		streamer.JSONRemoteAddr: c.RemoteAddr,
+	}
+	if c.client {
+		v[streamer.JSONType] = c.proto + " client consumer"
+	}
+	if c.URI != "" {
+		v["url"] = c.URI
+	}
+	if c.UserAgent != "" {
+		v[streamer.JSONUserAgent] = c.UserAgent
+	}
+	return json.Marshal(v)
+}
+
+func (c *Consumer) started() bool {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return c.header
+}
+
+// audioStart returns true if audio can be sent, stream without video
+// starts from the first audio packet
+func (c *Consumer) audioStart() bool {
+	c.mx.Lock()
+	header, video := c.header, c.video
+	c.mx.Unlock()
+
+	if header {
+		return true
+	}
+	return !video && c.writeHeader()
+}
+
+// writeHeader when codecs of all tracks are known, first packet should be keyframe
+func (c *Consumer) writeHeader() bool {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if c.header {
+		return true
+	}
+
+	for _, stream := range c.streams {
+		if stream == nil {
+			return false
+		}
+	}
+
+	if err := c.conn.WriteHeader(c.streams); err != nil {
+		_ = c.Stop()
+		return false
+	}
+
+	c.header = true
+	return true
+}
+
+func (c *Consumer) writePacket(pkt av.Packet) error {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if err := c.conn.WritePacket(pkt); err != nil {
+		_ = c.Stop()
+		return err
+	}
+
+	// vdk buffers output, trailer is just flush for RTMP connection and FLV muxer
+	if err := c.conn.WriteTrailer(); err != nil {
+		_ = c.Stop()
+		return err
+	}
+
+	c.send += len(pkt.Data)
+	return nil
+}
+
+func duration(ts, clockRate uint32) time.Duration {
+	return time.Duration(ts) * time.Second / time.Duration(clockRate)
+}
@@ -0,0 +1,131 @@
+package rtmp
+
+import (
+	"github.com/AlexxIT/go2rtc/pkg/h264"
+	"github.com/AlexxIT/go2rtc/pkg/streamer"
+	"github.com/deepch/vdk/av"
+	"github.com/deepch/vdk/format/rtmp"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+type testResult struct {
+	path    string
+	streams []av.CodecData
+	packets []av.Packet
+}
+
+// testPublish starts local stand-in for RTMP server and returns publisher
+// connected to it, server reads first packets of the stream
+func testPublish(t *testing.T, packets int) (*Consumer, chan testResult) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	addr := ln.Addr().String()
+	_ = ln.Close()
+
+	results := make(chan testResult, 1)
+
+	srv := &rtmp.Server{Addr: addr}
+	srv.HandlePublish = func(conn *rtmp.Conn) {
+		defer conn.Close()
+
+		res := testResult{path: conn.URL.Path}
+		res.streams, _ = conn.Streams()
+		for i := 0; i < packets; i++ {
+			pkt, err := conn.ReadPacket()
+			if err != nil {
+				break
+			}
+			res.packets = append(res.packets, pkt)
+		}
+		results <- res
+	}
+	go func() { _ = srv.ListenAndServe() }()
+
+	var cons *Consumer
+	for i := 0; i < 10 && cons == nil; i++ {
+		time.Sleep(50 * time.Millisecond)
+		cons, err = NewPublisher("rtmp://" + addr + "/live/stream_key")
+	}
+	assert.Nil(t, err)
+
+	return cons, results
+}
+
+func TestPublisher(t *testing.T) {
+	cons, results := testPublish(t, 3)
+
+	video := &streamer.Track{Codec: &streamer.Codec{
+		Name: streamer.CodecH264, ClockRate: 90000, PayloadType: h264.PayloadTypeAVC,
+		FmtpLine: "packetization-mode=1;sprop-parameter-sets=Z0JAHqaAoD2QAA==,aM48gAA=",
+	}}
+	audio := &streamer.Track{Codec: &streamer.Codec{
+		Name: streamer.CodecAAC, ClockRate: 44100, Channels: 2,
+		FmtpLine: "streamtype=5;mode=AAC-hbr;sizelength=13;indexlength=3;indexdeltalength=3;config=1210",
+	}}
+
+	cons.AddTrack(nil, video)
+	cons.AddTrack(nil, audio)
+
+	// audio before first keyframe is skipped
+	_ = audio.WriteRTP(&rtp.Packet{Payload: []byte{0, 0x10, 0, 0x08, 0xAA}})
+
+	// server reads some packets for probe
+	for i := uint32(0); i < 30; i++ {
+		payload := []byte{0x41, 3, 4}
+		if i == 0 {
+			payload = []byte{0x65, 1, 2}
+		}
+		_ = video.WriteRTP(&rtp.Packet{Header: rtp.Header{Timestamp: 1000 + i*3600}, Payload: h264.EncodeAVC(payload)})
+		_ = audio.WriteRTP(&rtp.Packet{Header: rtp.Header{Timestamp: 500 + i*1024}, Payload: []byte{0, 0x10, 0, 0x10, 0xBB, 0xCC}})
+	}
+
+	select {
+	case res := <-results:
+		assert.Equal(t, "/live/stream_key", res.path)
+		assert.Len(t, res.streams, 2)
+		assert.Equal(t, av.H264, res.streams[0].Type())
+		assert.Equal(t, av.AAC, res.streams[1].Type())
+
+		assert.Len(t, res.packets, 3)
+		assert.True(t, res.packets[0].IsKeyFrame)
+		assert.Equal(t, []byte{0xBB, 0xCC}, res.packets[1].Data)
+		assert.Equal(t, 40*time.Millisecond, res.packets[2].Time)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout")
+	}
+
+	_ = cons.Stop()
+}
+
+func TestPublisherAudio(t *testing.T) {
+	cons, results := testPublish(t, 2)
+
+	audio := &streamer.Track{Codec: &streamer.Codec{
+		Name: streamer.CodecAAC, ClockRate: 44100, Channels: 2,
+		FmtpLine: "streamtype=5;mode=AAC-hbr;sizelength=13;indexlength=3;indexdeltalength=3;config=1210",
+	}}
+
+	cons.AddTrack(nil, audio)
+
+	// stream without video starts from the first audio packet
+	for i := uint32(0); i < 30; i++ {
+		_ = audio.WriteRTP(&rtp.Packet{Header: rtp.Header{Timestamp: 500 + i*1024}, Payload: []byte{0, 0x10, 0, 0x10, 0xBB, byte(i)}})
+	}
+
+	select {
+	case res := <-results:
+		assert.Len(t, res.streams, 1)
+		assert.Equal(t, av.AAC, res.streams[0].Type())
+
+		assert.Len(t, res.packets, 2)
+		assert.Equal(t, []byte{0xBB, 0}, res.packets[0].Data)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout")
+	}
+
+	_ = cons.Stop()
+}
@@ -0,0 +1,44 @@
+package rtmp
+
+import (
+	"github.com/deepch/vdk/format/flv"
+	"io"
+)
+
+// OpenFLV - producer from FLV stream, ex. body of HTTP response (HTTP-FLV)
+// or messages of WebSocket connection (WS-FLV)
+func OpenFLV(uri, proto string, rd io.ReadCloser) (*Client, error) {
+	conn := &flvConn{Demuxer: flv.NewDemuxer(rd), Closer: rd}
+
+	c := &Client{URI: uri, proto: proto, conn: conn}
+	if err := c.describe(); err != nil {
+		_ = rd.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// NewFLVConsumer - consumer for HTTP-FLV and WS-FLV clients, FLV stream is
+// sent with Fire as []byte chunks starting from the FLV header
+func NewFLVConsumer(proto string) *Consumer {
+	c := &Consumer{proto: proto}
+	c.conn = flv.NewMuxer(&fireWriter{c: c})
+	return c
+}
+
+type flvConn struct {
+	*flv.Demuxer
+	io.Closer
+}
+
+type fireWriter struct {
+	c *Consumer
+}
+
+func (w *fireWriter) Write(p []byte) (int, error) {
+	// muxer reuses buffer after flush
+	b := make([]byte, len(p))
+	copy(b, p)
+	w.c.Fire(b)
+	return len(p), nil
+}
@@ -0,0 +1,68 @@
+package rtmp
+
+import (
+	"github.com/AlexxIT/go2rtc/pkg/h264"
+	"github.com/AlexxIT/go2rtc/pkg/streamer"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+)
+
+func TestFLV(t *testing.T) {
+	r, w := io.Pipe()
+
+	cons := NewFLVConsumer("HTTP-FLV")
+	cons.Listen(func(msg interface{}) {
+		if data, ok := msg.([]byte); ok {
+			_, _ = w.Write(data)
+		}
+	})
+
+	video := &streamer.Track{Codec: &streamer.Codec{
+		Name: streamer.CodecH264, ClockRate: 90000, PayloadType: h264.PayloadTypeAVC,
+		FmtpLine: "packetization-mode=1;sprop-parameter-sets=Z0JAHqaAoD2QAA==,aM48gAA=",
+	}}
+	audio := &streamer.Track{Codec: &streamer.Codec{
+		Name: streamer.CodecAAC, ClockRate: 44100, Channels: 2,
+		FmtpLine: "streamtype=5;mode=AAC-hbr;sizelength=13;indexlength=3;indexdeltalength=3;config=1210",
+	}}
+
+	cons.AddTrack(nil, video)
+	cons.AddTrack(nil, audio)
+
+	go func() {
+		for i := uint32(0); i < 3; i++ {
+			payload := []byte{0x41, 3, 4}
+			if i == 0 {
+				payload = []byte{0x65, 1, 2}
+			}
+			_ = video.WriteRTP(&rtp.Packet{Header: rtp.Header{Timestamp: 1000 + i*3600}, Payload: h264.EncodeAVC(payload)})
+			_ = audio.WriteRTP(&rtp.Packet{Header: rtp.Header{Timestamp: 500 + i*1024}, Payload: []byte{0, 0x10, 0, 0x10, 0xBB, 0xCC}})
+		}
+		_ = w.Close()
+	}()
+
+	prod, err := OpenFLV("http://localhost/flv", "HTTP-FLV", r)
+	assert.Nil(t, err)
+
+	medias := prod.GetMedias()
+	assert.Len(t, medias, 2)
+	assert.Equal(t, streamer.CodecH264, medias[0].Codecs[0].Name)
+	assert.Equal(t, streamer.CodecAAC, medias[1].Codecs[0].Name)
+	assert.Equal(t, uint32(44100), medias[1].Codecs[0].ClockRate)
+
+	var packets []*rtp.Packet
+	track := prod.GetTrack(medias[0], medias[0].Codecs[0])
+	track.Bind(func(packet *rtp.Packet) error {
+		packets = append(packets, packet)
+		return nil
+	})
+
+	assert.Equal(t, io.EOF, prod.Handle())
+
+	assert.Len(t, packets, 3)
+	assert.Equal(t, uint32(0), packets[0].Timestamp)
+	assert.Equal(t, uint32(3600), packets[1].Timestamp)
+	assert.Equal(t, []byte{0x65, 1, 2}, packets[0].Payload[4:])
+}
@@ -0,0 +1,31 @@
+package rtmp
+
+import (
+	"github.com/deepch/vdk/format/rtmp"
+	"strings"
+)
+
+// Accept - producer from the connection that publish stream to the RTMP server
+func Accept(conn *rtmp.Conn) (*Client, error) {
+	// link without query, because stream key can be there
+	u := *conn.URL
+	u.RawQuery = ""
+
+	c := &Client{
+		URI: u.String(), proto: "RTMP", server: true,
+		conn: conn, remoteAddr: conn.NetConn().RemoteAddr().String(),
+	}
+	if err := c.describe(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// StreamName from publish or play link: rtmp://host/app/{stream_name}
+func StreamName(conn *rtmp.Conn) string {
+	ss := strings.SplitN(conn.URL.Path, "/", 3)
+	if len(ss) < 3 {
+		return ""
+	}
+	return ss[2]
+}
@@ -21,6 +21,9 @@ func (c *Client) GetTrack(media *streamer.Media, codec *streamer.Codec) *streame
 }
 
 func (c *Client) Start() error {
+	if c.server {
+		return nil // server connection handled by the RTMP server
+	}
 	return c.Handle()
 }
 
@@ -30,10 +33,15 @@ func (c *Client) Stop() error {
 
 func (c *Client) MarshalJSON() ([]byte, error) {
 	v := map[string]interface{}{
-		streamer.JSONReceive:    c.receive,
-		streamer.JSONType:       "RTMP client producer",
-		streamer.JSONRemoteAddr: c.conn.NetConn().RemoteAddr().String(),
-		"url":                   c.URI,
+		streamer.JSONReceive: c.receive,
+		streamer.JSONType:    c.proto + " client producer",
+		"url":                c.URI,
+	}
+	if c.remoteAddr != "" {
+		v[streamer.JSONRemoteAddr] = c.remoteAddr
+	}
+	if c.server {
+		v[streamer.JSONType] = c.proto + " server producer"
 	}
 	for i, media := range c.medias {
 		k := "media:" + strconv.Itoa(i)
@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/AlexxIT/go2rtc/pkg/h264"
+	"github.com/AlexxIT/go2rtc/pkg/onvif"
 	"github.com/AlexxIT/go2rtc/pkg/streamer"
 	"github.com/AlexxIT/go2rtc/pkg/tcp"
 	"github.com/pion/rtcp"
@@ -18,6 +19,7 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -31,6 +33,8 @@ const (
 	MethodPause    = "PAUSE"
 	MethodAnnounce = "ANNOUNCE"
 	MethodRecord   = "RECORD"
+
+	MethodGetParameter = "GET_PARAMETER"
 )
 
 type Mode byte
@@ -41,10 +45,15 @@ const (
 	ModeServerUnknown
 	ModeServerProducer
 	ModeServerConsumer
+	ModeClientConsumer
 )
 
+// KeepAlive - default keep-alive interval if server doesn't tell session timeout
 const KeepAlive = time.Second * 25
 
+// MaxRedirects - how many DESCRIBE redirects client will follow
+const MaxRedirects = 5
+
 type Conn struct {
 	streamer.Element
 
@@ -52,6 +61,11 @@ type Conn struct {
 
 	Backchannel bool
 
+	// playback of recordings
+	Range string  // Range header for PLAY, ex. "npt=0-" or "clock=20230101T120000Z-"
+	Scale float64 // Scale header for PLAY, 0 - not set
+	Speed float64 // Speed header for PLAY, 0 - not set
+
 	Medias    []*streamer.Media
 	Session   string
 	UserAgent string
@@ -66,8 +80,19 @@ type Conn struct {
 	sequence int
 	uri      string
 
-	tracks   []*streamer.Track
-	channels map[byte]*streamer.Track
+	public    string // methods from OPTIONS response
+	timeout   int    // session timeout in seconds
+	redirects int
+	recording bool // RECORD accepted, so media can be sent to the server
+
+	mx sync.Mutex // conn write lock
+
+	tracks    []*streamer.Track
+	channels  map[byte]*streamer.Track
+	senders   []*sender
+	receivers map[byte]*receiver // key - RTP channel
+	metadata  map[byte]*onvif.Metadata
+	ssrc      uint32 // for RTCP Receiver Reports
 
 	// stats
 
@@ -82,6 +107,13 @@ func NewClient(uri string) (*Conn, error) {
 	return c, c.parseURI()
 }
 
+// NewPublisher - client for sending stream to the remote server via ANNOUNCE and RECORD
+func NewPublisher(uri string) (*Conn, error) {
+	c, err := NewClient(uri)
+	c.mode = ModeClientConsumer
+	return c, err
+}
+
 func NewServer(conn net.Conn) *Conn {
 	c := new(Conn)
 	c.conn = conn
@@ -97,7 +129,11 @@ func (c *Conn) parseURI() (err error) {
 	}
 
 	if strings.IndexByte(c.URL.Host, ':') < 0 {
-		c.URL.Host += ":554"
+		if c.URL.Scheme == "rtsp+http" {
+			c.URL.Host += ":80"
+		} else {
+			c.URL.Host += ":554"
+		}
 	}
 
 	// remove UserInfo from URL
@@ -115,9 +151,15 @@ func (c *Conn) Dial() (err error) {
 		_ = c.parseURI()
 	}
 
-	c.conn, err = net.DialTimeout(
-		"tcp", c.URL.Host, 10*time.Second,
-	)
+	if c.URL.Scheme == "rtsp+http" {
+		// RTSP requests inside tunnel use usual scheme
+		c.URL.Scheme = "rtsp"
+		c.conn, err = dialTunnel(c.URL)
+	} else {
+		c.conn, err = net.DialTimeout(
+			"tcp", c.URL.Host, 10*time.Second,
+		)
+	}
 	if err != nil {
 		return
 	}
@@ -145,6 +187,14 @@ func (c *Conn) Dial() (err error) {
 
 // Request sends only Request
 func (c *Conn) Request(req *tcp.Request) error {
+	// keep-alive can be sent from another goroutine
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if c.conn == nil {
+		return errors.New("connection closed")
+	}
+
 	if req.Proto == "" {
 		req.Proto = ProtoRTSP
 	}
@@ -238,6 +288,9 @@ func (c *Conn) Response(res *tcp.Response) error {
 
 	c.Fire(res)
 
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
 	return res.Write(c.conn)
 }
 
@@ -256,9 +309,22 @@ func (c *Conn) Options() error {
 		}
 	}
 
+	// Public: OPTIONS, DESCRIBE, SETUP, TEARDOWN, PLAY, PAUSE, GET_PARAMETER
+	c.public = res.Header.Get("Public")
+
 	return nil
 }
 
+// Supports checks method in the Public header from OPTIONS response
+func (c *Conn) Supports(method string) bool {
+	for _, s := range strings.Split(c.public, ",") {
+		if strings.TrimSpace(s) == method {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *Conn) Describe() error {
 	// 5.3 Back channel connection
 	// https://www.onvif.org/specs/stream/ONVIF-Streaming-Spec.pdf
@@ -276,6 +342,12 @@ func (c *Conn) Describe() error {
 
 	res, err := c.Do(req)
 	if err != nil {
+		if res != nil {
+			switch res.StatusCode {
+			case http.StatusMovedPermanently, http.StatusFound:
+				return c.redirect(res.Header.Get("Location"))
+			}
+		}
 		return err
 	}
 
@@ -296,24 +368,78 @@ func (c *Conn) Describe() error {
 	return nil
 }
 
-//func (c *Conn) Announce() (err error) {
-//	req := &tcp.Request{
-//		Method: MethodAnnounce,
-//		URL:    c.URL,
-//		Header: map[string][]string{
-//			"Content-Type": {"application/sdp"},
-//		},
-//	}
-//
-//	//req.Body, err = c.sdp.Marshal()
-//	if err != nil {
-//		return
-//	}
-//
-//	_, err = c.Do(req)
-//
-//	return
-//}
+// redirect reconnects to the new location and repeats DESCRIBE,
+// location can point to another host
+func (c *Conn) redirect(location string) error {
+	if location == "" {
+		return errors.New("redirect without location")
+	}
+
+	if c.redirects >= MaxRedirects {
+		return errors.New("too many redirects")
+	}
+	c.redirects++
+
+	// location can be relative
+	u, err := c.URL.Parse(location)
+	if err != nil {
+		return err
+	}
+
+	// keep user/pass from the original link, but don't send them to another server
+	if u.User == nil && u.Hostname() == c.URL.Hostname() && (u.Port() == "" || u.Port() == c.URL.Port()) {
+		if orig, _ := url.Parse(c.uri); orig != nil {
+			u.User = orig.User
+		}
+	}
+
+	_ = c.conn.Close()
+
+	c.uri = u.String()
+	c.Session = ""
+
+	if err = c.Dial(); err != nil {
+		return err
+	}
+
+	// best-effort, same as for the first connection
+	if c.public != "" {
+		_ = c.Options()
+	}
+
+	return c.Describe()
+}
+
+func (c *Conn) Announce() (err error) {
+	req := &tcp.Request{
+		Method: MethodAnnounce,
+		URL:    c.URL,
+		Header: map[string][]string{
+			"Content-Type": {"application/sdp"},
+		},
+	}
+
+	// convert binded tracks to output medias
+	var medias []*streamer.Media
+	for i, track := range c.tracks {
+		media := &streamer.Media{
+			Kind:      streamer.GetKind(track.Codec.Name),
+			Direction: streamer.DirectionSendonly,
+			Codecs:    []*streamer.Codec{track.Codec},
+			Control:   "trackID=" + strconv.Itoa(i),
+		}
+		medias = append(medias, media)
+	}
+
+	req.Body, err = streamer.MarshalSDP(medias)
+	if err != nil {
+		return
+	}
+
+	_, err = c.Do(req)
+
+	return
+}
 
 func (c *Conn) Setup() error {
 	for _, media := range c.Medias {
@@ -379,15 +505,7 @@ func (c *Conn) SetupMedia(
 		}
 	}
 
-	if c.Session == "" {
-		// Session: 216525287999;timeout=60
-		if s := res.Header.Get("Session"); s != "" {
-			if j := strings.IndexByte(s, ';'); j > 0 {
-				s = s[:j]
-			}
-			c.Session = s
-		}
-	}
+	c.setSession(res)
 
 	// we send our `interleaved`, but camera can answer with another
 
@@ -426,6 +544,8 @@ func (c *Conn) SetupMedia(
 			c.channels = make(map[byte]*streamer.Track)
 		}
 		c.channels[byte(ch)] = track
+		c.addReceiver(byte(ch), codec)
+		c.addMetadata(byte(ch), codec)
 
 	case streamer.DirectionRecvonly:
 		track = c.bindTrack(track, byte(ch), codec.PayloadType)
@@ -436,8 +556,112 @@ func (c *Conn) SetupMedia(
 	return track, nil
 }
 
+// SetupRecord sends SETUP for each binded track in record mode
+func (c *Conn) SetupRecord() error {
+	rawURL := c.URL.String()
+	if !strings.HasSuffix(rawURL, "/") {
+		rawURL += "/"
+	}
+
+	for i := range c.tracks {
+		trackURL, err := url.Parse(rawURL + "trackID=" + strconv.Itoa(i))
+		if err != nil {
+			return err
+		}
+
+		req := &tcp.Request{
+			Method: MethodSetup,
+			URL:    trackURL,
+			Header: map[string][]string{
+				"Transport": {fmt.Sprintf(
+					"RTP/AVP/TCP;unicast;interleaved=%d-%d;mode=record", i*2, i*2+1,
+				)},
+			},
+		}
+
+		res, err := c.Do(req)
+		if err != nil {
+			return err
+		}
+
+		c.setSession(res)
+	}
+
+	return nil
+}
+
+func (c *Conn) setSession(res *tcp.Response) {
+	if c.Session != "" {
+		return
+	}
+
+	// Session: 216525287999;timeout=60
+	if s := res.Header.Get("Session"); s != "" {
+		if j := strings.IndexByte(s, ';'); j > 0 {
+			c.timeout, _ = strconv.Atoi(streamer.Between(s[j:], "timeout=", ";"))
+			s = s[:j]
+		}
+		c.Session = s
+	}
+}
+
+func (c *Conn) Record() error {
+	req := &tcp.Request{Method: MethodRecord, URL: c.URL}
+	if _, err := c.Do(req); err != nil {
+		return err
+	}
+
+	c.mx.Lock()
+	c.recording = true
+	c.mx.Unlock()
+
+	return nil
+}
+
 func (c *Conn) Play() (err error) {
-	req := &tcp.Request{Method: MethodPlay, URL: c.URL}
+	return c.play(c.Range)
+}
+
+// Pause playback, response will be processed by Handle
+func (c *Conn) Pause() error {
+	req := &tcp.Request{Method: MethodPause, URL: c.URL}
+	return c.Request(req)
+}
+
+// Resume playback after Pause from the same position
+func (c *Conn) Resume() error {
+	return c.play("")
+}
+
+// Seek restarts playback from new position, many NVRs want PAUSE before it
+func (c *Conn) Seek(position string) error {
+	if err := c.Pause(); err != nil {
+		return err
+	}
+	return c.play(position)
+}
+
+// SetScale changes playback speed, ex. 2 - fast forward, -1 - reverse
+func (c *Conn) SetScale(scale float64) error {
+	c.Scale = scale
+	return c.play("")
+}
+
+func (c *Conn) play(position string) error {
+	req := &tcp.Request{
+		Method: MethodPlay, URL: c.URL, Header: map[string][]string{},
+	}
+
+	if position != "" {
+		req.Header.Set("Range", position)
+	}
+	if c.Scale != 0 {
+		req.Header.Set("Scale", strconv.FormatFloat(c.Scale, 'f', -1, 64))
+	}
+	if c.Speed != 0 {
+		req.Header.Set("Speed", strconv.FormatFloat(c.Speed, 'f', -1, 64))
+	}
+
 	return c.Request(req)
 }
 
@@ -451,14 +675,22 @@ func (c *Conn) Teardown() (err error) {
 }
 
 func (c *Conn) Close() error {
-	if c.conn == nil {
+	c.mx.Lock()
+	conn := c.conn
+	c.mx.Unlock()
+
+	if conn == nil {
 		return nil
 	}
 	if err := c.Teardown(); err != nil {
 		return err
 	}
-	conn := c.conn
+
+	// keep-alive and writers check conn under the same lock
+	c.mx.Lock()
 	c.conn = nil
+	c.mx.Unlock()
+
 	return conn.Close()
 }
 
@@ -514,6 +746,8 @@ func (c *Conn) Accept() error {
 				}
 				c.tracks = append(c.tracks, track)
 				c.channels[byte(i<<1)] = track
+				c.addReceiver(byte(i<<1), track.Codec)
+				c.addMetadata(byte(i<<1), track.Codec)
 			}
 
 			c.mode = ModeServerProducer
@@ -594,14 +828,27 @@ func (c *Conn) Accept() error {
 
 func (c *Conn) Handle() (err error) {
 	defer func() {
+		c.mx.Lock()
 		if c.conn == nil {
 			err = nil
 		}
+		c.mx.Unlock()
 		//c.Fire(streamer.StateNull)
 	}()
 
 	//c.Fire(streamer.StatePlaying)
-	ts := time.Now().Add(KeepAlive)
+
+	done := make(chan struct{})
+	defer close(done)
+
+	// keep-alive doesn't depend on incoming packets
+	if c.mode == ModeClientProducer || c.mode == ModeClientConsumer {
+		go c.keepAlive(done)
+	}
+
+	if c.senders != nil || c.receivers != nil {
+		go c.rtcpWorker(done)
+	}
 
 	for {
 		// we can read:
@@ -659,6 +906,17 @@ func (c *Conn) Handle() (err error) {
 				return
 			}
 
+			if r := c.receivers[channelID]; r != nil {
+				r.add(packet, time.Now())
+			}
+
+			// fire events from ONVIF metadata stream
+			if m := c.metadata[channelID]; m != nil {
+				for _, event := range m.Push(packet) {
+					c.Fire(event)
+				}
+			}
+
 			track := c.channels[channelID]
 			if track != nil {
 				_ = track.WriteRTP(packet)
@@ -679,18 +937,42 @@ func (c *Conn) Handle() (err error) {
 				return
 			}
 
+			c.handleRTCP(msg)
+
 			c.Fire(msg)
 		}
+	}
+}
 
-		// keep-alive
-		now := time.Now()
-		if now.After(ts) {
-			req := &tcp.Request{Method: MethodOptions, URL: c.URL}
-			// don't need to wait respose on this request
-			if err = c.Request(req); err != nil {
-				return err
+// KeepAliveInterval returns half of the session timeout from SETUP response
+// or default interval if timeout is unknown
+func (c *Conn) KeepAliveInterval() time.Duration {
+	if c.timeout > 0 {
+		return time.Duration(c.timeout) * time.Second / 2
+	}
+	return KeepAlive
+}
+
+func (c *Conn) keepAlive(done <-chan struct{}) {
+	ticker := time.NewTicker(c.KeepAliveInterval())
+	defer ticker.Stop()
+
+	// GET_PARAMETER is preferred keep-alive method if server supports it
+	method := MethodOptions
+	if c.Supports(MethodGetParameter) {
+		method = MethodGetParameter
+	}
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			req := &tcp.Request{Method: method, URL: c.URL}
+			// don't need to wait response on this request
+			if err := c.Request(req); err != nil {
+				return
 			}
-			ts = now.Add(KeepAlive)
 		}
 	}
 }
@@ -707,10 +989,12 @@ func (c *Conn) GetChannel(media *streamer.Media) int {
 func (c *Conn) bindTrack(
 	track *streamer.Track, channel uint8, payloadType uint8,
 ) *streamer.Track {
+	// i   - RTP (data channel)
+	// i+1 - RTCP (control channel)
+	s := &sender{channel: channel + 1, clockRate: track.Codec.ClockRate, track: track}
+	c.senders = append(c.senders, s)
+
 	push := func(packet *rtp.Packet) error {
-		if c.conn == nil {
-			return nil
-		}
 		packet.Header.PayloadType = payloadType
 		//packet.Header.PayloadType = 100
 		//packet.Header.PayloadType = 8
@@ -728,11 +1012,11 @@ func (c *Conn) bindTrack(
 			return nil
 		}
 
-		if _, err := c.conn.Write(data); err != nil {
+		if err := c.write(data); err != nil {
 			return err
 		}
 
-		c.send += size
+		s.add(packet)
 
 		return nil
 	}
@@ -745,10 +1029,53 @@ func (c *Conn) bindTrack(
 	return track.Bind(push)
 }
 
-type RTCP struct {
-	Channel byte
-	Header  rtcp.Header
-	Packets []rtcp.Packet
+func (c *Conn) addReceiver(channel byte, codec *streamer.Codec) {
+	if c.receivers == nil {
+		c.receivers = map[byte]*receiver{}
+	}
+	c.receivers[channel] = &receiver{channel: channel + 1, clockRate: codec.ClockRate}
+}
+
+func (c *Conn) addMetadata(channel byte, codec *streamer.Codec) {
+	if codec.Name != streamer.CodecONVIFMetadata {
+		return
+	}
+	if c.metadata == nil {
+		c.metadata = map[byte]*onvif.Metadata{}
+	}
+	c.metadata[channel] = &onvif.Metadata{}
+}
+
+func (c *Conn) writeInterleaved(channel byte, payload []byte) error {
+	data := make([]byte, 4+len(payload))
+	data[0] = '$'
+	data[1] = channel
+	binary.BigEndian.PutUint16(data[2:], uint16(len(payload)))
+	copy(data[4:], payload)
+
+	return c.write(data)
+}
+
+func (c *Conn) write(data []byte) error {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if c.conn == nil {
+		return nil
+	}
+
+	// publisher can't send media before RECORD response
+	if c.mode == ModeClientConsumer && !c.recording {
+		return nil
+	}
+
+	if _, err := c.conn.Write(data); err != nil {
+		return err
+	}
+
+	c.send += len(data) - 4
+
+	return nil
 }
 
 const sdpHeader = `v=0
@@ -0,0 +1,288 @@
+package rtsp
+
+import (
+	"bufio"
+	"encoding/binary"
+	"github.com/AlexxIT/go2rtc/pkg/streamer"
+	"github.com/AlexxIT/go2rtc/pkg/tcp"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const testSDP = "v=0\r\no=- 0 0 IN IP4 127.0.0.1\r\ns=-\r\nt=0 0\r\n" +
+	"m=video 0 RTP/AVP 96\r\na=rtpmap:96 H264/90000\r\na=control:trackID=0\r\n"
+
+// testServer - stand-in RTSP server, handler returns response for each request
+// or nil for no response, returns server address
+func testServer(t *testing.T, handler func(req *tcp.Request) *tcp.Response) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	t.Cleanup(func() { _ = ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+
+				rd := bufio.NewReader(conn)
+				for {
+					// skip interleaved frames from the publisher
+					if b, err := rd.Peek(4); err == nil && b[0] == '$' {
+						size := 4 + int(binary.BigEndian.Uint16(b[2:]))
+						if _, err = rd.Discard(size); err != nil {
+							return
+						}
+						continue
+					}
+
+					req, err := tcp.ReadRequest(rd)
+					if err != nil {
+						return
+					}
+
+					res := handler(req)
+					if res == nil {
+						continue
+					}
+
+					res.Proto = ProtoRTSP
+					if res.Status == "" {
+						res.Status = "200 OK"
+					}
+					if res.Header == nil {
+						res.Header = map[string][]string{}
+					}
+					res.Header.Set("CSeq", req.Header.Get("CSeq"))
+					if res.Body != nil {
+						res.Header.Set("Content-Length", strconv.Itoa(len(res.Body)))
+					}
+
+					if err = res.Write(conn); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+
+	return ln.Addr().String()
+}
+
+func TestRedirect(t *testing.T) {
+	var describes int
+
+	// another server asks user/pass, they must not come from the original link
+	var auths []string
+	other := testServer(t, func(req *tcp.Request) *tcp.Response {
+		auths = append(auths, req.Header.Get("Authorization"))
+		return &tcp.Response{
+			Status: "401 Unauthorized", Header: map[string][]string{"WWW-Authenticate": {`Basic realm="test"`}},
+		}
+	})
+
+	var address string
+	address = testServer(t, func(req *tcp.Request) *tcp.Response {
+		switch req.URL.Path {
+		case "/other":
+			return &tcp.Response{Status: "302 Found", Header: map[string][]string{"Location": {"rtsp://" + other + "/"}}}
+		case "/loop":
+			describes++
+			return &tcp.Response{
+				Status: "302 Found", Header: map[string][]string{"Location": {"rtsp://" + address + "/loop"}},
+			}
+		case "/old":
+			return &tcp.Response{Status: "301 Moved Permanently", Header: map[string][]string{"Location": {"/new"}}}
+		}
+		return &tcp.Response{Body: []byte(testSDP)}
+	})
+
+	conn, err := NewClient("rtsp://admin:secret@" + address + "/old")
+	assert.Nil(t, err)
+	assert.Nil(t, conn.Dial())
+	assert.Nil(t, conn.Describe())
+	assert.Equal(t, "/new", conn.URL.Path)
+	assert.Equal(t, "rtsp://admin:secret@"+address+"/new", conn.uri)
+	assert.Len(t, conn.Medias, 1)
+	_ = conn.Close()
+
+	conn, err = NewClient("rtsp://admin:secret@" + address + "/other")
+	assert.Nil(t, err)
+	assert.Nil(t, conn.Dial())
+	assert.NotNil(t, conn.Describe())
+	assert.Equal(t, []string{""}, auths)
+	_ = conn.Close()
+
+	conn, err = NewClient("rtsp://" + address + "/loop")
+	assert.Nil(t, err)
+	assert.Nil(t, conn.Dial())
+	assert.NotNil(t, conn.Describe())
+	assert.Equal(t, MaxRedirects+1, describes)
+	_ = conn.Close()
+}
+
+func TestKeepAlive(t *testing.T) {
+	for public, method := range map[string]string{
+		"OPTIONS, DESCRIBE, SETUP, PLAY, GET_PARAMETER": MethodGetParameter,
+		"OPTIONS, DESCRIBE, SETUP, PLAY":                MethodOptions,
+		"":                                              MethodOptions, // OPTIONS with error
+	} {
+		public, method := public, method
+		methods := make(chan string, 10)
+
+		address := testServer(t, func(req *tcp.Request) *tcp.Response {
+			methods <- req.Method
+			if public == "" {
+				return &tcp.Response{Status: "501 Not Implemented"}
+			}
+			return &tcp.Response{Header: map[string][]string{"Public": {public}}}
+		})
+
+		conn, err := NewClient("rtsp://" + address + "/stream")
+		assert.Nil(t, err)
+		assert.Nil(t, conn.Dial())
+
+		// some cameras don't support OPTIONS, the client should work without it
+		err = conn.Options()
+		assert.Equal(t, public == "", err != nil)
+		assert.Equal(t, MethodOptions, <-methods)
+
+		conn.timeout = 1 // keep-alive every 500 ms
+
+		done := make(chan struct{})
+		go conn.keepAlive(done)
+
+		select {
+		case m := <-methods:
+			assert.Equal(t, method, m)
+		case <-time.After(time.Second):
+			t.Fail()
+		}
+
+		close(done)
+		_ = conn.Close()
+	}
+}
+
+func TestPublisher(t *testing.T) {
+	var methods, transports []string
+
+	address := testServer(t, func(req *tcp.Request) *tcp.Response {
+		methods = append(methods, req.Method)
+		switch req.Method {
+		case MethodAnnounce:
+			assert.Equal(t, "application/sdp", req.Header.Get("Content-Type"))
+			assert.Contains(t, string(req.Body), "a=control:trackID=0")
+		case MethodSetup:
+			assert.Equal(t, "/stream/trackID=0", req.URL.Path)
+			transports = append(transports, req.Header.Get("Transport"))
+			return &tcp.Response{Header: map[string][]string{"Session": {"12345678;timeout=60"}}}
+		}
+		return &tcp.Response{}
+	})
+
+	conn, err := NewPublisher("rtsp://" + address + "/stream")
+	assert.Nil(t, err)
+	assert.Nil(t, conn.Dial())
+
+	track := &streamer.Track{
+		Codec:     &streamer.Codec{Name: streamer.CodecPCMA, ClockRate: 8000},
+		Direction: streamer.DirectionSendonly,
+	}
+	media := &streamer.Media{Kind: streamer.KindAudio, Direction: streamer.DirectionSendonly}
+	conn.AddTrack(media, track)
+
+	packet := &rtp.Packet{Header: rtp.Header{Version: 2}, Payload: make([]byte, 160)}
+
+	assert.Nil(t, conn.Announce())
+	assert.Nil(t, conn.SetupRecord())
+	assert.Equal(t, "12345678", conn.Session)
+
+	// media before RECORD response is dropped
+	assert.Nil(t, track.WriteRTP(packet))
+	assert.Equal(t, 0, conn.send)
+
+	assert.Nil(t, conn.Record())
+	assert.Nil(t, track.WriteRTP(packet))
+	assert.Equal(t, 12+160, conn.send)
+
+	assert.Equal(t, []string{MethodAnnounce, MethodSetup, MethodRecord}, methods)
+	assert.Equal(t, []string{"RTP/AVP/TCP;unicast;interleaved=0-1;mode=record"}, transports)
+
+	_ = conn.Close()
+}
+
+func TestPlayback(t *testing.T) {
+	// method and playback headers of each request
+	requests := make(chan string, 10)
+
+	address := testServer(t, func(req *tcp.Request) *tcp.Response {
+		requests <- req.Method + " range=" + req.Header.Get("Range") +
+			" scale=" + req.Header.Get("Scale") + " speed=" + req.Header.Get("Speed")
+		return &tcp.Response{}
+	})
+
+	conn, err := NewClient("rtsp://" + address + "/archive")
+	assert.Nil(t, err)
+	assert.Nil(t, conn.Dial())
+
+	conn.Range = "npt=10-"
+	conn.Speed = 2
+
+	assert.Nil(t, conn.Play())
+	assert.Equal(t, "PLAY range=npt=10- scale= speed=2", <-requests)
+
+	assert.Nil(t, conn.Pause())
+	assert.Equal(t, "PAUSE range= scale= speed=", <-requests)
+
+	// resume from the same position
+	assert.Nil(t, conn.Resume())
+	assert.Equal(t, "PLAY range= scale= speed=2", <-requests)
+
+	assert.Nil(t, conn.Seek("clock=20230101T120000Z-"))
+	assert.Equal(t, "PAUSE range= scale= speed=", <-requests)
+	assert.Equal(t, "PLAY range=clock=20230101T120000Z- scale= speed=2", <-requests)
+
+	assert.Nil(t, conn.SetScale(-0.5))
+	assert.Equal(t, "PLAY range= scale=-0.5 speed=2", <-requests)
+
+	_ = conn.Close()
+}
+
+func TestCloseKeepAlive(t *testing.T) {
+	address := testServer(t, func(req *tcp.Request) *tcp.Response {
+		return &tcp.Response{}
+	})
+
+	conn, err := NewClient("rtsp://" + address + "/stream")
+	assert.Nil(t, err)
+	assert.Nil(t, conn.Dial())
+
+	conn.timeout = 1 // keep-alive every 500 ms
+
+	stopped := make(chan struct{})
+	go func() {
+		conn.keepAlive(make(chan struct{}))
+		close(stopped)
+	}()
+
+	assert.Nil(t, conn.Close())
+
+	// keep-alive stops on the first tick after close
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fail()
+	}
+
+	req := &tcp.Request{Method: MethodOptions, URL: conn.URL}
+	assert.NotNil(t, conn.Request(req))
+}
@@ -0,0 +1,362 @@
+package rtsp
+
+import (
+	"fmt"
+	"github.com/AlexxIT/go2rtc/pkg/streamer"
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ReportInterval - how often RTCP reports are sent (RFC 3550 recommends 5 seconds minimum)
+const ReportInterval = time.Second * 5
+
+const cname = "go2rtc"
+
+type RTCP struct {
+	Channel byte
+	Header  rtcp.Header
+	Packets []rtcp.Packet
+}
+
+// sender collects stats of outgoing RTP packets for RTCP Sender Reports
+// and stats from incoming Receiver Reports
+type sender struct {
+	channel   byte // RTCP channel
+	clockRate uint32
+
+	track *streamer.Track // source track with optional wall clock
+
+	ssrc     uint32
+	packets  uint32
+	octets   uint32
+	rtpTime  uint32    // timestamp of last sent packet
+	wallTime time.Time // wall clock time of last sent packet
+
+	lastSR     uint32    // middle 32 bits of NTP time from last SR
+	lastSRTime time.Time // local time when last SR was sent
+
+	// from Receiver Reports
+	fractionLost uint8
+	totalLost    uint32
+	jitter       uint32
+	rtt          time.Duration
+
+	mx sync.Mutex
+}
+
+func (s *sender) add(packet *rtp.Packet) {
+	s.mx.Lock()
+	s.ssrc = packet.SSRC
+	s.packets++
+	s.octets += uint32(len(packet.Payload))
+	s.rtpTime = packet.Timestamp
+	s.wallTime = time.Now()
+	s.mx.Unlock()
+}
+
+// report returns Sender Report or nil if nothing was sent yet
+func (s *sender) report(now time.Time) *rtcp.SenderReport {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if s.packets == 0 {
+		return nil
+	}
+
+	elapsed := now.Sub(s.wallTime)
+
+	// RTP time of the same instant as NTP time
+	rtpTime := s.rtpTime + uint32(elapsed.Seconds()*float64(s.clockRate))
+
+	// use source capture time if it's known, so tracks from different
+	// sources can be synchronized by the player
+	wallTime := now
+	if t := s.track.WallClock(s.rtpTime); !t.IsZero() {
+		wallTime = t.Add(elapsed)
+	}
+
+	ntpTime := streamer.ToNTP(wallTime)
+	s.lastSR = uint32(ntpTime >> 16)
+	s.lastSRTime = now
+
+	return &rtcp.SenderReport{
+		SSRC:        s.ssrc,
+		NTPTime:     ntpTime,
+		RTPTime:     rtpTime,
+		PacketCount: s.packets,
+		OctetCount:  s.octets,
+	}
+}
+
+func (s *sender) handleReport(report rtcp.ReceptionReport, now time.Time) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	s.fractionLost = report.FractionLost
+	s.totalLost = report.TotalLost
+	s.jitter = report.Jitter
+
+	// RFC 3550. 6.4.1 round-trip propagation delay, DLSR in 1/65536 seconds
+	if report.LastSenderReport != 0 && report.LastSenderReport == s.lastSR {
+		delay := time.Duration(report.Delay) * time.Second / 65536
+		s.rtt = now.Sub(s.lastSRTime) - delay
+	}
+}
+
+func (s *sender) String() string {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	return fmt.Sprintf(
+		"packets=%d, octets=%d, lost=%d, jitter=%d, rtt=%s",
+		s.packets, s.octets, s.totalLost, s.jitter, s.rtt,
+	)
+}
+
+// receiver collects stats of incoming RTP packets for RTCP Receiver Reports
+// RFC 3550. Appendix A.1, A.3, A.8
+type receiver struct {
+	channel   byte // RTCP channel
+	clockRate uint32
+
+	ssrc     uint32
+	baseSeq  uint16
+	maxSeq   uint16
+	cycles   uint32 // shifted count of seq number cycles
+	received uint32
+
+	expectedPrior uint32
+	receivedPrior uint32
+
+	start   time.Time // base for arrival time in RTP units
+	transit int32
+	jitter  float64
+
+	lastSR     uint32    // middle 32 bits of NTP time from last SR
+	lastSRTime time.Time // local time when last SR was received
+
+	mx sync.Mutex
+}
+
+const (
+	maxDropout  = 3000
+	maxMisorder = 100
+	seqMod      = 1 << 16
+)
+
+func (r *receiver) add(packet *rtp.Packet, now time.Time) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	seq := packet.SequenceNumber
+
+	if r.received == 0 {
+		r.ssrc = packet.SSRC
+		r.baseSeq = seq
+		r.maxSeq = seq
+		r.start = now
+	} else if delta := seq - r.maxSeq; delta < maxDropout {
+		// in order, with permissible gap
+		if seq < r.maxSeq {
+			r.cycles += seqMod
+		}
+		r.maxSeq = seq
+	} else if delta <= seqMod-maxMisorder {
+		// very large jump, source was restarted
+		r.ssrc = packet.SSRC
+		r.baseSeq = seq
+		r.maxSeq = seq
+		r.cycles = 0
+		r.received = 0
+		r.expectedPrior = 0
+		r.receivedPrior = 0
+	}
+	// else duplicate or reordered packet
+
+	r.received++
+
+	// interarrival jitter in RTP timestamp units
+	arrival := uint32(now.Sub(r.start).Seconds() * float64(r.clockRate))
+	transit := int32(arrival - packet.Timestamp)
+	if r.received > 1 {
+		d := transit - r.transit
+		if d < 0 {
+			d = -d
+		}
+		r.jitter += (float64(d) - r.jitter) / 16
+	}
+	r.transit = transit
+}
+
+func (r *receiver) handleSR(sr *rtcp.SenderReport, now time.Time) {
+	r.mx.Lock()
+	r.lastSR = uint32(sr.NTPTime >> 16)
+	r.lastSRTime = now
+	r.mx.Unlock()
+}
+
+// report returns Reception Report block or nil if nothing was received yet
+func (r *receiver) report(now time.Time) *rtcp.ReceptionReport {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	if r.received == 0 {
+		return nil
+	}
+
+	extMaxSeq := r.cycles + uint32(r.maxSeq)
+	expected := extMaxSeq - uint32(r.baseSeq) + 1
+
+	var lost uint32
+	if expected > r.received {
+		// 24-bit signed field, so we can't report more
+		if lost = expected - r.received; lost > 0x7FFFFF {
+			lost = 0x7FFFFF
+		}
+	}
+
+	expectedInterval := expected - r.expectedPrior
+	receivedInterval := r.received - r.receivedPrior
+	r.expectedPrior = expected
+	r.receivedPrior = r.received
+
+	var fraction uint8
+	if expectedInterval > receivedInterval {
+		lostInterval := expectedInterval - receivedInterval
+		fraction = uint8((lostInterval << 8) / expectedInterval)
+	}
+
+	var delay uint32
+	if !r.lastSRTime.IsZero() {
+		delay = uint32(now.Sub(r.lastSRTime).Seconds() * 65536)
+	}
+
+	return &rtcp.ReceptionReport{
+		SSRC:               r.ssrc,
+		FractionLost:       fraction,
+		TotalLost:          lost,
+		LastSequenceNumber: extMaxSeq,
+		Jitter:             uint32(r.jitter),
+		LastSenderReport:   r.lastSR,
+		Delay:              delay,
+	}
+}
+
+func (r *receiver) String() string {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	var lost uint32
+	if expected := r.cycles + uint32(r.maxSeq) - uint32(r.baseSeq) + 1; expected > r.received {
+		lost = expected - r.received
+	}
+
+	return fmt.Sprintf(
+		"packets=%d, lost=%d, jitter=%d", r.received, lost, uint32(r.jitter),
+	)
+}
+
+func (c *Conn) rtcpWorker(done <-chan struct{}) {
+	ticker := time.NewTicker(ReportInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			if err := c.sendReports(now); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (c *Conn) sendReports(now time.Time) error {
+	for _, s := range c.senders {
+		sr := s.report(now)
+		if sr == nil {
+			continue
+		}
+
+		if err := c.writeRTCP(s.channel, sr, sr.SSRC); err != nil {
+			return err
+		}
+	}
+
+	for _, r := range c.receivers {
+		report := r.report(now)
+		if report == nil {
+			continue
+		}
+
+		if c.ssrc == 0 {
+			c.ssrc = rand.Uint32()
+		}
+
+		rr := &rtcp.ReceiverReport{
+			SSRC:    c.ssrc,
+			Reports: []rtcp.ReceptionReport{*report},
+		}
+
+		if err := c.writeRTCP(r.channel, rr, c.ssrc); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Conn) writeRTCP(channel byte, report rtcp.Packet, ssrc uint32) error {
+	// compound packet should contain SDES with CNAME
+	sdes := &rtcp.SourceDescription{
+		Chunks: []rtcp.SourceDescriptionChunk{{
+			Source: ssrc,
+			Items: []rtcp.SourceDescriptionItem{
+				{Type: rtcp.SDESCNAME, Text: cname},
+			},
+		}},
+	}
+
+	data, err := rtcp.Marshal([]rtcp.Packet{report, sdes})
+	if err != nil {
+		return err
+	}
+
+	return c.writeInterleaved(channel, data)
+}
+
+func (c *Conn) handleRTCP(msg *RTCP) {
+	now := time.Now()
+
+	for _, packet := range msg.Packets {
+		var reports []rtcp.ReceptionReport
+
+		switch packet := packet.(type) {
+		case *rtcp.ReceiverReport:
+			reports = packet.Reports
+		case *rtcp.SenderReport:
+			// map source RTP time to absolute capture time
+			if track := c.channels[msg.Channel-1]; track != nil && packet.NTPTime != 0 {
+				track.SetClock(packet.RTPTime, streamer.FromNTP(packet.NTPTime))
+			}
+			if r := c.receivers[msg.Channel-1]; r != nil {
+				r.handleSR(packet, now)
+			}
+			reports = packet.Reports
+		default:
+			continue
+		}
+
+		for _, report := range reports {
+			for _, s := range c.senders {
+				if s.channel == msg.Channel {
+					s.handleReport(report, now)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package rtsp
+
+import (
+	"bufio"
+	"encoding/binary"
+	"github.com/AlexxIT/go2rtc/pkg/streamer"
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestReceiverReport(t *testing.T) {
+	r := &receiver{channel: 1, clockRate: 8000}
+	assert.Nil(t, r.report(time.Now()))
+
+	now := time.Now()
+
+	// 20 packets with sequence overflow and two lost packets
+	seq := uint16(65530)
+	for i := 0; i < 20; i, seq = i+1, seq+1 {
+		if i == 5 || i == 6 {
+			continue
+		}
+		packet := &rtp.Packet{
+			Header: rtp.Header{SSRC: 7, SequenceNumber: seq, Timestamp: uint32(i * 160)},
+		}
+		r.add(packet, now.Add(time.Duration(i)*20*time.Millisecond))
+	}
+
+	report := r.report(now)
+	assert.Equal(t, uint32(7), report.SSRC)
+	assert.Equal(t, uint32(2), report.TotalLost)
+	assert.Equal(t, uint8(2*256/20), report.FractionLost)
+	assert.Equal(t, uint32(1<<16+13), report.LastSequenceNumber)
+	assert.Equal(t, uint32(0), report.Jitter)
+
+	// no new losses since previous report
+	report = r.report(now)
+	assert.Equal(t, uint8(0), report.FractionLost)
+}
+
+func TestSenderReport(t *testing.T) {
+	local, remote := net.Pipe()
+	defer remote.Close()
+
+	c := NewServer(local)
+	c.mode = ModeServerConsumer
+
+	track := &streamer.Track{Codec: &streamer.Codec{Name: streamer.CodecPCMA, ClockRate: 8000}}
+	c.bindTrack(track, 2, 8)
+
+	// interleaved frames from the server: channel and payload
+	type frame struct {
+		channel byte
+		payload []byte
+	}
+	frames := make(chan frame, 10)
+	go func() {
+		rd := bufio.NewReader(remote)
+		for {
+			b := make([]byte, 4)
+			if _, err := io.ReadFull(rd, b); err != nil {
+				return
+			}
+			payload := make([]byte, binary.BigEndian.Uint16(b[2:]))
+			if _, err := io.ReadFull(rd, payload); err != nil {
+				return
+			}
+			frames <- frame{channel: b[1], payload: payload}
+		}
+	}()
+
+	for i := 0; i < 3; i++ {
+		packet := &rtp.Packet{
+			Header:  rtp.Header{Version: 2, SSRC: 7, SequenceNumber: uint16(i), Timestamp: uint32(i * 160)},
+			Payload: make([]byte, 160),
+		}
+		assert.Nil(t, track.WriteRTP(packet))
+		assert.Equal(t, byte(2), (<-frames).channel)
+	}
+
+	go func() {
+		_ = c.sendReports(time.Now())
+	}()
+
+	f := <-frames
+	assert.Equal(t, byte(3), f.channel) // RTCP channel
+
+	packets, err := rtcp.Unmarshal(f.payload)
+	assert.Nil(t, err)
+
+	sr := packets[0].(*rtcp.SenderReport)
+	assert.Equal(t, uint32(7), sr.SSRC)
+	assert.Equal(t, uint32(3), sr.PacketCount)
+	assert.Equal(t, uint32(3*160), sr.OctetCount)
+	assert.InDelta(t, 2*160, sr.RTPTime, 80) // plus time since the last packet
+
+	_ = local.Close()
+}
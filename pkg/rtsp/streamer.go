@@ -27,8 +27,20 @@ func (c *Conn) GetTrack(media *streamer.Media, codec *streamer.Codec) *streamer.
 }
 
 func (c *Conn) Start() error {
-	if c.mode == ModeServerProducer {
+	switch c.mode {
+	case ModeServerProducer:
 		return nil
+	case ModeClientConsumer:
+		if err := c.Announce(); err != nil {
+			return err
+		}
+		if err := c.SetupRecord(); err != nil {
+			return err
+		}
+		if err := c.Record(); err != nil {
+			return err
+		}
+		return c.Handle()
 	}
 
 	if err := c.Play(); err != nil {
@@ -90,6 +102,8 @@ func (c *Conn) MarshalJSON() ([]byte, error) {
 		v[streamer.JSONType] = "RTSP server producer"
 	case ModeServerConsumer:
 		v[streamer.JSONType] = "RTSP server consumer"
+	case ModeClientConsumer:
+		v[streamer.JSONType] = "RTSP client consumer"
 	}
 	//if c.URI != "" {
 	//	v["uri"] = c.URI
@@ -111,6 +125,14 @@ func (c *Conn) MarshalJSON() ([]byte, error) {
 		k := "track:" + strconv.Itoa(int(i>>1))
 		v[k] = track.String()
 	}
+	for _, s := range c.senders {
+		k := "rtcp:" + strconv.Itoa(int(s.channel))
+		v[k] = s.String()
+	}
+	for _, r := range c.receivers {
+		k := "rtcp:" + strconv.Itoa(int(r.channel))
+		v[k] = r.String()
+	}
 	//for i, track := range c.tracks {
 	//	k := "track:" + strconv.Itoa(i+1)
 	//	if track.MimeType() == streamer.MimeTypeH264 {
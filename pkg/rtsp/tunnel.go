@@ -0,0 +1,228 @@
+package rtsp
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// RTSP-over-HTTP tunnel (Apple QuickTime style)
+// - client opens GET connection and receives server data as is
+// - client opens POST connection and sends its data base64 encoded
+// - both connections linked with the same x-sessioncookie header
+// https://opensource.apple.com/source/QuickTimeStreamingServer/QuickTimeStreamingServer-412.42/Documentation/RTSP_Over_HTTP.pdf
+
+const TunnelContentType = "application/x-rtsp-tunnelled"
+
+// TunnelTimeout - how long server waits for the next POST connection from client
+const TunnelTimeout = time.Minute
+
+type tunnelConn struct {
+	net.Conn // GET connection
+
+	reader *bufio.Reader
+	post   net.Conn
+}
+
+func dialTunnel(u *url.URL) (net.Conn, error) {
+	cookie := fmt.Sprintf("%x", rand.Int63())
+
+	get, err := net.DialTimeout("tcp", u.Host, 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	req := "GET " + u.RequestURI() + " HTTP/1.0\r\n" +
+		"Host: " + u.Host + "\r\n" +
+		"x-sessioncookie: " + cookie + "\r\n" +
+		"Accept: " + TunnelContentType + "\r\n" +
+		"Pragma: no-cache\r\n" +
+		"Cache-Control: no-cache\r\n\r\n"
+	if _, err = get.Write([]byte(req)); err != nil {
+		_ = get.Close()
+		return nil, err
+	}
+
+	reader := bufio.NewReader(get)
+
+	res, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		_ = get.Close()
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		_ = get.Close()
+		return nil, fmt.Errorf("wrong response on tunnel: %s", res.Status)
+	}
+
+	post, err := net.DialTimeout("tcp", u.Host, 10*time.Second)
+	if err != nil {
+		_ = get.Close()
+		return nil, err
+	}
+
+	// server doesn't answer on POST request
+	req = "POST " + u.RequestURI() + " HTTP/1.0\r\n" +
+		"Host: " + u.Host + "\r\n" +
+		"x-sessioncookie: " + cookie + "\r\n" +
+		"Content-Type: " + TunnelContentType + "\r\n" +
+		"Pragma: no-cache\r\n" +
+		"Cache-Control: no-cache\r\n" +
+		"Content-Length: 32767\r\n" +
+		"Expires: Sun, 9 Jan 1972 00:00:00 GMT\r\n\r\n"
+	if _, err = post.Write([]byte(req)); err != nil {
+		_ = get.Close()
+		_ = post.Close()
+		return nil, err
+	}
+
+	return &tunnelConn{Conn: get, reader: reader, post: post}, nil
+}
+
+func (t *tunnelConn) Read(b []byte) (int, error) {
+	return t.reader.Read(b)
+}
+
+func (t *tunnelConn) Write(b []byte) (int, error) {
+	data := make([]byte, base64.StdEncoding.EncodedLen(len(b)))
+	base64.StdEncoding.Encode(data, b)
+	if _, err := t.post.Write(data); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (t *tunnelConn) Close() error {
+	_ = t.post.Close()
+	return t.Conn.Close()
+}
+
+// Tunnel - server side of RTSP-over-HTTP connection. QuickTime can close POST
+// connection after each request and open new one with the same cookie.
+type Tunnel struct {
+	net.Conn // GET connection
+
+	reader io.Reader // decoded data from current POST connection
+	post   net.Conn
+	posts  chan tunnelPost
+
+	done chan struct{}
+	once sync.Once
+	mx   sync.Mutex
+}
+
+type tunnelPost struct {
+	conn   net.Conn
+	reader io.Reader
+}
+
+// NewTunnel - get should be hijacked GET connection after sending HTTP response
+func NewTunnel(get net.Conn) *Tunnel {
+	return &Tunnel{
+		Conn:  get,
+		posts: make(chan tunnelPost, 1),
+		done:  make(chan struct{}),
+	}
+}
+
+// AddPost - reader should contain data after POST request headers
+func (t *Tunnel) AddPost(conn net.Conn, reader io.Reader) error {
+	select {
+	case t.posts <- tunnelPost{conn: conn, reader: reader}:
+		return nil
+	case <-t.done:
+		return errors.New("tunnel closed")
+	}
+}
+
+func (t *Tunnel) Read(b []byte) (int, error) {
+	for {
+		if t.reader != nil {
+			n, err := t.reader.Read(b)
+			if n > 0 || err != io.EOF {
+				return n, err
+			}
+			t.mx.Lock()
+			_ = t.post.Close()
+			t.post = nil
+			t.mx.Unlock()
+			t.reader = nil
+		}
+
+		select {
+		case post := <-t.posts:
+			t.mx.Lock()
+			t.post = post.conn
+			t.mx.Unlock()
+			t.reader = &base64Reader{src: post.reader}
+		case <-time.After(TunnelTimeout):
+			return 0, io.EOF
+		case <-t.done:
+			return 0, io.EOF
+		}
+	}
+}
+
+func (t *Tunnel) Close() error {
+	t.once.Do(func() {
+		close(t.done)
+	})
+	t.mx.Lock()
+	if t.post != nil {
+		_ = t.post.Close()
+	}
+	t.mx.Unlock()
+	return t.Conn.Close()
+}
+
+// base64Reader decodes each 4 chars separately, because client can
+// send each request encoded separately with its own padding
+type base64Reader struct {
+	src  io.Reader
+	quad [4]byte
+	n    int
+	buf  []byte
+}
+
+func (r *base64Reader) Read(b []byte) (int, error) {
+	for len(r.buf) == 0 {
+		raw := make([]byte, 4096)
+		n, err := r.src.Read(raw)
+		if n == 0 {
+			return 0, err
+		}
+
+		for _, ch := range raw[:n] {
+			switch ch {
+			case '\r', '\n', ' ', '\t':
+				continue
+			}
+
+			r.quad[r.n] = ch
+			if r.n++; r.n < 4 {
+				continue
+			}
+			r.n = 0
+
+			var data [3]byte
+			i, err := base64.StdEncoding.Decode(data[:], r.quad[:])
+			if err != nil {
+				return 0, err
+			}
+			r.buf = append(r.buf, data[:i]...)
+		}
+	}
+
+	n := copy(b, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
@@ -0,0 +1,68 @@
+package rtsp
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestBase64Reader(t *testing.T) {
+	// two requests encoded separately with padding and split by line breaks
+	src := "T1BUSU9OUw==\r\nIHJ0c3A6Ly8=\nMQ=="
+	b, err := ioutil.ReadAll(&base64Reader{src: strings.NewReader(src)})
+	assert.Nil(t, err)
+	assert.Equal(t, "OPTIONS rtsp://1", string(b))
+}
+
+func TestTunnel(t *testing.T) {
+	tunnels := map[string]*Tunnel{}
+	var mu sync.Mutex
+
+	// stand-in for the server side, client opens POST only after GET response
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie := r.Header.Get("x-sessioncookie")
+
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		assert.Nil(t, err)
+
+		switch r.Method {
+		case "GET":
+			_, _ = rw.WriteString("HTTP/1.0 200 OK\r\nContent-Type: " + TunnelContentType + "\r\n\r\n")
+			_ = rw.Flush()
+
+			tunnel := NewTunnel(conn)
+
+			mu.Lock()
+			tunnels[cookie] = tunnel
+			mu.Unlock()
+
+			_ = NewServer(tunnel).Accept()
+			_ = tunnel.Close()
+
+		case "POST":
+			mu.Lock()
+			tunnel := tunnels[cookie]
+			mu.Unlock()
+
+			assert.NotNil(t, tunnel)
+			assert.Nil(t, tunnel.AddPost(conn, rw.Reader))
+		}
+	}))
+	defer srv.Close()
+
+	conn, err := NewClient("rtsp+http://" + srv.Listener.Addr().String() + "/stream")
+	assert.Nil(t, err)
+	assert.Nil(t, conn.Dial())
+
+	// requests go in POST connection and responses come in GET connection
+	for i := 0; i < 2; i++ {
+		assert.Nil(t, conn.Options())
+		assert.True(t, conn.Supports(MethodAnnounce))
+	}
+
+	_ = conn.Close()
+}
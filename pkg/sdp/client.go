@@ -0,0 +1,290 @@
+package sdp
+
+import (
+	"errors"
+	"fmt"
+	"github.com/AlexxIT/go2rtc/pkg/streamer"
+	"github.com/pion/rtp"
+	pion "github.com/pion/sdp/v3"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Timeout - source is dead if there are no packets on all ports
+const Timeout = 5 * time.Second
+
+// Client - producer from RTP packets on UDP ports (unicast or multicast),
+// ports and codecs are from SDP description, packets are reordered
+// by sequence number for each port
+type Client struct {
+	streamer.Element
+
+	URI string
+
+	medias []*streamer.Media
+	ports  []*port
+
+	closed   bool
+	lastRecv time.Time
+	mu       sync.Mutex
+
+	receive int
+}
+
+type port struct {
+	conn   *net.UDPConn
+	media  *streamer.Media
+	tracks map[uint8]*streamer.Track // by payload type
+	any    *streamer.Track           // for media with one codec, any payload type
+	buffer *reorderBuffer
+}
+
+var client = &http.Client{Timeout: 10 * time.Second}
+
+// Load - producer from SDP file or link: /config/camera.sdp, http://192.168.1.123/stream.sdp
+func Load(uri string) (*Client, error) {
+	var b []byte
+	var err error
+
+	if strings.HasPrefix(uri, "http://") || strings.HasPrefix(uri, "https://") {
+		var res *http.Response
+		if res, err = client.Get(uri); err != nil {
+			return nil, err
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			return nil, errors.New("sdp: wrong response status: " + res.Status)
+		}
+		b, err = ioutil.ReadAll(res.Body)
+	} else {
+		b, err = ioutil.ReadFile(uri)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return Open(uri, b)
+}
+
+// Dial - producer from RTP link with codec param, any payload type is accepted:
+// rtp://@239.0.0.1:5004#video=h264, rtp://@:5006#audio=pcma
+func Dial(uri string) (*Client, error) {
+	i := strings.IndexByte(uri, '#')
+	if i < 0 {
+		return nil, errors.New("rtp: codec param is required")
+	}
+
+	u, err := url.Parse(uri[:i])
+	if err != nil {
+		return nil, err
+	}
+
+	kind, name := "", ""
+	if kv := strings.SplitN(uri[i+1:], "=", 2); len(kv) == 2 {
+		kind, name = kv[0], strings.ToUpper(kv[1])
+	}
+
+	switch name {
+	case streamer.CodecH264, streamer.CodecH265, streamer.CodecVP8, streamer.CodecVP9,
+		streamer.CodecAV1, streamer.CodecJPEG, "MJPEG", streamer.CodecPCMU, streamer.CodecPCMA, streamer.CodecOpus:
+	default:
+		return nil, errors.New("rtp: unsupported codec: " + name)
+	}
+
+	codec := streamer.NewCodec(name)
+	if streamer.GetKind(codec.Name) != kind {
+		return nil, errors.New("rtp: wrong codec kind: " + kind)
+	}
+
+	host, port, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		return nil, err
+	}
+	if host == "" {
+		host = "0.0.0.0"
+	}
+
+	// minimal SDP with dynamic payload type
+	s := fmt.Sprintf(
+		"v=0\r\no=- 0 0 IN IP4 %s\r\ns=-\r\nc=IN IP4 %s\r\nt=0 0\r\nm=%s %s RTP/AVP 96\r\na=rtpmap:96 %s/%d",
+		host, host, kind, port, codec.Name, codec.ClockRate,
+	)
+	if codec.Channels > 0 {
+		s = fmt.Sprintf("%s/%d", s, codec.Channels)
+	}
+
+	return Open(uri, []byte(s+"\r\n"))
+}
+
+// Open binds UDP ports from the SDP description
+func Open(uri string, rawSDP []byte) (*Client, error) {
+	sd := &pion.SessionDescription{}
+	if err := sd.Unmarshal(rawSDP); err != nil {
+		return nil, err
+	}
+
+	c := &Client{URI: uri}
+
+	for _, md := range sd.MediaDescriptions {
+		media := streamer.UnmarshalMedia(md)
+		if !media.AV() || md.MediaName.Port.Value == 0 {
+			continue
+		}
+
+		media.Direction = streamer.DirectionSendonly
+
+		// media level address has priority over session level
+		var host string
+		if ci := md.ConnectionInformation; ci != nil && ci.Address != nil {
+			host = ci.Address.Address
+		} else if ci = sd.ConnectionInformation; ci != nil && ci.Address != nil {
+			host = ci.Address.Address
+		}
+
+		conn, err := listen(host, md.MediaName.Port.Value)
+		if err != nil {
+			_ = c.Close()
+			return nil, err
+		}
+
+		p := &port{
+			conn:   conn,
+			media:  media,
+			tracks: map[uint8]*streamer.Track{},
+			buffer: newReorderBuffer(),
+		}
+		for _, codec := range media.Codecs {
+			p.tracks[codec.PayloadType] = &streamer.Track{Codec: codec, Direction: media.Direction}
+		}
+		if len(media.Codecs) == 1 {
+			p.any = p.tracks[media.Codecs[0].PayloadType]
+		}
+
+		c.medias = append(c.medias, media)
+		c.ports = append(c.ports, p)
+	}
+
+	if len(c.ports) == 0 {
+		return nil, errors.New("sdp: can't find media with ports")
+	}
+
+	return c, nil
+}
+
+func (c *Client) Handle() error {
+	defer c.Fire(streamer.StateNull)
+
+	c.Fire(streamer.StatePlaying)
+
+	c.mu.Lock()
+	c.lastRecv = time.Now()
+	c.mu.Unlock()
+
+	errs := make(chan error, len(c.ports))
+	for _, p := range c.ports {
+		go func(p *port) {
+			errs <- c.handlePort(p)
+		}(p)
+	}
+
+	// all ports are closed with the first error
+	err := <-errs
+	_ = c.Close()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	return err
+}
+
+func (c *Client) Close() error {
+	c.mu.Lock()
+	c.closed = true
+	c.mu.Unlock()
+
+	for _, p := range c.ports {
+		_ = p.conn.Close()
+	}
+	return nil
+}
+
+func (c *Client) handlePort(p *port) error {
+	buf := make([]byte, 0xFFFF)
+
+	for {
+		_ = p.conn.SetReadDeadline(time.Now().Add(Timeout))
+		n, err := p.conn.Read(buf)
+		if err != nil {
+			// port without packets is OK, if there are packets on other ports
+			if ne, ok := err.(net.Error); ok && ne.Timeout() && c.alive() {
+				continue
+			}
+			return err
+		}
+
+		// skip RTCP packets, if they are muxed on the same port
+		if n < 12 || buf[0]>>6 != 2 || (buf[1] >= 200 && buf[1] <= 204) {
+			continue
+		}
+
+		packet := &rtp.Packet{}
+		if err = packet.Unmarshal(append([]byte{}, buf[:n]...)); err != nil {
+			continue
+		}
+
+		if p.track(packet.PayloadType) == nil {
+			continue
+		}
+
+		c.mu.Lock()
+		c.receive += n
+		c.lastRecv = time.Now()
+		c.mu.Unlock()
+
+		for _, packet = range p.buffer.push(packet) {
+			_ = p.track(packet.PayloadType).WriteRTP(packet)
+		}
+	}
+}
+
+func (p *port) track(payloadType uint8) *streamer.Track {
+	if track := p.tracks[payloadType]; track != nil {
+		return track
+	}
+	return p.any
+}
+
+func (c *Client) alive() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return time.Since(c.lastRecv) < Timeout
+}
+
+func listen(host string, port int) (*net.UDPConn, error) {
+	addr := &net.UDPAddr{IP: net.ParseIP(host), Port: port}
+
+	var conn *net.UDPConn
+	var err error
+
+	if addr.IP.IsMulticast() {
+		conn, err = net.ListenMulticastUDP("udp", nil, addr)
+	} else {
+		// unicast address is the address of this host, it can be also NAT or hostname,
+		// so listen port on all interfaces
+		conn, err = net.ListenUDP("udp", &net.UDPAddr{Port: port})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	_ = conn.SetReadBuffer(1024 * 1024)
+
+	return conn, nil
+}
@@ -0,0 +1,105 @@
+package sdp
+
+import (
+	"fmt"
+	"github.com/AlexxIT/go2rtc/pkg/streamer"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestReorder(t *testing.T) {
+	r := newReorderBuffer()
+
+	var seqs []uint16
+	push := func(seq uint16) {
+		for _, packet := range r.push(&rtp.Packet{Header: rtp.Header{SequenceNumber: seq}}) {
+			seqs = append(seqs, packet.SequenceNumber)
+		}
+	}
+
+	// wrap of sequence number, duplicate and late packets
+	for _, seq := range []uint16{65534, 0, 65535, 65535, 2, 1, 65533} {
+		push(seq)
+	}
+	assert.Equal(t, []uint16{65534, 65535, 0, 1, 2}, seqs)
+
+	// lost packet 3 is skipped when buffer is full
+	seqs = nil
+	for seq := uint16(4); seq < 4+ReorderSize; seq++ {
+		push(seq)
+	}
+	assert.Len(t, seqs, ReorderSize)
+	assert.Equal(t, uint16(4), seqs[0])
+
+	// lost packet is skipped after the delay, for low packet rate
+	seqs = nil
+	push(4 + ReorderSize + 1)
+	assert.Nil(t, seqs)
+	r.gapTime = r.gapTime.Add(-ReorderDelay)
+	push(4 + ReorderSize + 2)
+	assert.Equal(t, []uint16{4 + ReorderSize + 1, 4 + ReorderSize + 2}, seqs)
+}
+
+func TestClient(t *testing.T) {
+	// SDP has the port of the closed socket, client binds it again
+	conn, err := listen("127.0.0.1", 0)
+	assert.Nil(t, err)
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+	_ = conn.Close()
+
+	rawSDP := fmt.Sprintf(`v=0
+o=- 0 0 IN IP4 127.0.0.1
+s=-
+c=IN IP4 127.0.0.1
+t=0 0
+m=video %d RTP/AVP 96 26
+a=rtpmap:96 H264/90000
+a=fmtp:96 packetization-mode=1
+m=application 0 RTP/AVP 107
+`, port)
+
+	client, err := Open("test.sdp", []byte(rawSDP))
+	assert.Nil(t, err)
+
+	medias := client.GetMedias()
+	assert.Len(t, medias, 1)
+	assert.Equal(t, streamer.DirectionSendonly, medias[0].Direction)
+	assert.Equal(t, streamer.CodecH264, medias[0].Codecs[0].Name)
+	assert.Equal(t, streamer.CodecJPEG, medias[0].Codecs[1].Name)
+
+	var seqs []uint16
+	done := make(chan struct{})
+
+	track := client.GetTrack(medias[0], medias[0].Codecs[0])
+	track.Bind(func(packet *rtp.Packet) error {
+		if seqs = append(seqs, packet.SequenceNumber); len(seqs) == 3 {
+			close(done)
+		}
+		return nil
+	})
+
+	go func() {
+		_ = client.Handle()
+	}()
+
+	sender, err := net.Dial("udp", fmt.Sprintf("127.0.0.1:%d", port))
+	assert.Nil(t, err)
+
+	for _, seq := range []uint16{10, 12, 11} {
+		packet := &rtp.Packet{Header: rtp.Header{Version: 2, PayloadType: 96, SequenceNumber: seq}}
+		b, _ := packet.Marshal()
+		_, _ = sender.Write(b)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+	}
+
+	assert.Equal(t, []uint16{10, 11, 12}, seqs)
+
+	_ = client.Close()
+}
@@ -0,0 +1,83 @@
+package sdp
+
+import (
+	"github.com/pion/rtp"
+	"time"
+)
+
+const (
+	// ReorderSize - max count of packets waiting for the lost packet
+	ReorderSize = 32
+	// ReorderDelay - max wait time for the lost packet, for low packet rate streams
+	ReorderDelay = 100 * time.Millisecond
+)
+
+// reorderBuffer returns packets in sequence order, lost packets are skipped
+// when the buffer is full or after the delay
+type reorderBuffer struct {
+	seq     uint16 // next sequence
+	ok      bool
+	packets map[uint16]*rtp.Packet
+	gapTime time.Time // receive time of the first packet after the gap
+}
+
+func newReorderBuffer() *reorderBuffer {
+	return &reorderBuffer{packets: map[uint16]*rtp.Packet{}}
+}
+
+func (r *reorderBuffer) push(packet *rtp.Packet) []*rtp.Packet {
+	if !r.ok {
+		r.ok = true
+		r.seq = packet.SequenceNumber
+	}
+
+	diff := int16(packet.SequenceNumber - r.seq)
+
+	switch {
+	case diff < -ReorderSize:
+		// source restart, forget old packets
+		r.seq = packet.SequenceNumber
+		r.packets = map[uint16]*rtp.Packet{}
+
+	case diff < 0:
+		return nil // late or duplicate packet
+
+	case diff > 0:
+		if len(r.packets) == 0 {
+			r.gapTime = time.Now()
+		}
+		r.packets[packet.SequenceNumber] = packet
+		if len(r.packets) < ReorderSize && time.Since(r.gapTime) < ReorderDelay {
+			return nil
+		}
+
+		// skip lost packets until the first packet in the buffer
+		next := packet.SequenceNumber
+		for seq := range r.packets {
+			if int16(seq-next) < 0 {
+				next = seq
+			}
+		}
+		r.seq = next
+		return r.flush(nil)
+	}
+
+	r.seq++
+	return r.flush([]*rtp.Packet{packet})
+}
+
+func (r *reorderBuffer) flush(packets []*rtp.Packet) []*rtp.Packet {
+	for {
+		packet, ok := r.packets[r.seq]
+		if !ok {
+			// new gap for the rest of the buffer
+			if len(r.packets) > 0 {
+				r.gapTime = time.Now()
+			}
+			return packets
+		}
+		delete(r.packets, r.seq)
+		packets = append(packets, packet)
+		r.seq++
+	}
+}
@@ -0,0 +1,49 @@
+package sdp
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/AlexxIT/go2rtc/pkg/streamer"
+	"net"
+	"strconv"
+)
+
+func (c *Client) GetMedias() []*streamer.Media {
+	return c.medias
+}
+
+func (c *Client) GetTrack(media *streamer.Media, codec *streamer.Codec) *streamer.Track {
+	for _, p := range c.ports {
+		if p.media == media {
+			if track := p.tracks[codec.PayloadType]; track != nil && track.Codec == codec {
+				return track
+			}
+		}
+	}
+	panic(fmt.Sprintf("wrong media/codec: %+v %+v", media, codec))
+}
+
+func (c *Client) Start() error {
+	return c.Handle()
+}
+
+func (c *Client) Stop() error {
+	return c.Close()
+}
+
+func (c *Client) MarshalJSON() ([]byte, error) {
+	c.mu.Lock()
+	receive := c.receive
+	c.mu.Unlock()
+
+	v := map[string]interface{}{
+		streamer.JSONReceive: receive,
+		streamer.JSONType:    "RTP client producer",
+		"url":                c.URI,
+	}
+	for i, p := range c.ports {
+		k := "media:" + strconv.Itoa(i)
+		v[k] = p.media.String() + ", port=" + strconv.Itoa(p.conn.LocalAddr().(*net.UDPAddr).Port)
+	}
+	return json.Marshal(v)
+}
@@ -0,0 +1,261 @@
+package srt
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ListenTimeout - wait time for the incoming connection in listener mode
+const ListenTimeout = 30 * time.Second
+
+const handshakeInterval = 250 * time.Millisecond
+
+// Options of the connection, same as params of SRT links
+type Options struct {
+	StreamID   string
+	Passphrase string
+	KeyLength  int // pbkeylen: 16, 24 or 32 bytes, only for caller
+	Latency    time.Duration
+}
+
+// Open - SRT connection from link, caller mode by default:
+//   - srt://192.168.1.123:9000?streamid=camera1&passphrase=secret123&latency=200
+//   - srt://:9000?mode=listener - wait for one incoming connection
+func Open(uri string) (*Conn, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	query := u.Query()
+
+	opts := &Options{
+		StreamID:   query.Get("streamid"),
+		Passphrase: query.Get("passphrase"),
+	}
+	opts.KeyLength, _ = strconv.Atoi(query.Get("pbkeylen"))
+	if ms, _ := strconv.Atoi(query.Get("latency")); ms > 0 {
+		opts.Latency = time.Duration(ms) * time.Millisecond
+	}
+
+	switch query.Get("mode") {
+	case "", "caller":
+		return Dial(u.Host, opts)
+	case "listener":
+		return accept(u.Host, opts)
+	}
+
+	return nil, errors.New("srt: unsupported mode: " + query.Get("mode"))
+}
+
+// Dial - SRT connection in caller mode
+func Dial(address string, opts *Options) (*Conn, error) {
+	addr, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.DialUDP("udp", nil, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := handshakeCaller(conn, addr, opts)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	c.remote = addr
+	c.write = func(b []byte) error {
+		_, err := conn.Write(b)
+		return err
+	}
+	c.onClose = func() {
+		_ = conn.Close()
+	}
+
+	go func() {
+		buf := make([]byte, 0xFFFF)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				_ = c.Close()
+				return
+			}
+
+			p, err := parsePacket(append([]byte{}, buf[:n]...))
+			if err != nil || p.socketID != c.socketID {
+				continue
+			}
+
+			c.handle(p)
+		}
+	}()
+
+	go c.worker()
+
+	return c, nil
+}
+
+func handshakeCaller(conn *net.UDPConn, addr *net.UDPAddr, opts *Options) (*Conn, error) {
+	socketID := randUint32()
+	seq := randUint32() & seqNumberMask
+
+	latency := opts.Latency
+	if latency == 0 {
+		latency = DefaultLatency
+	}
+
+	// induction, version 4 for compatibility, response from HSv5 listener has magic
+	req := &handshake{
+		version:   4,
+		extension: 2,
+		seq:       seq,
+		mtu:       defaultMTU,
+		window:    defaultFlowWindow,
+		typ:       hsInduction,
+		socketID:  socketID,
+		peerIP:    addr.IP,
+	}
+
+	res, err := request(conn, socketID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.version != hsVersion || res.extension != hsMagic {
+		return nil, errors.New("srt: unsupported handshake version")
+	}
+
+	// conclusion with SRT extensions
+	req.version = hsVersion
+	req.extension = extensionFlagHS
+	req.typ = hsConclusion
+	req.cookie = res.cookie
+	req.latency = uint16(latency / time.Millisecond)
+
+	var km *keyMaterial
+	if opts.Passphrase != "" {
+		if km, err = newKeyMaterial(opts.KeyLength); err != nil {
+			return nil, err
+		}
+		if req.km, err = km.marshal(opts.Passphrase); err != nil {
+			return nil, err
+		}
+		req.encryption = uint16(len(km.keys[keyEven]) / 8)
+		req.extension |= extensionFlagKM
+	}
+
+	if opts.StreamID != "" {
+		req.streamID = opts.StreamID
+		req.extension |= extensionFlagConfig
+	}
+
+	if res, err = request(conn, socketID, req); err != nil {
+		return nil, err
+	}
+
+	if res.typ != hsConclusion {
+		return nil, fmt.Errorf("srt: connection rejected: %d", res.typ)
+	}
+
+	if km != nil {
+		// KMRSP with key material or with error state
+		if len(res.km) <= 4 {
+			return nil, ErrBadSecret
+		}
+	} else if res.km != nil {
+		return nil, errors.New("srt: peer with encryption")
+	}
+
+	if peer := time.Duration(res.latency) * time.Millisecond; peer > latency {
+		latency = peer
+	}
+
+	c := newConn(socketID, res.socketID, seq, latency, km)
+	c.StreamID = opts.StreamID
+	c.passphrase = opts.Passphrase
+	return c, nil
+}
+
+// request sends handshake and waits for the response, with retries for lost packets
+func request(conn *net.UDPConn, socketID uint32, req *handshake) (*handshake, error) {
+	p := &packet{control: true, typ: TypeHandshake, payload: req.marshal()}
+	b := p.marshal()
+	buf := make([]byte, 0xFFFF)
+
+	defer conn.SetReadDeadline(time.Time{})
+
+	for deadline := time.Now().Add(Timeout); time.Now().Before(deadline); {
+		if _, err := conn.Write(b); err != nil {
+			return nil, err
+		}
+
+		_ = conn.SetReadDeadline(time.Now().Add(handshakeInterval))
+
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				if ne, ok := err.(net.Error); ok && ne.Timeout() {
+					break // send again
+				}
+				return nil, err
+			}
+
+			p, err = parsePacket(buf[:n])
+			if err != nil || !p.control || p.typ != TypeHandshake || p.socketID != socketID {
+				continue
+			}
+
+			res, err := parseHandshake(p.payload)
+			if err != nil {
+				return nil, err
+			}
+
+			if res.typ == req.typ || res.typ >= RejectBase {
+				return res, nil
+			}
+		}
+	}
+
+	return nil, errors.New("srt: handshake timeout")
+}
+
+// accept waits for one incoming connection, other callers are rejected,
+// listener is closed with the connection
+func accept(address string, opts *Options) (*Conn, error) {
+	l, err := listen(address, opts, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	timer := time.AfterFunc(ListenTimeout, func() {
+		_ = l.Close()
+	})
+
+	c, err := l.Accept()
+	timer.Stop()
+
+	if err != nil {
+		return nil, errors.New("srt: no incoming connection")
+	}
+
+	c.mu.Lock()
+	if c.closed {
+		_ = l.Close()
+	} else {
+		onClose := c.onClose
+		c.onClose = func() {
+			onClose()
+			_ = l.Close()
+		}
+	}
+	c.mu.Unlock()
+
+	return c, nil
+}
@@ -0,0 +1,482 @@
+package srt
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// PayloadSize - max payload of the data packet, seven MPEG-TS packets
+const PayloadSize = 1316
+
+const (
+	DefaultLatency = 120 * time.Millisecond
+	// Timeout - for handshake and for the peer without any packets
+	Timeout = 5 * time.Second
+
+	tickInterval      = 10 * time.Millisecond // ACK and loss check interval
+	keepaliveInterval = time.Second
+	minNAKInterval    = 20 * time.Millisecond
+)
+
+var ErrTimeout = errors.New("srt: peer timeout")
+
+// Conn - SRT connection in live mode. Lost packets are requested again
+// until they are later than latency, then they are skipped, packets are
+// delivered to Read in order as soon as they are received
+type Conn struct {
+	StreamID string
+	Latency  time.Duration
+
+	remote  *net.UDPAddr
+	write   func(b []byte) error
+	onClose func()
+
+	socketID uint32
+	peerID   uint32
+	start    time.Time
+
+	km         *keyMaterial
+	passphrase string // for key material of the key refresh
+
+	// sender
+	sendSeq  uint32
+	sendMsg  uint32
+	sendBuf  []*sentPacket // not acknowledged packets
+	lastSend time.Time
+
+	// receiver
+	recvSeq  uint32            // next sequence for Read
+	recvNext uint32            // max received sequence + 1
+	recvBuf  map[uint32][]byte // received payloads, not ready for Read
+	lost     map[uint32]time.Time
+	lastRecv time.Time
+	lastNAK  time.Time
+	queue    [][]byte // ready payloads for Read
+	data     []byte
+
+	ackSeq    uint32
+	ackNumber uint32
+	acks      map[uint32]time.Time // send time of ACK for RTT
+	rtt       time.Duration
+	rttVar    time.Duration
+
+	response []byte // handshake response for retransmitted conclusion
+
+	closed bool
+	err    error
+	done   chan struct{}
+	cond   *sync.Cond
+	mu     sync.Mutex
+}
+
+type sentPacket struct {
+	packet *packet
+	time   time.Time
+}
+
+func newConn(socketID, peerID, seq uint32, latency time.Duration, km *keyMaterial) *Conn {
+	now := time.Now()
+	c := &Conn{
+		Latency:  latency,
+		socketID: socketID,
+		peerID:   peerID,
+		start:    now,
+		km:       km,
+		sendSeq:  seq,
+		sendMsg:  1,
+		recvSeq:  seq,
+		recvNext: seq,
+		recvBuf:  map[uint32][]byte{},
+		lost:     map[uint32]time.Time{},
+		lastRecv: now,
+		lastSend: now,
+		ackSeq:   seq,
+		acks:     map[uint32]time.Time{},
+		rtt:      100 * time.Millisecond,
+		rttVar:   50 * time.Millisecond,
+		done:     make(chan struct{}),
+	}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+func (c *Conn) Read(p []byte) (n int, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for len(c.data) == 0 {
+		if len(c.queue) > 0 {
+			c.data = c.queue[0]
+			c.queue = c.queue[1:]
+			continue
+		}
+		if c.closed {
+			return 0, c.err
+		}
+		c.cond.Wait()
+	}
+
+	n = copy(p, c.data)
+	c.data = c.data[n:]
+	return
+}
+
+// Write splits data to packets with PayloadSize
+func (c *Conn) Write(p []byte) (n int, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return 0, io.ErrClosedPipe
+	}
+
+	now := time.Now()
+
+	for len(p) > 0 {
+		size := len(p)
+		if size > PayloadSize {
+			size = PayloadSize
+		}
+
+		pkt := &packet{
+			seq:       c.sendSeq,
+			msg:       msgSolo | c.sendMsg&msgNumberMask,
+			timestamp: c.timestamp(now),
+			socketID:  c.peerID,
+		}
+
+		if c.km != nil {
+			pkt.msg |= msgKeyEven
+			pkt.payload = c.km.crypt(keyEven, pkt.seq, p[:size])
+		} else {
+			pkt.payload = append([]byte{}, p[:size]...)
+		}
+
+		c.sendSeq = seqNext(c.sendSeq)
+		if c.sendMsg++; c.sendMsg > msgNumberMask {
+			c.sendMsg = 1
+		}
+
+		c.sendBuf = append(c.sendBuf, &sentPacket{packet: pkt, time: now})
+
+		if err = c.send(pkt); err != nil {
+			return
+		}
+
+		n += size
+		p = p[size:]
+	}
+
+	return
+}
+
+// Close sends shutdown to the peer
+func (c *Conn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil
+	}
+
+	_ = c.send(&packet{control: true, typ: TypeShutdown, payload: make([]byte, 4)})
+	c.closeWithError(io.EOF)
+
+	return nil
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+// handle processes packet from the peer
+func (c *Conn) handle(p *packet) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return
+	}
+
+	c.lastRecv = time.Now()
+
+	if !p.control {
+		c.handleData(p)
+		return
+	}
+
+	switch p.typ {
+	case TypeACK:
+		if len(p.payload) < 4 {
+			return
+		}
+
+		seq := binary.BigEndian.Uint32(p.payload)
+		i := 0
+		for ; i < len(c.sendBuf); i++ {
+			if seqDiff(seq, c.sendBuf[i].packet.seq) <= 0 {
+				break
+			}
+		}
+		c.sendBuf = c.sendBuf[i:]
+
+		// full ACK has number and waits for ACKACK, light ACK has only sequence
+		if p.info != 0 {
+			_ = c.send(&packet{control: true, typ: TypeACKACK, info: p.info, payload: make([]byte, 4)})
+		}
+
+	case TypeACKACK:
+		if t, ok := c.acks[p.info]; ok {
+			delete(c.acks, p.info)
+
+			rtt := time.Since(t)
+			diff := c.rtt - rtt
+			if diff < 0 {
+				diff = -diff
+			}
+			c.rttVar = (3*c.rttVar + diff) / 4
+			c.rtt = (7*c.rtt + rtt) / 8
+		}
+
+	case TypeNAK:
+		for _, seq := range parseLossList(p.payload) {
+			for _, sent := range c.sendBuf {
+				if sent.packet.seq == seq {
+					sent.packet.msg |= msgRetransmit
+					_ = c.send(sent.packet)
+					break
+				}
+			}
+		}
+
+	case TypeExt:
+		if p.subtype == extensionKMReq {
+			c.handleKeyMaterial(p)
+		}
+
+	case TypeShutdown:
+		c.closeWithError(io.EOF)
+	}
+}
+
+// handleKeyMaterial updates keys after key refresh of the peer,
+// response is the same message or the error state
+func (c *Conn) handleKeyMaterial(p *packet) {
+	res := p.payload
+
+	if c.km == nil || c.passphrase == "" {
+		res = appendUint32(nil, kmNoSecret)
+	} else if km, err := parseKeyMaterial(p.payload, c.passphrase); err != nil {
+		res = appendUint32(nil, kmBadSecret)
+	} else {
+		c.km.update(km)
+	}
+
+	_ = c.send(&packet{control: true, typ: TypeExt, subtype: extensionKMRsp, payload: res})
+}
+
+func (c *Conn) handleData(p *packet) {
+	if seqDiff(p.seq, c.recvSeq) < 0 {
+		return // old or late packet
+	}
+	if _, ok := c.recvBuf[p.seq]; ok {
+		return // duplicate
+	}
+
+	var payload []byte
+	switch p.msg & msgKeyMask {
+	case 0:
+		payload = append([]byte{}, p.payload...)
+	case msgKeyEven, msgKeyOdd:
+		if c.km == nil {
+			return
+		}
+		i := keyEven
+		if p.msg&msgKeyMask == msgKeyOdd {
+			i = keyOdd
+		}
+		if payload = c.km.crypt(i, p.seq, p.payload); payload == nil {
+			return
+		}
+	default:
+		return
+	}
+
+	delete(c.lost, p.seq)
+
+	// new packets after the gap, report lost packets right away
+	if seqDiff(p.seq, c.recvNext) >= 0 {
+		var lost []uint32
+		now := time.Now()
+		for seq := c.recvNext; seq != p.seq && len(lost) < defaultFlowWindow; seq = seqNext(seq) {
+			c.lost[seq] = now
+			lost = append(lost, seq)
+		}
+		c.recvNext = seqNext(p.seq)
+
+		if lost != nil {
+			c.sendNAK(lost)
+		}
+	}
+
+	c.recvBuf[p.seq] = payload
+	c.flush()
+}
+
+// flush moves payloads in order to the queue for Read
+func (c *Conn) flush() {
+	var ready bool
+	for {
+		b, ok := c.recvBuf[c.recvSeq]
+		if !ok {
+			break
+		}
+		delete(c.recvBuf, c.recvSeq)
+		c.queue = append(c.queue, b)
+		c.recvSeq = seqNext(c.recvSeq)
+		ready = true
+	}
+	if ready {
+		c.cond.Broadcast()
+	}
+}
+
+// worker sends ACK, NAK and keepalive packets, checks peer timeout
+func (c *Conn) worker() {
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			c.mu.Lock()
+			c.tick(now)
+			c.mu.Unlock()
+		case <-c.done:
+			c.mu.Lock()
+			onClose := c.onClose
+			c.mu.Unlock()
+
+			if onClose != nil {
+				onClose()
+			}
+			return
+		}
+	}
+}
+
+func (c *Conn) tick(now time.Time) {
+	if c.closed {
+		return
+	}
+
+	if now.Sub(c.lastRecv) > Timeout {
+		c.closeWithError(ErrTimeout)
+		return
+	}
+
+	// too late packets, skip them until the next received packet
+	if t, ok := c.lost[c.recvSeq]; ok && now.Sub(t) > c.Latency {
+		for c.recvSeq != c.recvNext {
+			if _, ok = c.recvBuf[c.recvSeq]; ok {
+				break
+			}
+			delete(c.lost, c.recvSeq)
+			c.recvSeq = seqNext(c.recvSeq)
+		}
+		c.flush()
+	}
+
+	// periodic NAK, because NAK or retransmitted packet can be lost too
+	if len(c.lost) > 0 {
+		interval := (c.rtt + 4*c.rttVar) / 2
+		if interval < minNAKInterval {
+			interval = minNAKInterval
+		}
+		if now.Sub(c.lastNAK) > interval {
+			lost := make([]uint32, 0, len(c.lost))
+			for seq := range c.lost {
+				lost = append(lost, seq)
+			}
+			sort.Slice(lost, func(i, j int) bool {
+				return seqDiff(lost[i], lost[j]) < 0
+			})
+			c.sendNAK(lost)
+		}
+	}
+
+	if c.ackSeq != c.recvSeq {
+		c.sendACK(now)
+	}
+
+	// the peer doesn't need packets later than its latency
+	i := 0
+	for ; i < len(c.sendBuf); i++ {
+		if now.Sub(c.sendBuf[i].time) < c.Latency+time.Second {
+			break
+		}
+	}
+	c.sendBuf = c.sendBuf[i:]
+
+	if now.Sub(c.lastSend) > keepaliveInterval {
+		_ = c.send(&packet{control: true, typ: TypeKeepalive, payload: make([]byte, 4)})
+	}
+}
+
+func (c *Conn) sendACK(now time.Time) {
+	c.ackNumber++
+	c.ackSeq = c.recvSeq
+
+	// peer can skip ACKACK, so keep only recent ACKs
+	if len(c.acks) > 64 {
+		c.acks = map[uint32]time.Time{}
+	}
+	c.acks[c.ackNumber] = now
+
+	// last sequence, RTT, RTT variance, available buffer, packets rate, capacity, receiving rate
+	b := appendUint32(nil, c.recvSeq)
+	b = appendUint32(b, uint32(c.rtt/time.Microsecond))
+	b = appendUint32(b, uint32(c.rttVar/time.Microsecond))
+	b = appendUint32(b, defaultFlowWindow)
+	b = append(b, make([]byte, 12)...)
+
+	_ = c.send(&packet{control: true, typ: TypeACK, info: c.ackNumber, payload: b})
+}
+
+func (c *Conn) sendNAK(lost []uint32) {
+	c.lastNAK = time.Now()
+	_ = c.send(&packet{control: true, typ: TypeNAK, payload: lossList(lost)})
+}
+
+func (c *Conn) send(p *packet) error {
+	now := time.Now()
+	if p.control {
+		p.timestamp = c.timestamp(now)
+		p.socketID = c.peerID
+	}
+	c.lastSend = now
+	return c.write(p.marshal())
+}
+
+func (c *Conn) timestamp(now time.Time) uint32 {
+	return uint32(now.Sub(c.start) / time.Microsecond)
+}
+
+func (c *Conn) closeWithError(err error) {
+	c.closed = true
+	c.err = err
+	close(c.done)
+	c.cond.Broadcast()
+}
+
+func randUint32() uint32 {
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return binary.BigEndian.Uint32(b)
+}
@@ -0,0 +1,238 @@
+package srt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+var ErrBadSecret = errors.New("srt: wrong passphrase")
+
+const (
+	saltSize     = 16
+	kmHeaderSize = 16
+	kekIter      = 2048 // PBKDF2 iterations for KEK from passphrase
+)
+
+// key index of key material, data packets have even or odd key flag
+const (
+	keyEven = 0
+	keyOdd  = 1
+)
+
+// KMRSP state in place of key material, when it can't be decoded
+const (
+	kmNoSecret  = 3
+	kmBadSecret = 4
+)
+
+// keyMaterial - stream encrypting keys (SEK) with salt, SEK is sent to the peer
+// wrapped with key encrypting key (KEK) from the passphrase. Sender can refresh
+// the key, then new key is announced in the other (even or odd) slot
+type keyMaterial struct {
+	salt   []byte
+	keys   [2][]byte
+	blocks [2]cipher.Block
+}
+
+func newKeyMaterial(keyLen int) (*keyMaterial, error) {
+	switch keyLen {
+	case 0:
+		keyLen = 16
+	case 16, 24, 32:
+	default:
+		return nil, errors.New("srt: wrong key length")
+	}
+
+	b := make([]byte, saltSize+keyLen)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+
+	km := &keyMaterial{salt: b[:saltSize]}
+	if err := km.setKey(keyEven, b[saltSize:]); err != nil {
+		return nil, err
+	}
+	return km, nil
+}
+
+func (km *keyMaterial) setKey(i int, key []byte) error {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	km.keys[i] = key
+	km.blocks[i] = block
+	return nil
+}
+
+// update sets salt and keys from the key material of the key refresh
+func (km *keyMaterial) update(from *keyMaterial) {
+	km.salt = from.salt
+	for i := range from.keys {
+		if from.keys[i] != nil {
+			km.keys[i] = from.keys[i]
+			km.blocks[i] = from.blocks[i]
+		}
+	}
+}
+
+// parseKeyMaterial unwraps SEKs from key material message (KMREQ or KMRSP)
+func parseKeyMaterial(b []byte, passphrase string) (*keyMaterial, error) {
+	// version 1, packet type 2 (KMmsg), signature "HAI"
+	if len(b) < kmHeaderSize || b[0] != 0x12 || b[1] != 0x20 || b[2] != 0x29 {
+		return nil, errors.New("srt: wrong key material")
+	}
+
+	kk := int(b[3] & 3)
+	saltLen := int(b[14]) * 4
+	keyLen := int(b[15]) * 4
+
+	// one or two keys (even and odd) in one wrap, even key first
+	keys := 1
+	if kk == 3 {
+		keys = 2
+	}
+
+	if kk == 0 || b[8] != 2 || saltLen != saltSize || len(b) < kmHeaderSize+saltLen+8+keyLen*keys {
+		return nil, errors.New("srt: unsupported key material")
+	}
+
+	salt := b[kmHeaderSize : kmHeaderSize+saltLen]
+	wrap := b[kmHeaderSize+saltLen : kmHeaderSize+saltLen+8+keyLen*keys]
+
+	kek := pbkdf2.Key([]byte(passphrase), salt[saltLen-8:], kekIter, keyLen, sha1.New)
+
+	key, err := keyUnwrap(kek, wrap)
+	if err != nil {
+		return nil, err
+	}
+
+	km := &keyMaterial{salt: append([]byte{}, salt...)}
+	for i := keyEven; i <= keyOdd; i++ {
+		if kk&(1<<i) == 0 {
+			continue
+		}
+		if err = km.setKey(i, key[:keyLen]); err != nil {
+			return nil, err
+		}
+		key = key[keyLen:]
+	}
+	return km, nil
+}
+
+// marshal returns key material message with all keys
+func (km *keyMaterial) marshal(passphrase string) ([]byte, error) {
+	var kk byte
+	var keys []byte
+	for i, key := range km.keys {
+		if key != nil {
+			kk |= 1 << i
+			keys = append(keys, key...)
+		}
+	}
+
+	keyLen := len(keys)
+	if kk == 3 {
+		keyLen /= 2
+	}
+
+	kek := pbkdf2.Key([]byte(passphrase), km.salt[saltSize-8:], kekIter, keyLen, sha1.New)
+
+	wrap, err := keyWrap(kek, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	b := []byte{
+		0x12, 0x20, 0x29, kk, // version, type, signature, keys
+		0, 0, 0, 0, // KEK index
+		2, 0, 2, 0, // cipher AES-CTR, no auth, stream encapsulation SRT
+		0, 0, saltSize / 4, byte(keyLen / 4),
+	}
+	b = append(b, km.salt...)
+	return append(b, wrap...), nil
+}
+
+// crypt encrypts or decrypts payload with AES-CTR, IV from salt and packet sequence,
+// returns nil if there is no key with this index
+func (km *keyMaterial) crypt(i int, seq uint32, b []byte) []byte {
+	if km.blocks[i] == nil {
+		return nil
+	}
+
+	iv := make([]byte, aes.BlockSize)
+	binary.BigEndian.PutUint32(iv[10:], seq)
+	for j := 0; j < 14; j++ {
+		iv[j] ^= km.salt[j]
+	}
+
+	dst := make([]byte, len(b))
+	cipher.NewCTR(km.blocks[i], iv).XORKeyStream(dst, b)
+	return dst
+}
+
+var wrapIV = []byte{0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6}
+
+// keyWrap - AES key wrap (RFC 3394)
+func keyWrap(kek, key []byte) ([]byte, error) {
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(key) / 8
+	a := append([]byte{}, wrapIV...)
+	r := append([]byte{}, key...)
+	buf := make([]byte, aes.BlockSize)
+
+	for j := 0; j < 6; j++ {
+		for i := 0; i < n; i++ {
+			copy(buf, a)
+			copy(buf[8:], r[i*8:])
+			block.Encrypt(buf, buf)
+
+			t := uint64(n*j + i + 1)
+			binary.BigEndian.PutUint64(a, binary.BigEndian.Uint64(buf)^t)
+			copy(r[i*8:], buf[8:])
+		}
+	}
+
+	return append(a, r...), nil
+}
+
+// keyUnwrap - AES key unwrap (RFC 3394), checks integrity of the key
+func keyUnwrap(kek, b []byte) ([]byte, error) {
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(b)/8 - 1
+	a := append([]byte{}, b[:8]...)
+	r := append([]byte{}, b[8:]...)
+	buf := make([]byte, aes.BlockSize)
+
+	for j := 5; j >= 0; j-- {
+		for i := n - 1; i >= 0; i-- {
+			t := uint64(n*j + i + 1)
+			binary.BigEndian.PutUint64(buf, binary.BigEndian.Uint64(a)^t)
+			copy(buf[8:], r[i*8:i*8+8])
+			block.Decrypt(buf, buf)
+
+			copy(a, buf[:8])
+			copy(r[i*8:], buf[8:])
+		}
+	}
+
+	if !bytes.Equal(a, wrapIV) {
+		return nil, ErrBadSecret
+	}
+
+	return r, nil
+}
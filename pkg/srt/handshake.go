@@ -0,0 +1,181 @@
+package srt
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+)
+
+// handshake types
+const (
+	hsInduction  = 1
+	hsConclusion = 0xFFFFFFFF
+)
+
+// rejection reasons in the handshake type field
+const (
+	RejectBase      = 1000
+	RejectPeer      = RejectBase + 2
+	RejectResource  = RejectBase + 3
+	RejectBadSecret = RejectBase + 10   // wrong passphrase
+	RejectUnsecure  = RejectBase + 11   // encryption on one side only
+	RejectNotFound  = RejectBase + 1404 // predefined codes are HTTP codes + 1000
+)
+
+const (
+	hsMagic      = 0x4A17 // extension field of HSv5 induction response
+	hsHeaderSize = 48
+	hsVersion    = 5
+
+	srtVersion = 0x010500
+	srtFlags   = 0x3F // TSBPD send and recv, crypt, too-late drop, periodic NAK, retransmit flag
+
+	defaultMTU        = 1500
+	defaultFlowWindow = 8192
+
+	extensionHSReq = 1
+	extensionHSRsp = 2
+	extensionKMReq = 3
+	extensionKMRsp = 4
+	extensionSID   = 5
+
+	extensionFlagHS     = 1
+	extensionFlagKM     = 2
+	extensionFlagConfig = 4
+)
+
+type handshake struct {
+	version    uint32
+	encryption uint16 // key length / 8 or zero
+	extension  uint16 // magic for induction, extension flags for conclusion
+	seq        uint32 // initial sequence number
+	mtu        uint32
+	window     uint32
+	typ        uint32
+	socketID   uint32 // socket ID of the sender
+	cookie     uint32
+	peerIP     net.IP
+
+	// extensions of conclusion
+	latency  uint16 // TSBPD delay in milliseconds
+	km       []byte // key material message
+	streamID string
+	response bool // HSRSP and KMRSP instead of HSREQ and KMREQ
+}
+
+func parseHandshake(b []byte) (*handshake, error) {
+	if len(b) < hsHeaderSize {
+		return nil, errors.New("srt: handshake too short")
+	}
+
+	hs := &handshake{
+		version:    binary.BigEndian.Uint32(b),
+		encryption: binary.BigEndian.Uint16(b[4:]),
+		extension:  binary.BigEndian.Uint16(b[6:]),
+		seq:        binary.BigEndian.Uint32(b[8:]),
+		mtu:        binary.BigEndian.Uint32(b[12:]),
+		window:     binary.BigEndian.Uint32(b[16:]),
+		typ:        binary.BigEndian.Uint32(b[20:]),
+		socketID:   binary.BigEndian.Uint32(b[24:]),
+		cookie:     binary.BigEndian.Uint32(b[28:]),
+	}
+
+	// extensions: type (16 bits), length in 4 byte words (16 bits), content
+	for b = b[hsHeaderSize:]; len(b) >= 4; {
+		typ := binary.BigEndian.Uint16(b)
+		size := 4 * int(binary.BigEndian.Uint16(b[2:]))
+		if 4+size > len(b) {
+			return nil, errors.New("srt: wrong handshake extension")
+		}
+
+		data := b[4 : 4+size]
+		b = b[4+size:]
+
+		switch typ {
+		case extensionHSReq, extensionHSRsp:
+			if len(data) >= 12 {
+				// receiver delay in high bits, sender delay in low bits
+				recv := binary.BigEndian.Uint16(data[8:])
+				send := binary.BigEndian.Uint16(data[10:])
+				if hs.latency = recv; send > recv {
+					hs.latency = send
+				}
+			}
+			hs.response = typ == extensionHSRsp
+		case extensionKMReq, extensionKMRsp:
+			hs.km = data
+		case extensionSID:
+			hs.streamID = string(swapWords(data))
+			for n := len(hs.streamID); n > 0 && hs.streamID[n-1] == 0; n-- {
+				hs.streamID = hs.streamID[:n-1]
+			}
+		}
+	}
+
+	return hs, nil
+}
+
+func (hs *handshake) marshal() []byte {
+	b := make([]byte, hsHeaderSize)
+	binary.BigEndian.PutUint32(b, hs.version)
+	binary.BigEndian.PutUint16(b[4:], hs.encryption)
+	binary.BigEndian.PutUint16(b[6:], hs.extension)
+	binary.BigEndian.PutUint32(b[8:], hs.seq)
+	binary.BigEndian.PutUint32(b[12:], hs.mtu)
+	binary.BigEndian.PutUint32(b[16:], hs.window)
+	binary.BigEndian.PutUint32(b[20:], hs.typ)
+	binary.BigEndian.PutUint32(b[24:], hs.socketID)
+	binary.BigEndian.PutUint32(b[28:], hs.cookie)
+
+	// IPv4 in the first word in little endian, as the reference library does
+	if ip := hs.peerIP.To4(); ip != nil {
+		b[32], b[33], b[34], b[35] = ip[3], ip[2], ip[1], ip[0]
+	} else if ip = hs.peerIP.To16(); ip != nil {
+		copy(b[32:], ip)
+	}
+
+	if hs.version != hsVersion || hs.typ != hsConclusion {
+		return b
+	}
+
+	typ := uint16(extensionHSReq)
+	if hs.response {
+		typ = extensionHSRsp
+	}
+	data := appendUint32(nil, srtVersion)
+	data = appendUint32(data, srtFlags)
+	data = appendUint32(data, uint32(hs.latency)<<16|uint32(hs.latency))
+	b = appendExtension(b, typ, data)
+
+	if hs.km != nil {
+		typ = extensionKMReq
+		if hs.response {
+			typ = extensionKMRsp
+		}
+		b = appendExtension(b, typ, hs.km)
+	}
+
+	if hs.streamID != "" {
+		data = []byte(hs.streamID)
+		for len(data)%4 != 0 {
+			data = append(data, 0)
+		}
+		b = appendExtension(b, extensionSID, swapWords(data))
+	}
+
+	return b
+}
+
+func appendExtension(b []byte, typ uint16, data []byte) []byte {
+	b = append(b, byte(typ>>8), byte(typ), byte(len(data)/4>>8), byte(len(data)/4))
+	return append(b, data...)
+}
+
+// swapWords - stream ID is sent as 32 bit words in little endian
+func swapWords(b []byte) []byte {
+	b = append([]byte{}, b...)
+	for i := 0; i+4 <= len(b); i += 4 {
+		b[i], b[i+1], b[i+2], b[i+3] = b[i+3], b[i+2], b[i+1], b[i]
+	}
+	return b
+}
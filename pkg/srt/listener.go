@@ -0,0 +1,297 @@
+package srt
+
+import (
+	"errors"
+	"hash/crc32"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AcceptBacklog - max count of connections waiting for Accept
+const AcceptBacklog = 16
+
+var ErrClosed = errors.New("srt: listener closed")
+
+// Listener - SRT server, all connections share one UDP socket
+type Listener struct {
+	conn   *net.UDPConn
+	opts   Options
+	secret string // for handshake cookies
+
+	conns  map[uint32]*Conn // by local socket ID
+	peers  map[string]*Conn // by peer address and socket ID
+	accept chan *Conn
+	limit  int // max count of accepted connections, zero for unlimited
+	count  int
+	done   chan struct{}
+	mu     sync.Mutex
+}
+
+func Listen(address string, opts *Options) (*Listener, error) {
+	return listen(address, opts, 0)
+}
+
+func listen(address string, opts *Options, limit int) (*Listener, error) {
+	addr, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	_ = conn.SetReadBuffer(1024 * 1024)
+
+	l := &Listener{
+		conn:   conn,
+		secret: strconv.FormatUint(uint64(randUint32()), 16),
+		conns:  map[uint32]*Conn{},
+		peers:  map[string]*Conn{},
+		accept: make(chan *Conn, AcceptBacklog),
+		done:   make(chan struct{}),
+		limit:  limit,
+	}
+	if opts != nil {
+		l.opts = *opts
+	}
+	if l.opts.Latency == 0 {
+		l.opts.Latency = DefaultLatency
+	}
+
+	go l.serve()
+
+	return l, nil
+}
+
+// Accept returns new connection, stream ID is from the caller
+func (l *Listener) Accept() (*Conn, error) {
+	select {
+	case c := <-l.accept:
+		return c, nil
+	case <-l.done:
+		return nil, ErrClosed
+	}
+}
+
+func (l *Listener) Addr() net.Addr {
+	return l.conn.LocalAddr()
+}
+
+// Close listener and all its connections
+func (l *Listener) Close() error {
+	l.mu.Lock()
+	select {
+	case <-l.done:
+		l.mu.Unlock()
+		return nil
+	default:
+		close(l.done)
+	}
+
+	conns := make([]*Conn, 0, len(l.conns))
+	for _, c := range l.conns {
+		conns = append(conns, c)
+	}
+	l.mu.Unlock()
+
+	for _, c := range conns {
+		_ = c.Close()
+	}
+
+	return l.conn.Close()
+}
+
+func (l *Listener) serve() {
+	buf := make([]byte, 0xFFFF)
+
+	for {
+		n, addr, err := l.conn.ReadFromUDP(buf)
+		if err != nil {
+			_ = l.Close()
+			return
+		}
+
+		p, err := parsePacket(append([]byte{}, buf[:n]...))
+		if err != nil {
+			continue
+		}
+
+		if p.control && p.typ == TypeHandshake {
+			l.handshake(p, addr)
+			continue
+		}
+
+		l.mu.Lock()
+		c := l.conns[p.socketID]
+		l.mu.Unlock()
+
+		if c != nil && c.remote.String() == addr.String() {
+			c.handle(p)
+		}
+	}
+}
+
+func (l *Listener) handshake(p *packet, addr *net.UDPAddr) {
+	req, err := parseHandshake(p.payload)
+	if err != nil {
+		return
+	}
+
+	switch req.typ {
+	case hsInduction:
+		res := &handshake{
+			version:   hsVersion,
+			extension: hsMagic,
+			seq:       req.seq,
+			mtu:       defaultMTU,
+			window:    defaultFlowWindow,
+			typ:       hsInduction,
+			cookie:    l.cookie(addr),
+			peerIP:    addr.IP,
+		}
+		l.send(addr, req.socketID, res.marshal())
+
+	case hsConclusion:
+		if req.cookie != l.cookie(addr) {
+			return
+		}
+
+		key := addr.String() + "/" + strconv.FormatUint(uint64(req.socketID), 10)
+
+		l.mu.Lock()
+		c := l.peers[key]
+		l.mu.Unlock()
+
+		// response was lost, caller sends conclusion again
+		if c != nil {
+			l.send(addr, req.socketID, c.response)
+			return
+		}
+
+		if reason := l.conclusion(req, addr, key); reason != 0 {
+			res := &handshake{version: hsVersion, typ: reason, peerIP: addr.IP}
+			l.send(addr, req.socketID, res.marshal())
+		}
+	}
+}
+
+// conclusion creates new connection or returns reject reason
+func (l *Listener) conclusion(req *handshake, addr *net.UDPAddr, key string) uint32 {
+	if req.version != hsVersion {
+		return RejectPeer
+	}
+
+	var km *keyMaterial
+
+	switch {
+	case (l.opts.Passphrase == "") != (req.km == nil):
+		return RejectUnsecure
+	case req.km != nil:
+		var err error
+		if km, err = parseKeyMaterial(req.km, l.opts.Passphrase); err != nil {
+			return RejectBadSecret
+		}
+	}
+
+	l.mu.Lock()
+	full := len(l.accept) >= AcceptBacklog || (l.limit > 0 && l.count >= l.limit)
+	if !full {
+		l.count++
+	}
+	l.mu.Unlock()
+
+	if full {
+		return RejectResource
+	}
+
+	latency := l.opts.Latency
+	if peer := time.Duration(req.latency) * time.Millisecond; peer > latency {
+		latency = peer
+	}
+
+	// both directions use the initial sequence number of the caller
+	c := newConn(randUint32(), req.socketID, req.seq, latency, km)
+	c.StreamID = req.streamID
+	c.passphrase = l.opts.Passphrase
+	c.remote = addr
+	c.write = func(b []byte) error {
+		_, err := l.conn.WriteToUDP(b, addr)
+		return err
+	}
+	c.onClose = func() {
+		l.mu.Lock()
+		delete(l.conns, c.socketID)
+		delete(l.peers, key)
+		l.mu.Unlock()
+	}
+
+	res := &handshake{
+		version:    hsVersion,
+		encryption: req.encryption,
+		extension:  extensionFlagHS,
+		seq:        req.seq,
+		mtu:        defaultMTU,
+		window:     defaultFlowWindow,
+		typ:        hsConclusion,
+		socketID:   c.socketID,
+		cookie:     req.cookie,
+		peerIP:     addr.IP,
+		latency:    uint16(latency / time.Millisecond),
+		km:         req.km,
+		response:   true,
+	}
+	if km != nil {
+		res.extension |= extensionFlagKM
+	}
+	c.response = res.marshal()
+
+	l.mu.Lock()
+	l.conns[c.socketID] = c
+	l.peers[key] = c
+	l.mu.Unlock()
+
+	l.send(addr, req.socketID, c.response)
+
+	go c.worker()
+
+	l.accept <- c
+
+	return 0
+}
+
+func (l *Listener) send(addr *net.UDPAddr, socketID uint32, hs []byte) {
+	p := &packet{control: true, typ: TypeHandshake, socketID: socketID, payload: hs}
+	_, _ = l.conn.WriteToUDP(p.marshal(), addr)
+}
+
+// cookie - SYN cookie from the caller address, so listener doesn't
+// store anything until conclusion
+func (l *Listener) cookie(addr *net.UDPAddr) uint32 {
+	return crc32.ChecksumIEEE([]byte(l.secret + addr.String()))
+}
+
+// ParseStreamID returns resource name and publish mode from stream ID,
+// simple name or access control format: #!::r=camera1,m=publish
+func ParseStreamID(s string) (name string, publish bool) {
+	if !strings.HasPrefix(s, "#!::") {
+		return s, false
+	}
+
+	for _, kv := range strings.Split(s[4:], ",") {
+		if i := strings.IndexByte(kv, '='); i > 0 {
+			switch kv[:i] {
+			case "r":
+				name = kv[i+1:]
+			case "m":
+				publish = kv[i+1:] == "publish"
+			}
+		}
+	}
+
+	return
+}
@@ -0,0 +1,147 @@
+package srt
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// control packet types
+const (
+	TypeHandshake = 0x0
+	TypeKeepalive = 0x1
+	TypeACK       = 0x2
+	TypeNAK       = 0x3
+	TypeShutdown  = 0x5
+	TypeACKACK    = 0x6
+	TypeExt       = 0x7FFF // user defined, subtype is the SRT command: KMREQ, KMRSP
+)
+
+// data packet message flags
+const (
+	msgSolo        = 0xC0000000 // packet position: single packet message
+	msgKeyEven     = 0x08000000 // encrypted with even key
+	msgKeyOdd      = 0x10000000 // encrypted with odd key
+	msgKeyMask     = 0x18000000
+	msgRetransmit  = 0x04000000
+	msgNumberMask  = 0x03FFFFFF
+	seqNumberMask  = 0x7FFFFFFF
+	headerSize     = 16
+	lossRangeFirst = 0x80000000
+)
+
+// packet - data or control SRT packet, header fields are common,
+// control packets use type, subtype and info instead of sequence and message
+type packet struct {
+	control bool
+
+	seq uint32 // data: sequence number
+	msg uint32 // data: flags and message number
+
+	typ     uint16 // control: packet type
+	subtype uint16 // control: subtype of TypeExt
+	info    uint32 // control: type-specific information
+
+	timestamp uint32 // microseconds from the connection start
+	socketID  uint32 // destination socket ID
+
+	payload []byte
+}
+
+func parsePacket(b []byte) (*packet, error) {
+	if len(b) < headerSize {
+		return nil, errors.New("srt: packet too short")
+	}
+
+	p := &packet{
+		timestamp: binary.BigEndian.Uint32(b[8:]),
+		socketID:  binary.BigEndian.Uint32(b[12:]),
+		payload:   b[headerSize:],
+	}
+
+	if b[0]&0x80 != 0 {
+		p.control = true
+		p.typ = binary.BigEndian.Uint16(b) & 0x7FFF
+		p.subtype = binary.BigEndian.Uint16(b[2:])
+		p.info = binary.BigEndian.Uint32(b[4:])
+	} else {
+		p.seq = binary.BigEndian.Uint32(b)
+		p.msg = binary.BigEndian.Uint32(b[4:])
+	}
+
+	return p, nil
+}
+
+func (p *packet) marshal() []byte {
+	b := make([]byte, headerSize+len(p.payload))
+
+	if p.control {
+		binary.BigEndian.PutUint16(b, 0x8000|p.typ)
+		binary.BigEndian.PutUint16(b[2:], p.subtype)
+		binary.BigEndian.PutUint32(b[4:], p.info)
+	} else {
+		binary.BigEndian.PutUint32(b, p.seq&seqNumberMask)
+		binary.BigEndian.PutUint32(b[4:], p.msg)
+	}
+
+	binary.BigEndian.PutUint32(b[8:], p.timestamp)
+	binary.BigEndian.PutUint32(b[12:], p.socketID)
+	copy(b[headerSize:], p.payload)
+
+	return b
+}
+
+// seqDiff returns a - b for 31 bit sequence numbers with wrap
+func seqDiff(a, b uint32) int32 {
+	return int32((a-b)<<1) >> 1
+}
+
+func seqNext(seq uint32) uint32 {
+	return (seq + 1) & seqNumberMask
+}
+
+// lossList encodes lost sequence numbers, ranges as first and last numbers
+func lossList(seqs []uint32) []byte {
+	var b []byte
+	for i := 0; i < len(seqs); {
+		j := i
+		for j+1 < len(seqs) && seqs[j+1] == seqNext(seqs[j]) {
+			j++
+		}
+		if i == j {
+			b = appendUint32(b, seqs[i])
+		} else {
+			b = appendUint32(b, seqs[i]|lossRangeFirst)
+			b = appendUint32(b, seqs[j])
+		}
+		i = j + 1
+	}
+	return b
+}
+
+func parseLossList(b []byte) (seqs []uint32) {
+	for len(b) >= 4 {
+		seq := binary.BigEndian.Uint32(b)
+		b = b[4:]
+
+		if seq&lossRangeFirst == 0 {
+			seqs = append(seqs, seq)
+			continue
+		}
+
+		if len(b) < 4 {
+			break
+		}
+		last := binary.BigEndian.Uint32(b)
+		b = b[4:]
+
+		// limit range for broken packets
+		for seq &= seqNumberMask; seqDiff(last, seq) >= 0 && len(seqs) < 8192; seq = seqNext(seq) {
+			seqs = append(seqs, seq)
+		}
+	}
+	return
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
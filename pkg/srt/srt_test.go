@@ -0,0 +1,194 @@
+package srt
+
+import (
+	"bytes"
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestKeyWrap(t *testing.T) {
+	// RFC 3394, 4.1 Wrap 128 bits of Key Data with a 128-bit KEK
+	kek, _ := hex.DecodeString("000102030405060708090A0B0C0D0E0F")
+	key, _ := hex.DecodeString("00112233445566778899AABBCCDDEEFF")
+
+	wrap, err := keyWrap(kek, key)
+	assert.Nil(t, err)
+	assert.Equal(t, "1FA68B0A8112B447AEF34BD8FB5A7B829D3E862371D2CFE5", strings.ToUpper(hex.EncodeToString(wrap)))
+
+	unwrap, err := keyUnwrap(kek, wrap)
+	assert.Nil(t, err)
+	assert.Equal(t, key, unwrap)
+
+	kek[0] = 1
+	_, err = keyUnwrap(kek, wrap)
+	assert.Equal(t, ErrBadSecret, err)
+}
+
+func TestLossList(t *testing.T) {
+	seqs := []uint32{1, 3, 4, 5, seqNumberMask, 0}
+	assert.Equal(t, seqs, parseLossList(lossList(seqs)))
+}
+
+func TestKeyRefresh(t *testing.T) {
+	km, err := newKeyMaterial(16)
+	assert.Nil(t, err)
+
+	receiver := newConn(2, 1, 100, DefaultLatency, km)
+	receiver.passphrase = "secret123"
+
+	var sent []*packet
+	receiver.write = func(b []byte) error {
+		p, _ := parsePacket(b)
+		sent = append(sent, p)
+		return nil
+	}
+
+	// sender announces new odd key with the same salt and even key
+	refresh, err := newKeyMaterial(16)
+	assert.Nil(t, err)
+	refresh.salt = km.salt
+	_ = refresh.setKey(keyOdd, refresh.keys[keyEven])
+	_ = refresh.setKey(keyEven, km.keys[keyEven])
+
+	msg, err := refresh.marshal("secret123")
+	assert.Nil(t, err)
+
+	receiver.handle(&packet{control: true, typ: TypeExt, subtype: extensionKMReq, payload: msg})
+
+	assert.Len(t, sent, 1)
+	assert.Equal(t, uint16(TypeExt), sent[0].typ)
+	assert.Equal(t, uint16(extensionKMRsp), sent[0].subtype)
+	assert.Equal(t, msg, sent[0].payload)
+
+	// packets with the old even key and the new odd key
+	data := []byte("MPEG-TS payload")
+	receiver.handle(&packet{seq: 100, msg: msgSolo | msgKeyEven | 1, payload: km.crypt(keyEven, 100, data)})
+	receiver.handle(&packet{seq: 101, msg: msgSolo | msgKeyOdd | 2, payload: refresh.crypt(keyOdd, 101, data)})
+
+	buf := make([]byte, 2*len(data))
+	_, err = io.ReadFull(receiver, buf)
+	assert.Nil(t, err)
+	assert.Equal(t, append(data, data...), buf)
+
+	// key material with the wrong passphrase
+	msg, _ = refresh.marshal("wrong12345")
+	receiver.handle(&packet{control: true, typ: TypeExt, subtype: extensionKMReq, payload: msg})
+	assert.Equal(t, appendUint32(nil, kmBadSecret), sent[1].payload)
+}
+
+func TestRetransmit(t *testing.T) {
+	sender := newConn(1, 2, seqNumberMask-10, DefaultLatency, nil)
+	receiver := newConn(2, 1, seqNumberMask-10, DefaultLatency, nil)
+
+	// every fifth data packet is lost on the first send
+	link := func(dst *Conn, lossy bool) func(b []byte) error {
+		ch := make(chan []byte, 1000)
+		go func() {
+			for b := range ch {
+				p, _ := parsePacket(b)
+				if lossy && !p.control && p.msg&msgRetransmit == 0 && p.seq%5 == 0 {
+					continue
+				}
+				dst.handle(p)
+			}
+		}()
+		return func(b []byte) error {
+			ch <- b
+			return nil
+		}
+	}
+	sender.write = link(receiver, true)
+	receiver.write = link(sender, false)
+
+	go sender.worker()
+	go receiver.worker()
+
+	data := make([]byte, 100*PayloadSize)
+	for i := range data {
+		data[i] = byte(i / PayloadSize)
+	}
+
+	n, err := sender.Write(data)
+	assert.Nil(t, err)
+	assert.Equal(t, len(data), n)
+
+	buf := make([]byte, len(data))
+	_, err = io.ReadFull(receiver, buf)
+	assert.Nil(t, err)
+	assert.Equal(t, data, buf)
+
+	_ = sender.Close()
+	_ = receiver.Close()
+}
+
+func TestLoopback(t *testing.T) {
+	opts := &Options{Passphrase: "secret123"}
+
+	l, err := Listen("127.0.0.1:0", opts)
+	assert.Nil(t, err)
+	defer l.Close()
+
+	address := l.Addr().String()
+
+	// wrong passphrase and no encryption are rejected
+	_, err = Dial(address, &Options{Passphrase: "wrong12345"})
+	assert.NotNil(t, err)
+	_, err = Dial(address, &Options{})
+	assert.NotNil(t, err)
+
+	caller, err := Open("srt://" + address + "?streamid=%23!::r=camera1,m=publish&passphrase=secret123&pbkeylen=32&latency=200")
+	assert.Nil(t, err)
+
+	server, err := l.Accept()
+	assert.Nil(t, err)
+
+	name, publish := ParseStreamID(server.StreamID)
+	assert.Equal(t, "camera1", name)
+	assert.True(t, publish)
+	assert.Equal(t, 32, len(server.km.keys[keyEven]))
+	assert.Equal(t, 200, int(server.Latency.Milliseconds()))
+
+	data := bytes.Repeat([]byte{0x47, 1, 2, 3}, 1000)
+
+	_, err = caller.Write(data)
+	assert.Nil(t, err)
+
+	buf := make([]byte, len(data))
+	_, err = io.ReadFull(server, buf)
+	assert.Nil(t, err)
+	assert.Equal(t, data, buf)
+
+	// other direction
+	_, err = server.Write(data[:100])
+	assert.Nil(t, err)
+	_, err = io.ReadFull(caller, buf[:100])
+	assert.Nil(t, err)
+	assert.Equal(t, data[:100], buf[:100])
+
+	// shutdown from the caller
+	_ = caller.Close()
+	_, err = server.Read(buf)
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestListenerMode(t *testing.T) {
+	l, err := listen("127.0.0.1:0", nil, 1)
+	assert.Nil(t, err)
+	defer l.Close()
+
+	caller, err := Dial(l.Addr().String(), &Options{})
+	assert.Nil(t, err)
+	defer caller.Close()
+
+	// only one connection in listener mode
+	_, err = Dial(l.Addr().String(), &Options{})
+	assert.NotNil(t, err)
+
+	server, err := l.Accept()
+	assert.Nil(t, err)
+	assert.NotNil(t, server)
+	assert.Len(t, l.accept, 0)
+}
@@ -2,6 +2,7 @@ package streamer
 
 import (
 	"strings"
+	"time"
 )
 
 const (
@@ -55,3 +56,74 @@ func Contains(medias []*Media, media *Media, codec *Codec) bool {
 	}
 	return ok1 && ok2
 }
+
+// NTP epoch starts 1900-01-01, Unix epoch starts 1970-01-01
+const ntpEpochOffset = 2208988800
+
+// ToNTP converts time to 64-bit NTP timestamp (RTCP, MP4)
+func ToNTP(t time.Time) uint64 {
+	sec := uint64(t.Unix()) + ntpEpochOffset
+	frac := uint64(t.Nanosecond()) << 32 / uint64(time.Second)
+	return sec<<32 | frac
+}
+
+// FromNTP converts 64-bit NTP timestamp to time
+func FromNTP(ntp uint64) time.Time {
+	sec := int64(ntp>>32) - ntpEpochOffset
+	nsec := (ntp & 0xFFFFFFFF) * uint64(time.Second) >> 32
+	return time.Unix(sec, int64(nsec))
+}
+
+// Timeline removes jumps of source timestamps (ex. discontinuity of HLS or
+// MPEG-TS source), it's shared by all tracks of the producer, so they stay in sync
+type Timeline struct {
+	offset time.Duration
+	last   time.Duration
+	ok     bool
+
+	start time.Time // wall clock of the first Wait
+	base  time.Duration
+}
+
+// Time returns continuous time for the source time
+func (t *Timeline) Time(ts time.Duration) time.Duration {
+	ts += t.offset
+
+	if !t.ok {
+		t.ok = true
+		t.last = ts
+		return ts
+	}
+
+	// tracks can be interleaved with some delay, so small step back is normal
+	if ts < t.last-2*time.Second || ts > t.last+10*time.Second {
+		t.offset += t.last - ts
+		ts = t.last
+	}
+
+	if ts > t.last {
+		t.last = ts
+	}
+
+	return ts
+}
+
+// Wait sleeps until wall clock reaches the time from the first call,
+// for sources that can be read faster than realtime (ex. HLS segments)
+func (t *Timeline) Wait(ts time.Duration) {
+	if t.start.IsZero() {
+		t.start = time.Now()
+		t.base = ts
+		return
+	}
+	if d := ts - t.base - time.Since(t.start); d > 0 {
+		time.Sleep(d)
+	}
+}
+
+// RTPTime converts time to RTP timestamp with the clock rate
+func RTPTime(ts time.Duration, clockRate uint32) uint32 {
+	sec := ts / time.Second
+	rem := ts % time.Second
+	return uint32(sec)*clockRate + uint32(rem*time.Duration(clockRate)/time.Second)
+}
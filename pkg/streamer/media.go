@@ -15,8 +15,9 @@ const (
 )
 
 const (
-	KindVideo = "video"
-	KindAudio = "audio"
+	KindVideo       = "video"
+	KindAudio       = "audio"
+	KindApplication = "application" // data tracks, ex. ONVIF metadata
 )
 
 const (
@@ -33,6 +34,8 @@ const (
 	CodecOpus = "OPUS" // payloadType: 111
 	CodecG722 = "G722"
 	CodecMPA  = "MPA" // payload: 14
+
+	CodecONVIFMetadata = "VND.ONVIF.METADATA" // ONVIF events and analytics XML
 )
 
 func GetKind(name string) string {
@@ -41,6 +44,8 @@ func GetKind(name string) string {
 		return KindVideo
 	case CodecPCMU, CodecPCMA, CodecAAC, CodecOpus, CodecG722, CodecMPA:
 		return KindAudio
+	case CodecONVIFMetadata:
+		return KindApplication
 	}
 	return ""
 }
@@ -49,7 +54,7 @@ func GetKind(name string) string {
 // - deepch/vdk/format/rtsp/sdp.Media
 // - pion/sdp.MediaDescription
 type Media struct {
-	Kind      string   `json:"kind,omitempty"` // video or audio
+	Kind      string   `json:"kind,omitempty"` // video, audio or application
 	Direction string   `json:"direction,omitempty"`
 	Codecs    []*Codec `json:"codecs,omitempty"`
 
@@ -187,7 +192,16 @@ func UnmarshalSDP(rawSDP []byte) ([]*Media, error) {
 }
 
 func MarshalSDP(medias []*Media) ([]byte, error) {
-	sd := &sdp.SessionDescription{}
+	sd := &sdp.SessionDescription{
+		Origin: sdp.Origin{
+			Username: "-", SessionID: 1, SessionVersion: 1,
+			NetworkType: "IN", AddressType: "IP4", UnicastAddress: "0.0.0.0",
+		},
+		SessionName: "-",
+		TimeDescriptions: []sdp.TimeDescription{
+			{Timing: sdp.Timing{StartTime: 0, StopTime: 0}},
+		},
+	}
 
 	payloadType := uint8(96)
 
@@ -204,6 +218,9 @@ func MarshalSDP(medias []*Media) ([]byte, error) {
 			},
 		}
 		md.WithCodec(payloadType, codec.Name, codec.ClockRate, codec.Channels, codec.FmtpLine)
+		if media.Control != "" {
+			md.WithValueAttribute("control", media.Control)
+		}
 
 		sd.MediaDescriptions = append(sd.MediaDescriptions, md)
 
@@ -46,3 +46,12 @@ type Consumer interface {
 	GetMedias() []*Media
 	AddTrack(media *Media, track *Track) *Track
 }
+
+// Playback - producer with control over recorded content (ex. NVR archive)
+type Playback interface {
+	Pause() error
+	Resume() error
+	// Seek to position in RTSP Range format: "npt=10-" or "clock=20230101T120000Z-"
+	Seek(position string) error
+	SetScale(scale float64) error
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/pion/rtp"
 	"sync"
+	"time"
 )
 
 type WriterFunc func(packet *rtp.Packet) error
@@ -13,6 +14,8 @@ type Track struct {
 	Codec     *Codec
 	Direction string
 	Sink      map[*Track]WriterFunc
+	clock     *clock // shared with all binded tracks
+	clockMx   sync.Mutex
 	mx        sync.Mutex
 }
 
@@ -39,7 +42,7 @@ func (t *Track) Bind(w WriterFunc) *Track {
 	}
 
 	clone := &Track{
-		Codec: t.Codec, Direction: t.Direction, Sink: t.Sink,
+		Codec: t.Codec, Direction: t.Direction, Sink: t.Sink, clock: t.getClock(true),
 	}
 	t.Sink[clone] = w
 
@@ -53,3 +56,56 @@ func (t *Track) Unbind() {
 	delete(t.Sink, t)
 	t.mx.Unlock()
 }
+
+// SetClock maps RTP timestamp to absolute capture time,
+// usually from RTCP Sender Report of the source
+func (t *Track) SetClock(rtpTime uint32, wallTime time.Time) {
+	t.getClock(true).set(rtpTime, wallTime)
+}
+
+// WallClock returns absolute capture time for RTP timestamp
+// or zero time if source doesn't provide it
+func (t *Track) WallClock(rtpTime uint32) time.Time {
+	if c := t.getClock(false); c != nil {
+		return c.get(rtpTime, t.Codec.ClockRate)
+	}
+	return time.Time{}
+}
+
+// getClock has own lock, because WallClock is called from the sinks
+// while mx of the track is locked
+func (t *Track) getClock(create bool) *clock {
+	t.clockMx.Lock()
+	defer t.clockMx.Unlock()
+
+	if t.clock == nil && create {
+		t.clock = &clock{}
+	}
+	return t.clock
+}
+
+type clock struct {
+	rtpTime  uint32
+	wallTime time.Time
+	mx       sync.Mutex
+}
+
+func (c *clock) set(rtpTime uint32, wallTime time.Time) {
+	c.mx.Lock()
+	c.rtpTime = rtpTime
+	c.wallTime = wallTime
+	c.mx.Unlock()
+}
+
+func (c *clock) get(rtpTime uint32, clockRate uint32) time.Time {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if c.wallTime.IsZero() || clockRate == 0 {
+		return time.Time{}
+	}
+
+	// signed difference, packet can be before or after the report
+	delta := int64(int32(rtpTime - c.rtpTime))
+	return c.wallTime.Add(time.Duration(delta) * time.Second / time.Duration(clockRate))
+}
@@ -0,0 +1,53 @@
+package streamer
+
+import (
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestNTP(t *testing.T) {
+	ts := time.Date(2022, time.September, 1, 12, 30, 0, 500_000_000, time.UTC)
+	ntp := ToNTP(ts)
+	assert.Equal(t, uint64(0x80000000), ntp&0xFFFFFFFF)
+	assert.WithinDuration(t, ts, FromNTP(ntp), time.Microsecond)
+}
+
+func TestWallClock(t *testing.T) {
+	track := &Track{Codec: &Codec{Name: CodecH264, ClockRate: 90000}}
+	assert.True(t, track.WallClock(0).IsZero())
+
+	var wallClock time.Time
+	clone := track.Bind(func(packet *rtp.Packet) error {
+		wallClock = track.WallClock(packet.Timestamp)
+		return nil
+	})
+
+	ts := time.Now()
+	track.SetClock(0xFFFFFFFF-45000+1, ts) // RTP time overflow
+
+	_ = track.WriteRTP(&rtp.Packet{Header: rtp.Header{Timestamp: 45000}})
+	assert.Equal(t, ts.Add(time.Second), wallClock)
+
+	_ = track.WriteRTP(&rtp.Packet{Header: rtp.Header{Timestamp: 0xFFFFFFFF - 90000 + 1}})
+	assert.Equal(t, ts.Add(-time.Second/2), wallClock)
+
+	assert.Equal(t, ts, clone.WallClock(0xFFFFFFFF-45000+1))
+}
+
+func TestWallClockRace(t *testing.T) {
+	track := &Track{Codec: &Codec{Name: CodecH264, ClockRate: 90000}}
+
+	done := make(chan struct{})
+	go func() {
+		// RTCP reader of the source
+		track.SetClock(0, time.Now())
+		close(done)
+	}()
+
+	_ = track.WallClock(0)
+	<-done
+
+	assert.False(t, track.WallClock(0).IsZero())
+}